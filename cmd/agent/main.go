@@ -2,27 +2,51 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/mansoormajeed/glimpse/internal/agent/agentid"
+	"github.com/mansoormajeed/glimpse/internal/agent/enroll"
 	"github.com/mansoormajeed/glimpse/internal/agent/heartbeat"
+	"github.com/mansoormajeed/glimpse/internal/agent/metrics"
+	"github.com/mansoormajeed/glimpse/internal/common/config"
 	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	"github.com/mansoormajeed/glimpse/internal/common/tlsutil"
 	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 )
 
+// keepaliveParams pings the server when the connection has been idle, so a
+// half-open connection is closed and the heartbeat stream reconnects instead
+// of sending into the void until TCP gives up. The server allows pings this often.
+var keepaliveParams = keepalive.ClientParameters{
+	Time:                30 * time.Second,
+	Timeout:             10 * time.Second,
+	PermitWithoutStream: true,
+}
+
 func main() {
 
-	debug := flag.Bool("debug", false, "Enable debug logging")
-	flag.Parse()
+	cfg, err := config.LoadAgentConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logger.Fatalf("Error loading config: %v", err)
+	}
 
-	if *debug {
-		logger.SetDebugLevel()
-		logger.Debug("Debug logging enabled")
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		logger.Fatalf("Error setting log level: %v", err)
 	}
+	logger.Debugf("Log level set to %s", cfg.LogLevel)
 
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -31,11 +55,16 @@ func main() {
 	}
 
 	logger.Infof("Starting the agent on host: %s", hostname)
+	collectors, err := metrics.NewRegistry(cfg)
+	if err != nil {
+		logger.Fatalf("Error setting up collectors: %v", err)
+	}
+	logger.Infof("Collectors: %s", strings.Join(collectors.Enabled(), ", "))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	setupSignalHandling(cancel)
-	run(ctx)
+	run(ctx, cfg, collectors)
 
 	logger.Infof("Agent is running... Press Ctrl+C to exit.")
 
@@ -43,19 +72,40 @@ func main() {
 	<-ctx.Done()
 }
 
-func run(ctx context.Context) {
+func run(ctx context.Context, cfg *config.AgentConfig, collectors *metrics.Registry) {
 
 	var opts []grpc.DialOption
-	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if cfg.TLS.Enabled {
+		tlsConfig, err := tlsutil.ClientConfig(cfg.TLS)
+		if err != nil {
+			logger.Errorf("Error setting up TLS: %v", err)
+			return
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	creds := enroll.NewCredentials(agentid.LoadOrGenerateAgentID())
+	opts = append(opts, grpc.WithPerRPCCredentials(creds), grpc.WithKeepaliveParams(keepaliveParams))
+	if cfg.JoinToken != "" && !cfg.TLS.Enabled {
+		logger.Warn("Enrolling over plaintext exposes the join token and agent secret, enable TLS")
+	}
 
-	conn, err := grpc.NewClient("localhost:5001", opts...)
+	conn, err := grpc.NewClient(cfg.ServerAddr, opts...)
 	if err != nil {
 		logger.Errorf("Error creating gRPC client: %v", err)
 		return
 	}
 	defer conn.Close()
 	client := pb.NewGlimpseServiceClient(conn)
-	heartbeatService := heartbeat.NewHeartbeatService(client)
+	enroller := enroll.NewEnroller(client, creds, cfg.JoinToken)
+	queue, err := heartbeat.NewQueue(cfg.Buffer)
+	if err != nil {
+		logger.Errorf("Error setting up the heartbeat buffer: %v", err)
+		return
+	}
+	heartbeatService := heartbeat.NewHeartbeatService(client, enroller, collectors, queue, cfg.HeartbeatInterval)
 	heartbeatService.Start(ctx)
 }
 
@@ -1,30 +1,153 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/mansoormajeed/glimpse/internal/common/config"
 	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	"github.com/mansoormajeed/glimpse/internal/common/tlsutil"
 	"github.com/mansoormajeed/glimpse/internal/server"
+	"github.com/mansoormajeed/glimpse/internal/server/export"
+	"github.com/mansoormajeed/glimpse/internal/server/notify"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Agents ping every 30s when idle. The server pings agents it has not heard
+// from in a minute and drops connections that do not answer, and lets agent
+// pings through as long as they are no more frequent than every 20s.
+var (
+	keepaliveParams = keepalive.ServerParameters{
+		Time:    time.Minute,
+		Timeout: 20 * time.Second,
+	}
+	keepalivePolicy = keepalive.EnforcementPolicy{
+		MinTime:             20 * time.Second,
+		PermitWithoutStream: true,
+	}
 )
 
 func main() {
 
-	listenPort := flag.Int("port", 5001, "Port to listen on")
-	debug := flag.Bool("debug", false, "Enable debug logging")
-	flag.Parse()
+	cfg, err := config.LoadServerConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logger.Fatalf("Error loading config: %v", err)
+	}
 
-	if *debug {
-		logger.SetDebugLevel()
-		logger.Debug("Debug logging enabled")
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		logger.Fatalf("Error setting log level: %v", err)
 	}
+	logger.Debugf("Log level set to %s", cfg.LogLevel)
+
 	logger.Info("Starting the server...")
-	logger.Debugf("Listening on port: %d", *listenPort)
 
-	store := server.NewServerStore(5) // for now use buffer 5. later change to 60
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	tiers := server.TiersFromConfig(cfg.Storage)
+	var storage server.Storage
+	if cfg.Storage.Type == "disk" {
+		storage, err = server.NewSegmentStorage(cfg.HistoryPath(), tiers)
+		if err != nil {
+			logger.Fatalf("Error opening history storage: %v", err)
+		}
+		logger.Infof("Keeping history in %s", cfg.HistoryPath())
+	}
+	store := server.NewServerStore(cfg.HistorySize, storage, tiers)
+	defer store.Close()
+
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepaliveParams),
+		grpc.KeepaliveEnforcementPolicy(keepalivePolicy),
+	}
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := tlsutil.ServerConfig(cfg.TLS)
+		if err != nil {
+			logger.Fatalf("Error setting up TLS: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		logger.Infof("TLS enabled for gRPC (client auth: %s)", clientAuthName(cfg.TLS))
+	} else {
+		logger.Warn("TLS is disabled, agents connect in plaintext")
+	}
+
+	var agentAuth *server.AgentAuth
+	if cfg.Auth.Enabled {
+		agentAuth, err = server.NewAgentAuth(cfg.CredentialsPath(), cfg.Auth.JoinTokens)
+		if err != nil {
+			logger.Fatalf("Error loading agent credentials: %v", err)
+		}
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(agentAuth.UnaryInterceptor),
+			grpc.ChainStreamInterceptor(agentAuth.StreamInterceptor),
+		)
+		logger.Info("Agent authentication enabled")
+	}
+
+	store.StartStatusSweeper(cfg.Status, cfg.AgentHeartbeatInterval)
+
+	dispatcher, err := notify.NewDispatcher(cfg.Notifications, cfg.Alerts.Rules)
+	if err != nil {
+		logger.Fatalf("Error setting up notifiers: %v", err)
+	}
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	alerts, err := server.NewAlertEngine(store, cfg.Alerts, dispatcher)
+	if err != nil {
+		logger.Fatalf("Error loading alert rules: %v", err)
+	}
+	alerts.Start()
+	defer alerts.Stop()
+	server.RegisterAlertHandlers(alerts)
+
+	forwarder := export.NewForwarder(cfg.Export)
+	forwarder.Start()
+	defer forwarder.Stop()
+
+	glimpseServer := server.NewGlimpseServer(store, agentAuth, forwarder, cfg)
 
-	server.StartHTTPServer(store)
+	if cfg.Auth.AdminToken != "" {
+		server.RegisterAdminHandlers(store, agentAuth, glimpseServer, cfg.Auth.AdminToken)
+		server.RegisterNotifierHandlers(dispatcher, cfg.Auth.AdminToken)
+	}
+
+	httpServer := server.StartHTTPServer(store, forwarder, cfg.HTTPListenAddr)
 
 	// Start the gRPC server
-	server.StartGRPCServer(store)
+	grpcServer := server.StartGRPCServer(glimpseServer, cfg.GRPCListenAddr, opts...)
 
+	<-ctx.Done()
+	logger.Info("Shutting down the server...")
+	// stop taking heartbeats first, the deferred stops then flush what they hold
+	server.StopGRPCServer(grpcServer, glimpseServer, shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Warnf("Error shutting down the HTTP server: %v", err)
+	}
+}
+
+// how long running calls get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+func clientAuthName(t config.ServerTLSConfig) string {
+	switch {
+	case t.ClientAuth != "":
+		return t.ClientAuth
+	case t.ClientCAFile != "":
+		return "require"
+	default:
+		return "none"
+	}
 }
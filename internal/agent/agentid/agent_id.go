@@ -9,9 +9,9 @@ import (
 	"github.com/mansoormajeed/glimpse/internal/common/logger"
 )
 
-func getAgentIDPath() string {
+func getStateDir() string {
 	if xdgState := os.Getenv("XDG_STATE_HOME"); xdgState != "" {
-		return filepath.Join(xdgState, "glimpse", "agent-id")
+		return filepath.Join(xdgState, "glimpse")
 	}
 
 	homeDir, err := os.UserHomeDir()
@@ -19,7 +19,15 @@ func getAgentIDPath() string {
 		logger.Fatalf("Unable to get home directory: %v", err)
 	}
 
-	return filepath.Join(homeDir, ".glimpse", "agent-id")
+	return filepath.Join(homeDir, ".glimpse")
+}
+
+func getAgentIDPath() string {
+	return filepath.Join(getStateDir(), "agent-id")
+}
+
+func getAgentSecretPath() string {
+	return filepath.Join(getStateDir(), "agent-secret")
 }
 
 func LoadOrGenerateAgentID() string {
@@ -46,3 +54,36 @@ func LoadOrGenerateAgentID() string {
 
 	return id
 }
+
+// LoadSecret returns the per-agent secret issued by the server at enrollment, if any
+func LoadSecret() (string, bool) {
+	data, err := os.ReadFile(getAgentSecretPath())
+	if err != nil {
+		return "", false
+	}
+	secret := strings.TrimSpace(string(data))
+	return secret, secret != ""
+}
+
+// SaveSecret stores the per-agent secret next to the agent ID
+func SaveSecret(secret string) error {
+	path := getAgentSecretPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(secret), 0600)
+}
+
+// ClearSecret forgets the stored secret so the agent enrolls again
+func ClearSecret() error {
+	err := os.Remove(getAgentSecretPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// BufferDir is where heartbeats that could not be sent are spilled by default
+func BufferDir() string {
+	return filepath.Join(getStateDir(), "buffer")
+}
@@ -0,0 +1,115 @@
+package enroll
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mansoormajeed/glimpse/internal/agent/agentid"
+	"github.com/mansoormajeed/glimpse/internal/common/auth"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+// Credentials attaches the agent ID and secret to every gRPC call.
+// Nothing is attached until the agent has a secret.
+type Credentials struct {
+	mu      sync.RWMutex
+	agentID string
+	secret  string
+}
+
+func NewCredentials(agentID string) *Credentials {
+	secret, _ := agentid.LoadSecret()
+	return &Credentials{
+		agentID: agentID,
+		secret:  secret,
+	}
+}
+
+func (c *Credentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.secret == "" {
+		return nil, nil
+	}
+	return map[string]string{
+		auth.MetadataAgentID:     c.agentID,
+		auth.MetadataAgentSecret: c.secret,
+	}, nil
+}
+
+// RequireTransportSecurity is false so enrollment still works over plaintext,
+// but the secret is then visible to anyone on the path. Use TLS.
+func (c *Credentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func (c *Credentials) hasSecret() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.secret != ""
+}
+
+func (c *Credentials) setSecret(secret string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.secret = secret
+}
+
+// Enroller trades the join token for a secret when the agent does not have one yet
+type Enroller struct {
+	client    pb.GlimpseServiceClient
+	creds     *Credentials
+	joinToken string
+}
+
+func NewEnroller(client pb.GlimpseServiceClient, creds *Credentials, joinToken string) *Enroller {
+	return &Enroller{
+		client:    client,
+		creds:     creds,
+		joinToken: joinToken,
+	}
+}
+
+// Ensure enrolls the agent unless it already has a secret. Without a join token
+// it does nothing, which is what servers without auth expect.
+func (e *Enroller) Ensure(ctx context.Context, hostname string) error {
+	if e.creds.hasSecret() || e.joinToken == "" {
+		return nil
+	}
+
+	logger.Infof("Enrolling agent %s with the server", e.creds.agentID)
+	resp, err := e.client.Enroll(ctx, &pb.EnrollRequest{
+		AgentId:   e.creds.agentID,
+		Hostname:  hostname,
+		JoinToken: e.joinToken,
+	})
+	if err != nil {
+		return fmt.Errorf("enrollment failed: %w", err)
+	}
+	if resp.AgentSecret == "" {
+		return errors.New("enrollment failed: server returned an empty secret")
+	}
+
+	if err := agentid.SaveSecret(resp.AgentSecret); err != nil {
+		return fmt.Errorf("error saving agent secret: %w", err)
+	}
+	e.creds.setSecret(resp.AgentSecret)
+	logger.Info("Agent enrolled successfully")
+	return nil
+}
+
+// Invalidate drops the secret after the server stopped accepting it, so the next
+// Ensure enrolls again. Without a join token there is no way back, so the secret is kept.
+func (e *Enroller) Invalidate() {
+	if e.joinToken == "" {
+		return
+	}
+	if err := agentid.ClearSecret(); err != nil {
+		logger.Errorf("Error removing agent secret: %v", err)
+	}
+	e.creds.setSecret("")
+}
@@ -2,28 +2,59 @@ package heartbeat
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
 	"time"
 
 	"github.com/mansoormajeed/glimpse/internal/agent/agentid"
-	m "github.com/mansoormajeed/glimpse/internal/agent/metrics"
+	"github.com/mansoormajeed/glimpse/internal/agent/enroll"
+	"github.com/mansoormajeed/glimpse/internal/agent/metrics"
 	"github.com/mansoormajeed/glimpse/internal/common/logger"
 	"github.com/mansoormajeed/glimpse/internal/common/logger/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
 )
 
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// errReregister is returned by streamHeartbeats when the server asked us to reopen the stream
+var errReregister = errors.New("server requested re-registration")
+
+// HeartbeatService collects a heartbeat every interval into a queue, and
+// sends the queue to the server whenever it can be reached. Heartbeats
+// collected while it cannot are replayed in order once it is back.
 type HeartbeatService struct {
-	client pb.GlimpseServiceClient
+	client     pb.GlimpseServiceClient
+	enroller   *enroll.Enroller
+	collectors *metrics.Registry
+	queue      *Queue
+	interval   time.Duration
+
+	queued     chan struct{}      // signalled when a heartbeat was queued
+	collectNow chan struct{}      // asks for a heartbeat without waiting for the ticker
+	intervals  chan time.Duration // interval changes from the server, for the collect loop
 }
 
 var agentID string
 
-func NewHeartbeatService(client pb.GlimpseServiceClient) *HeartbeatService {
+func NewHeartbeatService(client pb.GlimpseServiceClient, enroller *enroll.Enroller, collectors *metrics.Registry, queue *Queue, interval time.Duration) *HeartbeatService {
 	return &HeartbeatService{
-		client: client,
+		client:     client,
+		enroller:   enroller,
+		collectors: collectors,
+		queue:      queue,
+		interval:   interval,
+		queued:     make(chan struct{}, 1),
+		collectNow: make(chan struct{}, 1),
+		intervals:  make(chan time.Duration, 1),
 	}
 }
 
@@ -31,56 +62,278 @@ func (h *HeartbeatService) Start(ctx context.Context) {
 
 	logger.Info("Starting Heartbeat Service...")
 	agentID = agentid.LoadOrGenerateAgentID()
+	go h.collect(ctx, h.interval)
+	go h.run(ctx)
+	<-ctx.Done()
+	logger.Info("Stopping Heartbeat Service...")
+}
+
+// collect queues a heartbeat every interval, whether or not the server can be
+// reached
+func (h *HeartbeatService) collect(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case interval := <-h.intervals:
+			ticker.Reset(interval)
+			continue
+		case <-h.collectNow:
+		case <-ticker.C:
+		}
+
+		req, err := h.buildRequest(ctx)
+		if err != nil {
+			logger.Errorf("Error building heartbeat: %v", err)
+			continue
+		}
+		h.queue.Push(req, time.Now())
+		select {
+		case h.queued <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// setInterval changes the heartbeat interval, replacing a change the collect
+// loop has not picked up yet
+func (h *HeartbeatService) setInterval(interval time.Duration) {
+	h.interval = interval
+	select {
+	case <-h.intervals:
+	default:
+	}
+	h.intervals <- interval
+}
+
+// flush sends the queued heartbeats, oldest first. One that fails to send is
+// left in the queue for the next attempt.
+//
+// Delivery is at most once: a stream Send that returns nil has only buffered
+// the heartbeat, and the server does not acknowledge it. The heartbeats
+// buffered when the stream breaks are lost, keeping them for a replay would
+// store the ones that did arrive twice.
+//
+// The agent id is set here rather than when the heartbeat is built, it only
+// changes in run, which is also the only caller.
+func (h *HeartbeatService) flush(send func(*pb.HeartbeatRequest) error) error {
+	replayed := 0
+	defer func() {
+		if replayed > 0 {
+			logger.Infof("Replayed %d buffered heartbeats", replayed)
+		}
+	}()
+	for {
+		e, ok := h.queue.Peek()
+		if !ok {
+			return nil
+		}
+		req := proto.Clone(e.req).(*pb.HeartbeatRequest)
+		req.AgentId = agentID
+		// one sent within an interval of its collection is live, older ones
+		// were held back and tell the server when they were collected
+		age := time.Since(e.collected)
+		if age >= h.interval {
+			req.DelayMs = age.Milliseconds()
+		}
+		logger.Debug(util.PrettyYaml(req))
+		if err := send(req); err != nil {
+			return err
+		}
+		h.queue.Pop(e)
+		if req.DelayMs > 0 {
+			replayed++
+		}
+	}
+}
+
+// run keeps a metrics stream open, reconnecting with backoff when it drops.
+// Servers that predate StreamMetrics get unary heartbeats instead.
+func (h *HeartbeatService) run(ctx context.Context) {
+	delay := minReconnectDelay
+	for {
+		start := time.Now()
+		err := h.enroll(ctx)
+		if err == nil {
+			err = h.streamHeartbeats(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case status.Code(err) == codes.Unimplemented:
+			logger.Warn("Server does not support metrics streaming, falling back to unary heartbeats")
+			h.unaryHeartbeats(ctx)
+			return
+		case errors.Is(err, errReregister):
+			logger.Info("Server requested re-registration, reopening stream")
+			agentID = agentid.LoadOrGenerateAgentID()
+			delay = minReconnectDelay
+			continue
+		case status.Code(err) == codes.Unauthenticated:
+			logger.Errorf("Server rejected the agent credentials: %v", status.Convert(err).Message())
+			h.enroller.Invalidate()
+		case status.Code(err) == codes.PermissionDenied:
+			logger.Errorf("Agent is not allowed to report: %v", status.Convert(err).Message())
+			delay = maxReconnectDelay
+		}
+
+		// a stream that stayed up for a while was healthy, so start the backoff over
+		if time.Since(start) > maxReconnectDelay {
+			delay = minReconnectDelay
+		}
+		logger.Errorf("Metrics stream closed: %v. Reconnecting in %s", err, delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+func (h *HeartbeatService) streamHeartbeats(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := h.client.StreamMetrics(ctx)
+	if err != nil {
+		return err
+	}
+
+	commands := make(chan *pb.ServerCommand)
+	recvErr := make(chan error, 1)
 	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
 		for {
+			cmd, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
 			select {
+			case commands <- cmd:
 			case <-ctx.Done():
-				logger.Info("Stopping Heartbeat Service...")
 				return
-			case <-ticker.C:
-				err := h.SendHeartbeat()
-				if err != nil {
-					logger.Errorf("Error sending heartbeat: %v", err)
-				} else {
-					logger.Info("Heartbeat sent successfully")
-				}
 			}
 		}
 	}()
-	<-ctx.Done()
+
+	send := func(req *pb.HeartbeatRequest) error {
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+		logger.Debug("Heartbeat sent on stream")
+		return nil
+	}
+
+	// Send only reports io.EOF when the stream breaks. The status the server
+	// closed it with comes out of Recv, so wait for that instead.
+	sendFailed := func(err error) error {
+		for {
+			select {
+			case recvErr := <-recvErr:
+				return recvErr
+			case <-commands:
+			case <-ctx.Done():
+				return err
+			}
+		}
+	}
+
+	// replay what was queued while we were away, or send a fresh heartbeat so
+	// the server learns about us without waiting an interval
+	if h.queue.Len() == 0 {
+		select {
+		case h.collectNow <- struct{}{}:
+		default:
+		}
+	}
+	if err := h.flush(send); err != nil {
+		return sendFailed(err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			stream.CloseSend()
+			return ctx.Err()
+		case err := <-recvErr:
+			return err
+		case cmd := <-commands:
+			switch cmd.Type {
+			case pb.CommandType_COMMAND_SET_INTERVAL:
+				interval := time.Duration(cmd.IntervalMs) * time.Millisecond
+				if interval <= 0 {
+					logger.Warnf("Ignoring invalid interval from server: %dms", cmd.IntervalMs)
+					continue
+				}
+				logger.Infof("Server changed heartbeat interval to %s (%s)", interval, cmd.Reason)
+				h.setInterval(interval)
+			case pb.CommandType_COMMAND_REREGISTER:
+				stream.CloseSend()
+				return errReregister
+			default:
+				logger.Warnf("Ignoring unknown command from server: %v", cmd.Type)
+			}
+		case <-h.queued:
+			if err := h.flush(send); err != nil {
+				return sendFailed(err)
+			}
+		}
+	}
 }
 
-func (h *HeartbeatService) SendHeartbeat() error {
+func (h *HeartbeatService) unaryHeartbeats(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.queued:
+			err := h.enroll(ctx)
+			if err == nil {
+				err = h.flush(h.sendHeartbeat)
+			}
+			if status.Code(err) == codes.Unauthenticated {
+				h.enroller.Invalidate()
+			}
+			if err != nil {
+				logger.Errorf("Error sending heartbeat: %v", err)
+			} else {
+				logger.Info("Heartbeat sent successfully")
+			}
+		}
+	}
+}
 
+func (h *HeartbeatService) enroll(ctx context.Context) error {
 	hostname, err := os.Hostname()
 	if err != nil {
 		return fmt.Errorf("error getting hostname: %v", err)
 	}
-	os := runtime.GOOS
-	metrics, err := m.GetAgentMetrics()
+	return h.enroller.Ensure(ctx, hostname)
+}
+
+func (h *HeartbeatService) buildRequest(ctx context.Context) (*pb.HeartbeatRequest, error) {
+	hostname, err := os.Hostname()
 	if err != nil {
-		return fmt.Errorf("error getting agent metrics: %v", err)
+		return nil, fmt.Errorf("error getting hostname: %v", err)
 	}
-	req := &pb.HeartbeatRequest{
+	metrics, samples := h.collectors.Collect(ctx)
+	return &pb.HeartbeatRequest{
 		Hostname: hostname,
-		Os:       os,
-		AgentId:  agentID,
-		Metrics: &pb.AgentMetrics{
-			CpuUsage:        metrics.CPUUsage,
-			MemoryUsage:     metrics.MemoryUsage,
-			DiskUsage:       metrics.DiskUsage,
-			NetworkUpload:   metrics.NetworkUpload,
-			NetworkDownload: metrics.NetworkDownload,
-			DiskRead:        metrics.DiskReadKB,
-			DiskWrite:       metrics.DiskWriteKB,
-			CpuTemp:         metrics.CPUTemp,
-			Uptime:          metrics.Uptime,
-		},
-	}
-	logger.Info("Sending heartbeat request.... Hostname: ", hostname)
-	logger.Debugf(util.PrettyYaml(req))
+		Os:       runtime.GOOS,
+		Metrics:  metrics,
+		Samples:  samples,
+	}, nil
+}
+
+// sendHeartbeat sends a single heartbeat over the unary RPC
+func (h *HeartbeatService) sendHeartbeat(req *pb.HeartbeatRequest) error {
+
+	logger.Info("Sending heartbeat request.... Hostname: ", req.Hostname)
 
 	resp, err := h.client.Heartbeat(context.Background(), req)
 	if err != nil {
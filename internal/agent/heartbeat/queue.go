@@ -0,0 +1,306 @@
+package heartbeat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/agent/agentid"
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	"google.golang.org/protobuf/encoding/protodelim"
+
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+const chunkSuffix = ".chunk"
+
+// Queue holds the heartbeats that were collected but not sent yet, oldest
+// first. The newest ones are kept in memory, when there are more than the
+// memory limit the older half is spilled to a chunk file. Chunks are read
+// back one at a time as the queue drains, and the ones left over when the
+// agent stops are picked up by the next run.
+type Queue struct {
+	mu       sync.Mutex
+	dir      string
+	memLimit int
+	max      int
+
+	head    []queued // read back from the oldest chunk
+	chunks  []chunk  // on disk, oldest first
+	tail    []queued // not spilled
+	size    int
+	nextSeq uint64
+
+	dropped     int  // since the queue was last empty
+	spillFailed bool // the last spill failed, so the next failure is not logged again
+}
+
+// queued is one heartbeat with the time its metrics were collected. seq tells
+// apart the entry that was peeked from the one that is first when it is popped.
+type queued struct {
+	seq       uint64
+	collected time.Time
+	req       *pb.HeartbeatRequest
+}
+
+type chunk struct {
+	path    string
+	entries int
+}
+
+// NewQueue opens the buffer, loading the chunks a previous run left behind
+func NewQueue(cfg config.BufferConfig) (*Queue, error) {
+	q := &Queue{
+		dir:      cfg.Directory,
+		memLimit: cfg.MemoryEntries,
+		max:      max(cfg.MaxEntries, 1), // with buffering off the heartbeat about to be sent is still queued
+	}
+	if q.dir == "" {
+		q.dir = agentid.BufferDir()
+	}
+	if err := os.MkdirAll(q.dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating the buffer directory: %v", err)
+	}
+
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the buffer directory: %v", err)
+	}
+	// names start with the zero padded collection time, so this is oldest first
+	for _, e := range entries {
+		n, seq, ok := parseChunkName(e.Name())
+		if !ok {
+			continue
+		}
+		q.chunks = append(q.chunks, chunk{path: filepath.Join(q.dir, e.Name()), entries: n})
+		q.size += n
+		q.nextSeq = max(q.nextSeq, seq+uint64(n))
+	}
+	if q.size > 0 {
+		logger.Infof("Found %d buffered heartbeats to replay in %s", q.size, q.dir)
+	}
+	q.trim()
+	return q, nil
+}
+
+// Push queues a heartbeat, dropping the oldest one when the queue is full
+func (q *Queue) Push(req *pb.HeartbeatRequest, collected time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.tail = append(q.tail, queued{seq: q.nextSeq, collected: collected, req: req})
+	q.nextSeq++
+	q.size++
+	if len(q.tail) > q.memLimit {
+		q.spill()
+	}
+	q.trim()
+}
+
+// Peek returns the oldest heartbeat without removing it. The request must not
+// be modified, a spill may be writing it out at the same time.
+func (q *Queue) Peek() (queued, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// an unreadable chunk loads nothing, so go on to the next one
+	for len(q.head) == 0 && len(q.chunks) > 0 {
+		q.load()
+	}
+	switch {
+	case len(q.head) > 0:
+		return q.head[0], true
+	case len(q.tail) > 0:
+		return q.tail[0], true
+	}
+	return queued{}, false
+}
+
+// Pop removes the heartbeat Peek returned, unless it was dropped meanwhile
+func (q *Queue) Pop(e queued) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// it may have been spilled after it was peeked
+	for len(q.head) == 0 && len(q.chunks) > 0 {
+		q.load()
+	}
+	switch {
+	case len(q.head) > 0 && q.head[0].seq == e.seq:
+		q.head = q.head[1:]
+	case len(q.head) == 0 && len(q.tail) > 0 && q.tail[0].seq == e.seq:
+		q.tail = q.tail[1:]
+	default:
+		return
+	}
+	q.size--
+
+	if q.size == 0 && q.dropped > 0 {
+		logger.Warnf("%d heartbeats were dropped because the buffer was full", q.dropped)
+		q.dropped = 0
+	}
+}
+
+// Len is the number of queued heartbeats
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// spill writes the older half of the in-memory heartbeats to a chunk. When
+// that fails they stay in memory, still bounded by max.
+func (q *Queue) spill() {
+	n := len(q.tail) - q.memLimit/2
+	path, err := q.writeChunk(q.tail[:n])
+	if err != nil {
+		if !q.spillFailed {
+			logger.Warnf("Error spilling buffered heartbeats to disk, keeping them in memory: %v", err)
+		}
+		q.spillFailed = true
+		return
+	}
+	q.spillFailed = false
+	q.chunks = append(q.chunks, chunk{path: path, entries: n})
+	q.tail = append([]queued(nil), q.tail[n:]...)
+}
+
+// trim drops the oldest heartbeats until the queue fits in max
+func (q *Queue) trim() {
+	if q.size > q.max && q.dropped == 0 {
+		logger.Warnf("Heartbeat buffer is full at %d entries, dropping the oldest", q.max)
+	}
+	for q.size > q.max {
+		switch {
+		case len(q.head) > 0:
+			q.head = q.head[1:]
+		case len(q.chunks) > 0:
+			// a whole chunk is dropped at once when it fits in the overflow
+			if c := q.chunks[0]; c.entries <= q.size-q.max {
+				q.removeChunk()
+				q.dropped += c.entries
+				continue
+			}
+			q.load()
+			continue
+		default:
+			q.tail = q.tail[1:]
+		}
+		q.size--
+		q.dropped++
+	}
+}
+
+// load reads the oldest chunk into head and deletes its file
+func (q *Queue) load() {
+	c := q.chunks[0]
+	entries, err := readChunk(c.path)
+	if err != nil {
+		logger.Warnf("Error reading buffered heartbeats from %s, %d of %d read: %v", c.path, len(entries), c.entries, err)
+	}
+	q.removeChunk()
+	q.head = entries
+	q.size += len(entries)
+}
+
+// removeChunk forgets the oldest chunk and deletes its file
+func (q *Queue) removeChunk() {
+	c := q.chunks[0]
+	q.chunks = q.chunks[1:]
+	q.size -= c.entries
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warnf("Error removing %s: %v", c.path, err)
+	}
+}
+
+// A chunk is named <first collection time in unix nanos>-<first seq>-<entries>
+// and holds one record per heartbeat: the collection time in unix nanos as 8
+// big endian bytes, then the size delimited request.
+func (q *Queue) writeChunk(entries []queued) (string, error) {
+	name := fmt.Sprintf("%019d-%d-%d%s", entries[0].collected.UnixNano(), entries[0].seq, len(entries), chunkSuffix)
+	path := filepath.Join(q.dir, name)
+
+	f, err := os.CreateTemp(q.dir, name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	for _, e := range entries {
+		var ts [8]byte
+		binary.BigEndian.PutUint64(ts[:], uint64(e.collected.UnixNano()))
+		w.Write(ts[:])
+		if _, err := protodelim.MarshalTo(w, e.req); err != nil {
+			f.Close()
+			return "", err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(f.Name(), path)
+}
+
+func readChunk(path string) ([]queued, error) {
+	_, seq, _ := parseChunkName(filepath.Base(path))
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var entries []queued
+	for {
+		var ts [8]byte
+		if _, err := io.ReadFull(r, ts[:]); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return entries, err
+		}
+		req := &pb.HeartbeatRequest{}
+		if err := protodelim.UnmarshalFrom(r, req); err != nil {
+			return entries, err
+		}
+		entries = append(entries, queued{
+			seq:       seq,
+			collected: time.Unix(0, int64(binary.BigEndian.Uint64(ts[:]))),
+			req:       req,
+		})
+		seq++
+	}
+}
+
+// parseChunkName returns the number of entries and the first seq of a chunk
+func parseChunkName(name string) (int, uint64, bool) {
+	base, ok := strings.CutSuffix(name, chunkSuffix)
+	if !ok {
+		return 0, 0, false
+	}
+	parts := strings.Split(base, "-")
+	if len(parts) != 3 {
+		return 0, 0, false
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	n, err := strconv.Atoi(parts[2])
+	if err != nil || n <= 0 {
+		return 0, 0, false
+	}
+	return n, seq, true
+}
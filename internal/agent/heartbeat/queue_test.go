@@ -0,0 +1,237 @@
+package heartbeat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestQueue(t *testing.T, dir string, memory, max int) *Queue {
+	t.Helper()
+	q, err := NewQueue(config.BufferConfig{Directory: dir, MemoryEntries: memory, MaxEntries: max})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// push queues heartbeats from, from+1, ... to-1, told apart by their hostname
+func push(q *Queue, from, to int) {
+	for i := from; i < to; i++ {
+		q.Push(&pb.HeartbeatRequest{Hostname: fmt.Sprintf("h%d", i)}, start.Add(time.Duration(i)*time.Second))
+	}
+}
+
+// drain pops everything and returns the hostnames in the order they came out
+func drain(t *testing.T, q *Queue) []string {
+	t.Helper()
+	var got []string
+	for {
+		e, ok := q.Peek()
+		if !ok {
+			break
+		}
+		got = append(got, e.req.Hostname)
+		q.Pop(e)
+		if len(got) > 1000 {
+			t.Fatal("queue does not drain")
+		}
+	}
+	return got
+}
+
+func hostnames(from, to int) []string {
+	var names []string
+	for i := from; i < to; i++ {
+		names = append(names, fmt.Sprintf("h%d", i))
+	}
+	return names
+}
+
+func chunkFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+chunkSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func assertOrder(t *testing.T, got, want []string) {
+	t.Helper()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v,\nwant %v", got, want)
+	}
+}
+
+func TestQueueSpillAndLoadInOrder(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t, dir, 4, 100)
+	push(q, 0, 10)
+
+	if files := chunkFiles(t, dir); len(files) == 0 {
+		t.Fatal("nothing was spilled past the memory limit")
+	}
+	if q.Len() != 10 {
+		t.Errorf("Len = %d, want 10", q.Len())
+	}
+	// pushing while draining keeps the order too
+	var got []string
+	for i := 0; i < 3; i++ {
+		e, _ := q.Peek()
+		got = append(got, e.req.Hostname)
+		q.Pop(e)
+	}
+	push(q, 10, 15)
+	got = append(got, drain(t, q)...)
+
+	assertOrder(t, got, hostnames(0, 15))
+	if files := chunkFiles(t, dir); len(files) != 0 {
+		t.Errorf("chunks left after draining: %v", files)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len = %d after draining", q.Len())
+	}
+}
+
+func TestQueuePopAfterSpill(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t, dir, 4, 100)
+	push(q, 0, 4)
+
+	// the peeked heartbeat is in memory, then spilled before it is popped
+	e, _ := q.Peek()
+	push(q, 4, 5)
+	if len(chunkFiles(t, dir)) != 1 {
+		t.Fatal("want the oldest heartbeats spilled")
+	}
+	q.Pop(e)
+
+	assertOrder(t, drain(t, q), hostnames(1, 5))
+}
+
+func TestQueuePopAfterDrop(t *testing.T) {
+	q := newTestQueue(t, t.TempDir(), 10, 3)
+	push(q, 0, 3)
+
+	// the peeked heartbeat is dropped to make room, so Pop leaves the new first one alone
+	e, _ := q.Peek()
+	push(q, 3, 4)
+	q.Pop(e)
+
+	assertOrder(t, drain(t, q), hostnames(1, 4))
+}
+
+func TestQueueConcurrentPushAndPop(t *testing.T) {
+	q := newTestQueue(t, t.TempDir(), 4, 1000)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		push(q, 0, 200)
+	}()
+
+	var got []string
+	for len(got) < 200 {
+		e, ok := q.Peek()
+		if !ok {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		got = append(got, e.req.Hostname)
+		q.Pop(e)
+	}
+	wg.Wait()
+
+	assertOrder(t, got, hostnames(0, 200))
+}
+
+func TestQueueTrimDropsWholeChunks(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t, dir, 4, 10)
+	push(q, 0, 30)
+
+	if q.Len() != 10 {
+		t.Errorf("Len = %d, want the max of 10", q.Len())
+	}
+	// trim removes chunk files without reading them back
+	for _, f := range chunkFiles(t, dir) {
+		n, seq, _ := parseChunkName(filepath.Base(f))
+		if seq+uint64(n) <= 20 {
+			t.Errorf("chunk %s holds only dropped heartbeats", f)
+		}
+	}
+	assertOrder(t, drain(t, q), hostnames(20, 30))
+}
+
+func TestQueueRecoversChunksAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t, dir, 4, 100)
+	push(q, 0, 10)
+	spilled := 0
+	for _, f := range chunkFiles(t, dir) {
+		n, _, _ := parseChunkName(filepath.Base(f))
+		spilled += n
+	}
+	// a temporary file of a spill cut short is not a chunk
+	os.WriteFile(filepath.Join(dir, "1-2-3.chunk.123.tmp"), []byte("junk"), 0600)
+
+	// what was only in memory is lost with the old queue
+	q = newTestQueue(t, dir, 4, 100)
+	if q.Len() != spilled {
+		t.Fatalf("Len = %d after the restart, want the %d spilled", q.Len(), spilled)
+	}
+	e, _ := q.Peek()
+	if !e.collected.Equal(start) {
+		t.Errorf("collected = %s, want %s", e.collected, start)
+	}
+	// new heartbeats go after the recovered ones
+	push(q, 100, 102)
+	assertOrder(t, drain(t, q), append(hostnames(0, spilled), hostnames(100, 102)...))
+}
+
+func TestQueueSkipsUnreadableChunk(t *testing.T) {
+	dir := t.TempDir()
+	q := newTestQueue(t, dir, 4, 100)
+	push(q, 0, 10)
+	files := chunkFiles(t, dir)
+	if len(files) < 2 {
+		t.Fatalf("want at least two chunks, got %v", files)
+	}
+	n, _, _ := parseChunkName(filepath.Base(files[0]))
+	os.WriteFile(files[0], []byte("not a chunk"), 0600)
+
+	assertOrder(t, drain(t, q), hostnames(n, 10))
+}
+
+func TestParseChunkName(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		seq  uint64
+		ok   bool
+	}{
+		{"0001714564800000000000-7-3.chunk", 3, 7, true},
+		{"0001714564800000000000-7-0.chunk", 0, 0, false},
+		{"0001714564800000000000-x-3.chunk", 0, 0, false},
+		{"0001714564800000000000-7-3.chunk.1.tmp", 0, 0, false},
+		{"agent-id", 0, 0, false},
+	}
+	for _, tt := range tests {
+		n, seq, ok := parseChunkName(tt.name)
+		if n != tt.n || seq != tt.seq || ok != tt.ok {
+			t.Errorf("parseChunkName(%q) = %d, %d, %v, want %d, %d, %v", tt.name, n, seq, ok, tt.n, tt.seq, tt.ok)
+		}
+	}
+}
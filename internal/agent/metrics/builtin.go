@@ -0,0 +1,285 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+// collectorFunc turns a function that fills AgentMetrics fields into a Collector
+type collectorFunc struct {
+	name    string
+	fields  []string
+	collect func(ctx context.Context, m *pb.AgentMetrics) error
+}
+
+func (c collectorFunc) Name() string     { return c.name }
+func (c collectorFunc) Fields() []string { return c.fields }
+
+func (c collectorFunc) Collect(ctx context.Context, out *Output) error {
+	return c.collect(ctx, out.Metrics)
+}
+
+type builtin struct {
+	collector Collector
+	schedule  Schedule
+}
+
+// builtinCollectors configures the collectors that ship with the agent and
+// the exec commands. The ones with a section of their own are enabled by it.
+func builtinCollectors(cfg *config.AgentConfig) []builtin {
+	SetFilesystemFilter(cfg.Filesystems)
+	SetNetworkFilter(cfg.Network)
+	SetDiskIOFilter(cfg.DiskIO)
+	SetProcessesConfig(cfg.Processes)
+	SetSensorsConfig(cfg.Sensors)
+	SetContainersConfig(cfg.Containers)
+	SetSystemdConfig(cfg.Systemd)
+
+	always := Schedule{Enabled: true}
+	list := []builtin{
+		{collectorFunc{"cpu", []string{"cpu_usage", "cpu_per_core", "cpu_user", "cpu_system", "cpu_iowait", "cpu_steal", "cpu_count"}, collectCPU}, always},
+		{collectorFunc{"load", []string{"load1", "load5", "load15"}, collectLoad}, always},
+		{collectorFunc{"memory", []string{"memory_usage", "mem_total", "mem_used", "mem_available", "mem_buffers", "mem_cached", "swap_total", "swap_used", "swap_in", "swap_out"}, collectMemory}, always},
+		{collectorFunc{"disk", []string{"disk_usage"}, collectDisk}, always},
+		{collectorFunc{"network", []string{"network_upload", "network_download"}, collectNetwork}, always},
+		{collectorFunc{"diskio", []string{"disk_read", "disk_write"}, collectDiskIO}, always},
+		{collectorFunc{"uptime", []string{"uptime"}, collectUptime}, always},
+		{collectorFunc{"sensors", []string{"cpu_temp", "temperatures", "fans"}, collectSensors}, always},
+		{collectorFunc{"filesystems", []string{"filesystems"}, collectFilesystems}, always},
+		{collectorFunc{"interfaces", []string{"network_interfaces"}, collectInterfaces}, always},
+		{collectorFunc{"disks", []string{"disk_devices"}, collectDiskDevices}, always},
+		{collectorFunc{"processes", []string{"top_cpu", "top_memory"}, collectProcesses}, Schedule{Enabled: cfg.Processes.Enabled}},
+		{collectorFunc{"containers", []string{"containers"}, collectContainers}, Schedule{Enabled: cfg.Containers.Enabled, Interval: 10 * time.Second, Timeout: 5 * time.Second}},
+		{collectorFunc{"systemd", []string{"systemd_state", "failed_units", "watched_units"}, collectSystemd}, Schedule{Enabled: cfg.Systemd.Enabled, Interval: 10 * time.Second, Timeout: 5 * time.Second}},
+		{&textfileCollector{cfg.Textfile.Directory}, Schedule{Enabled: cfg.Textfile.Directory != "", Interval: 10 * time.Second, Timeout: 5 * time.Second}},
+	}
+	return append(list, execCollectors(cfg.Exec)...)
+}
+
+func collectCPU(_ context.Context, m *pb.AgentMetrics) error {
+	stats, err := GetCPUStats()
+	if err != nil {
+		return err
+	}
+	m.CpuUsage = int64(stats.Usage)
+	m.CpuPerCore = stats.PerCore
+	m.CpuUser = stats.User
+	m.CpuSystem = stats.System
+	m.CpuIowait = stats.IOWait
+	m.CpuSteal = stats.Steal
+	m.CpuCount = stats.Count
+	return nil
+}
+
+func collectLoad(_ context.Context, m *pb.AgentMetrics) error {
+	avg, err := GetLoadAverage()
+	if err != nil {
+		return err
+	}
+	m.Load1, m.Load5, m.Load15 = avg.Load1, avg.Load5, avg.Load15
+	return nil
+}
+
+func collectMemory(_ context.Context, m *pb.AgentMetrics) error {
+	stats, err := GetMemoryStats()
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return err
+	}
+	m.MemoryUsage = int64(stats.UsedPercent)
+	m.MemTotal = stats.Total
+	m.MemUsed = stats.Used
+	m.MemAvailable = stats.Available
+	m.MemBuffers = stats.Buffers
+	m.MemCached = stats.Cached
+	m.SwapTotal = stats.SwapTotal
+	m.SwapUsed = stats.SwapUsed
+	m.SwapIn = stats.SwapIn
+	m.SwapOut = stats.SwapOut
+	return err
+}
+
+func collectDisk(_ context.Context, m *pb.AgentMetrics) error {
+	usage, err := GetDiskUsage()
+	m.DiskUsage = usage
+	return err
+}
+
+func collectNetwork(_ context.Context, m *pb.AgentMetrics) error {
+	up, down, err := GetNetworkUsage()
+	m.NetworkUpload, m.NetworkDownload = up, down
+	return err
+}
+
+func collectDiskIO(_ context.Context, m *pb.AgentMetrics) error {
+	read, write, err := GetDiskIO()
+	m.DiskRead, m.DiskWrite = read, write
+	return err
+}
+
+func collectUptime(_ context.Context, m *pb.AgentMetrics) error {
+	uptime, err := GetHostUptime()
+	m.Uptime = uptime
+	return err
+}
+
+// collectSensors reports cpu_temp unavailable on machines without temperature
+// sensors, like most VMs, rather than a temperature of 0
+func collectSensors(_ context.Context, m *pb.AgentMetrics) error {
+	temps, err := GetTemperatures()
+	if err != nil {
+		return err
+	}
+	for _, s := range temps {
+		m.Temperatures = append(m.Temperatures, &pb.Sensor{Key: s.Key, Name: s.Name, Celsius: s.Celsius})
+	}
+	for _, f := range GetFans() {
+		m.Fans = append(m.Fans, &pb.Fan{Key: f.Key, Name: f.Name, Rpm: f.RPM})
+	}
+	if len(temps) == 0 {
+		return &PartialError{Fields: []string{"cpu_temp"}, Err: errors.New("no temperature sensors")}
+	}
+	m.CpuTemp = GetCPUTemperature(temps)
+	return nil
+}
+
+func collectFilesystems(_ context.Context, m *pb.AgentMetrics) error {
+	filesystems, err := GetFilesystems()
+	if err != nil {
+		return err
+	}
+	for _, fs := range filesystems {
+		m.Filesystems = append(m.Filesystems, &pb.Filesystem{
+			Mountpoint:  fs.Mountpoint,
+			Device:      fs.Device,
+			Fstype:      fs.FSType,
+			TotalBytes:  fs.TotalBytes,
+			UsedBytes:   fs.UsedBytes,
+			FreeBytes:   fs.FreeBytes,
+			InodesTotal: fs.InodesTotal,
+			InodesUsed:  fs.InodesUsed,
+			InodesFree:  fs.InodesFree,
+		})
+	}
+	return nil
+}
+
+func collectInterfaces(_ context.Context, m *pb.AgentMetrics) error {
+	interfaces, err := GetNetworkInterfaces()
+	if err != nil {
+		return err
+	}
+	for _, nic := range interfaces {
+		m.NetworkInterfaces = append(m.NetworkInterfaces, &pb.NetworkInterface{
+			Name:      nic.Name,
+			RxBytes:   nic.RxBytes,
+			TxBytes:   nic.TxBytes,
+			RxPackets: nic.RxPackets,
+			TxPackets: nic.TxPackets,
+			RxErrors:  nic.RxErrors,
+			TxErrors:  nic.TxErrors,
+			RxDropped: nic.RxDropped,
+			TxDropped: nic.TxDropped,
+		})
+	}
+	return nil
+}
+
+func collectDiskDevices(_ context.Context, m *pb.AgentMetrics) error {
+	devices, err := GetDiskDevices()
+	if err != nil {
+		return err
+	}
+	for _, d := range devices {
+		m.DiskDevices = append(m.DiskDevices, &pb.DiskDevice{
+			Name:       d.Name,
+			ReadBytes:  d.ReadBytes,
+			WriteBytes: d.WriteBytes,
+			Reads:      d.Reads,
+			Writes:     d.Writes,
+			Busy:       d.Busy,
+		})
+	}
+	return nil
+}
+
+func collectProcesses(_ context.Context, m *pb.AgentMetrics) error {
+	byCPU, byMemory, err := GetTopProcesses()
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return err
+	}
+	m.TopCpu = processList(byCPU)
+	m.TopMemory = processList(byMemory)
+	return err
+}
+
+func collectContainers(ctx context.Context, m *pb.AgentMetrics) error {
+	containers, err := GetContainers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		m.Containers = append(m.Containers, &pb.Container{
+			Id:              c.ID,
+			Name:            c.Name,
+			Image:           c.Image,
+			State:           c.State,
+			Status:          c.Status,
+			CpuPercent:      c.CPUPercent,
+			MemoryBytes:     c.MemoryBytes,
+			MemoryLimit:     c.MemoryLimit,
+			NetRxBytes:      c.NetRxBytes,
+			NetTxBytes:      c.NetTxBytes,
+			BlockReadBytes:  c.BlockReadBytes,
+			BlockWriteBytes: c.BlockWriteBytes,
+			RestartCount:    c.RestartCount,
+		})
+	}
+	return nil
+}
+
+func collectSystemd(ctx context.Context, m *pb.AgentMetrics) error {
+	status, err := GetSystemdStatus(ctx)
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return err
+	}
+	m.SystemdState = status.State
+	m.FailedUnits = unitList(status.Failed)
+	m.WatchedUnits = unitList(status.Watched)
+	return err
+}
+
+func processList(procs []Process) []*pb.Process {
+	list := make([]*pb.Process, 0, len(procs))
+	for _, p := range procs {
+		list = append(list, &pb.Process{
+			Pid:        p.PID,
+			Name:       p.Name,
+			User:       p.User,
+			Cmdline:    p.Cmdline,
+			CpuPercent: p.CPUPercent,
+			Rss:        p.RSS,
+		})
+	}
+	return list
+}
+
+func unitList(units []SystemdUnit) []*pb.SystemdUnit {
+	list := make([]*pb.SystemdUnit, 0, len(units))
+	for _, u := range units {
+		list = append(list, &pb.SystemdUnit{
+			Name:        u.Name,
+			LoadState:   u.LoadState,
+			ActiveState: u.ActiveState,
+			SubState:    u.SubState,
+			Description: u.Description,
+		})
+	}
+	return list
+}
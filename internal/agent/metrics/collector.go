@@ -0,0 +1,247 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// Collector gathers one group of metrics. Collect fills the Output it is
+// given, which starts out empty, and the registry merges the outputs of all
+// collectors into the heartbeat. Fields names the AgentMetrics fields the
+// collector fills, they are reported unavailable when it fails.
+type Collector interface {
+	Name() string
+	Fields() []string
+	Collect(ctx context.Context, out *Output) error
+}
+
+// Output is what one collector run found: values for AgentMetrics fields, and
+// samples for metrics AgentMetrics has no field for
+type Output struct {
+	Metrics *pb.AgentMetrics
+	Samples []*pb.Sample
+}
+
+// AddSample appends a sample. labels may be nil.
+func (o *Output) AddSample(name string, labels map[string]string, value float64, unit string, typ pb.SampleType) {
+	o.Samples = append(o.Samples, &pb.Sample{Name: name, Labels: labels, Value: value, Unit: unit, Type: typ})
+}
+
+// PartialError is returned by a collector that got some of its fields but not
+// all. The ones in Fields are reported unavailable, the rest is kept.
+type PartialError struct {
+	Fields []string
+	Err    error
+}
+
+func (e *PartialError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// Schedule is how a collector runs unless the collectors config says otherwise
+type Schedule struct {
+	Enabled  bool
+	Interval time.Duration // 0 runs with every heartbeat
+	Timeout  time.Duration
+}
+
+const defaultCollectorTimeout = 2 * time.Second
+
+// Registry runs the enabled collectors and puts their results together
+type Registry struct {
+	overrides map[string]config.CollectorConfig
+	entries   []*collectorEntry
+	names     map[string]bool
+}
+
+// collectorEntry is a registered collector and what its last run produced
+type collectorEntry struct {
+	collector Collector
+	interval  time.Duration
+	timeout   time.Duration
+
+	mu      sync.Mutex
+	running bool // a run that timed out can still be going
+	lastRun time.Time
+	last    *Output
+	lastErr error
+}
+
+// NewRegistry sets up the built-in collectors from the agent config. Names in
+// the collectors config that match no collector are an error.
+func NewRegistry(cfg *config.AgentConfig) (*Registry, error) {
+	r := &Registry{overrides: cfg.Collectors, names: make(map[string]bool)}
+	for _, b := range builtinCollectors(cfg) {
+		if err := r.Register(b.collector, b.schedule); err != nil {
+			return nil, err
+		}
+	}
+	for name := range cfg.Collectors {
+		if !r.names[name] {
+			return nil, fmt.Errorf("unknown collector %q in collectors config", name)
+		}
+	}
+	return r, nil
+}
+
+// Register adds a collector. The collectors config, when it names the
+// collector, wins over the given schedule. Disabled collectors are known to
+// the registry but never run.
+func (r *Registry) Register(c Collector, s Schedule) error {
+	name := c.Name()
+	if r.names[name] {
+		return fmt.Errorf("collector %q registered twice", name)
+	}
+	r.names[name] = true
+
+	if o, ok := r.overrides[name]; ok {
+		if o.Enabled != nil {
+			s.Enabled = *o.Enabled
+		}
+		if o.Interval > 0 {
+			s.Interval = o.Interval
+		}
+		if o.Timeout > 0 {
+			s.Timeout = o.Timeout
+		}
+	}
+	if !s.Enabled {
+		return nil
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = defaultCollectorTimeout
+	}
+	r.entries = append(r.entries, &collectorEntry{collector: c, interval: s.Interval, timeout: s.Timeout})
+	return nil
+}
+
+// Enabled lists the collectors that run, sorted by name
+func (r *Registry) Enabled() []string {
+	names := make([]string, 0, len(r.entries))
+	for _, e := range r.entries {
+		names = append(names, e.collector.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Collect runs the collectors that are due, in parallel, and merges the latest
+// output of every collector into one AgentMetrics and one list of samples. A
+// collector that failed or did not finish within its timeout is listed in
+// Unavailable, with its fields left at zero and its samples left out.
+func (r *Registry) Collect(ctx context.Context) (*pb.AgentMetrics, []*pb.Sample) {
+	now := time.Now()
+	var wg sync.WaitGroup
+	for _, e := range r.entries {
+		if !e.due(now) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	out := &Output{Metrics: &pb.AgentMetrics{}}
+	for _, e := range r.entries {
+		e.mergeInto(out)
+	}
+	return out.Metrics, out.Samples
+}
+
+// due reports whether the collector should run now and marks it as running
+func (e *collectorEntry) due(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running || (!e.lastRun.IsZero() && now.Sub(e.lastRun) < e.interval) {
+		return false
+	}
+	e.running = true
+	e.lastRun = now
+	return true
+}
+
+func (e *collectorEntry) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	type result struct {
+		out *Output
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer cancel()
+		out := &Output{Metrics: &pb.AgentMetrics{}}
+		err := e.collector.Collect(ctx, out)
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+		done <- result{out, err}
+	}()
+
+	select {
+	case res := <-done:
+		e.finish(res.out, res.err)
+	case <-ctx.Done():
+		// the collector keeps going in the background, it is not run again until it returns
+		e.finish(nil, fmt.Errorf("timed out after %s", e.timeout))
+	}
+}
+
+// finish records the result of a run, logging when the collector starts or
+// stops failing rather than on every heartbeat
+func (e *collectorEntry) finish(out *Output, err error) {
+	e.mu.Lock()
+	// the first run of a rate collector only sets the baseline, that is no failure to recover from
+	wasFailing := e.lastErr != nil && !errors.Is(e.lastErr, errNoBaseline)
+	e.last, e.lastErr = out, err
+	e.mu.Unlock()
+
+	name := e.collector.Name()
+	switch {
+	case errors.Is(err, errNoBaseline):
+		logger.Debugf("Collector %s: %v", name, err)
+	case err != nil && !wasFailing:
+		logger.Warnf("Collector %s is unavailable: %v", name, err)
+	case err == nil && wasFailing:
+		logger.Infof("Collector %s is available again", name)
+	}
+}
+
+func (e *collectorEntry) mergeInto(out *Output) {
+	e.mu.Lock()
+	last, err := e.last, e.lastErr
+	e.mu.Unlock()
+
+	if err == nil {
+		proto.Merge(out.Metrics, last.Metrics)
+		out.Samples = append(out.Samples, last.Samples...)
+		return
+	}
+	fields := e.collector.Fields()
+	var partial *PartialError
+	if errors.As(err, &partial) && last != nil {
+		proto.Merge(out.Metrics, last.Metrics)
+		out.Samples = append(out.Samples, last.Samples...)
+		fields = partial.Fields
+	}
+	out.Metrics.Unavailable = append(out.Metrics.Unavailable, &pb.UnavailableCollector{
+		Name:   e.collector.Name(),
+		Error:  err.Error(),
+		Fields: fields,
+	})
+}
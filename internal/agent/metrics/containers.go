@@ -0,0 +1,229 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+)
+
+// Container is one container of the local engine. Rates are per second since
+// the previous time the engine was asked.
+type Container struct {
+	ID              string
+	Name            string
+	Image           string
+	State           string
+	Status          string
+	CPUPercent      float64 // of one core
+	MemoryBytes     uint64  // without page cache
+	MemoryLimit     uint64
+	NetRxBytes      float64
+	NetTxBytes      float64
+	BlockReadBytes  float64
+	BlockWriteBytes float64
+	RestartCount    int32
+}
+
+// containerCounters are the cumulative numbers the rates are worked out from
+type containerCounters struct {
+	at                    time.Time
+	cpu                   uint64 // nanoseconds
+	rx, tx, read, written uint64
+}
+
+var (
+	containerClient *http.Client
+	containerPrev   = make(map[string]containerCounters)
+	containerPrevMu sync.Mutex // containers are described in parallel
+)
+
+// SetContainersConfig points the container collection at the engine socket
+func SetContainersConfig(cfg config.ContainersConfig) {
+	containerClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", cfg.Socket)
+			},
+		},
+	}
+}
+
+// GetContainers lists the containers of the local Docker or Podman engine,
+// running or not
+func GetContainers(ctx context.Context) ([]Container, error) {
+	containers, err := listContainers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %v", err)
+	}
+	return containers, nil
+}
+
+// engineContainer is the part of GET /containers/json we use
+type engineContainer struct {
+	ID     string   `json:"Id"`
+	Names  []string `json:"Names"`
+	Image  string   `json:"Image"`
+	State  string   `json:"State"`
+	Status string   `json:"Status"`
+}
+
+// engineStats is the part of GET /containers/{id}/stats we use
+type engineStats struct {
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage uint64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+}
+
+func listContainers(ctx context.Context) ([]Container, error) {
+	var list []engineContainer
+	if err := engineGet(ctx, "/containers/json?all=true", &list); err != nil {
+		return nil, err
+	}
+
+	containers := make([]Container, len(list))
+	var wg sync.WaitGroup
+	for i, ec := range list {
+		containers[i] = Container{
+			ID:     ec.ID,
+			Name:   strings.TrimPrefix(firstOr(ec.Names, ec.ID), "/"),
+			Image:  ec.Image,
+			State:  ec.State,
+			Status: ec.Status,
+		}
+		wg.Add(1)
+		go func(c *Container) {
+			defer wg.Done()
+			describeContainer(ctx, c)
+		}(&containers[i])
+	}
+	wg.Wait()
+
+	// forget containers that are gone
+	seen := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		seen[c.ID] = true
+	}
+	for id := range containerPrev {
+		if !seen[id] {
+			delete(containerPrev, id)
+		}
+	}
+
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	return containers, nil
+}
+
+// describeContainer adds the restart count and, for running containers, the resource usage
+func describeContainer(ctx context.Context, c *Container) {
+	var inspect struct {
+		RestartCount int32 `json:"RestartCount"`
+	}
+	if err := engineGet(ctx, "/containers/"+url.PathEscape(c.ID)+"/json", &inspect); err != nil {
+		logger.Debugf("Error inspecting container %s: %v", c.Name, err)
+	}
+	c.RestartCount = inspect.RestartCount
+
+	if c.State != "running" {
+		return
+	}
+	var stats engineStats
+	if err := engineGet(ctx, "/containers/"+url.PathEscape(c.ID)+"/stats?stream=false&one-shot=true", &stats); err != nil {
+		logger.Debugf("Error getting stats of container %s: %v", c.Name, err)
+		return
+	}
+
+	// like docker stats, page cache does not count as used memory.
+	// cgroup v1 calls it cache, v2 inactive_file
+	c.MemoryLimit = stats.MemoryStats.Limit
+	cache := stats.MemoryStats.Stats["inactive_file"]
+	if v, ok := stats.MemoryStats.Stats["cache"]; ok {
+		cache = v
+	}
+	if stats.MemoryStats.Usage > cache {
+		c.MemoryBytes = stats.MemoryStats.Usage - cache
+	}
+
+	cur := containerCounters{at: time.Now(), cpu: stats.CPUStats.CPUUsage.TotalUsage}
+	for _, n := range stats.Networks {
+		cur.rx += n.RxBytes
+		cur.tx += n.TxBytes
+	}
+	for _, io := range stats.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(io.Op) {
+		case "read":
+			cur.read += io.Value
+		case "write":
+			cur.written += io.Value
+		}
+	}
+
+	containerPrevMu.Lock()
+	prev, ok := containerPrev[c.ID]
+	containerPrev[c.ID] = cur
+	containerPrevMu.Unlock()
+	if !ok {
+		return
+	}
+	elapsed := cur.at.Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	c.CPUPercent = rate(cur.cpu, prev.cpu, elapsed) / 1e9 * 100
+	c.NetRxBytes = rate(cur.rx, prev.rx, elapsed)
+	c.NetTxBytes = rate(cur.tx, prev.tx, elapsed)
+	c.BlockReadBytes = rate(cur.read, prev.read, elapsed)
+	c.BlockWriteBytes = rate(cur.written, prev.written, elapsed)
+}
+
+// engineGet calls the engine API and decodes the JSON answer into v. The host
+// part of the URL is ignored, the transport always dials the socket.
+func engineGet(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://engine"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := containerClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func firstOr(list []string, fallback string) string {
+	if len(list) > 0 {
+		return list[0]
+	}
+	return fallback
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+)
+
+// fakeEngine serves the parts of the Docker API the collector uses on a unix
+// socket and points the collector at it
+func fakeEngine(t *testing.T, handler http.Handler) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "engine.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Close() })
+
+	SetContainersConfig(config.ContainersConfig{Enabled: true, Socket: socket})
+	containerPrev = make(map[string]containerCounters)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func engineHandler(stats map[string]any) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("all") != "true" {
+			http.Error(w, "want all=true", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, []map[string]any{
+			{"Id": "web1", "Names": []string{"/web"}, "Image": "nginx:1.27", "State": "running", "Status": "Up 2 hours"},
+			{"Id": "db1", "Names": []string{"/db"}, "Image": "postgres:16", "State": "running", "Status": "Up 2 hours"},
+			{"Id": "job1", "Names": []string{"/backup"}, "Image": "restic", "State": "exited", "Status": "Exited (0) 5 minutes ago"},
+		})
+	})
+	mux.HandleFunc("GET /containers/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		restarts := map[string]int{"web1": 3, "db1": 0, "job1": 1}
+		writeJSON(w, map[string]any{"RestartCount": restarts[r.PathValue("id")]})
+	})
+	mux.HandleFunc("GET /containers/{id}/stats", func(w http.ResponseWriter, r *http.Request) {
+		s, ok := stats[r.PathValue("id")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, s)
+	})
+	return mux
+}
+
+func engineStatsJSON(cpu, usage uint64, memStats map[string]uint64, rx, tx, read, written uint64) map[string]any {
+	return map[string]any{
+		"cpu_stats":    map[string]any{"cpu_usage": map[string]any{"total_usage": cpu}},
+		"memory_stats": map[string]any{"usage": usage, "limit": uint64(1 << 30), "stats": memStats},
+		"networks": map[string]any{
+			"eth0": map[string]any{"rx_bytes": rx / 2, "tx_bytes": tx},
+			"eth1": map[string]any{"rx_bytes": rx - rx/2, "tx_bytes": 0},
+		},
+		"blkio_stats": map[string]any{"io_service_bytes_recursive": []map[string]any{
+			{"op": "Read", "value": read},
+			{"op": "Write", "value": written},
+			{"op": "Total", "value": read + written},
+		}},
+	}
+}
+
+func approx(got, want float64) bool {
+	return math.Abs(got-want) <= want*0.01
+}
+
+func TestGetContainers(t *testing.T) {
+	fakeEngine(t, engineHandler(map[string]any{
+		// cgroup v2 reports the page cache as inactive_file
+		"web1": engineStatsJSON(15e9, 300<<20, map[string]uint64{"inactive_file": 100 << 20}, 20000, 5000, 8000, 4000),
+		// cgroup v1 calls it cache, which wins over inactive_file
+		"db1": engineStatsJSON(1e9, 500<<20, map[string]uint64{"cache": 200 << 20, "inactive_file": 50 << 20}, 0, 0, 0, 0),
+	}))
+	// the previous counters of web1 are from 10 seconds ago, so rates are per
+	// second over 10s. A container that is gone is forgotten.
+	containerPrev["web1"] = containerCounters{at: time.Now().Add(-10 * time.Second), cpu: 10e9, rx: 10000, tx: 4000, read: 3000, written: 2000}
+	containerPrev["gone"] = containerCounters{at: time.Now()}
+
+	containers, err := GetContainers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 3 {
+		t.Fatalf("got %d containers, want 3", len(containers))
+	}
+	// sorted by name
+	backup, db, web := containers[0], containers[1], containers[2]
+	if backup.Name != "backup" || db.Name != "db" || web.Name != "web" {
+		t.Fatalf("names = %s, %s, %s", backup.Name, db.Name, web.Name)
+	}
+
+	if web.Image != "nginx:1.27" || web.State != "running" || web.Status != "Up 2 hours" || web.RestartCount != 3 {
+		t.Errorf("web = %+v", web)
+	}
+	if web.MemoryBytes != 200<<20 || web.MemoryLimit != 1<<30 {
+		t.Errorf("web memory = %d of %d, want %d of %d", web.MemoryBytes, web.MemoryLimit, 200<<20, 1<<30)
+	}
+	// 5s of CPU time over 10s is half a core
+	for _, r := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"cpu", web.CPUPercent, 50},
+		{"rx", web.NetRxBytes, 1000},
+		{"tx", web.NetTxBytes, 100},
+		{"read", web.BlockReadBytes, 500},
+		{"write", web.BlockWriteBytes, 200},
+	} {
+		if !approx(r.got, r.want) {
+			t.Errorf("web %s rate = %g, want about %g", r.name, r.got, r.want)
+		}
+	}
+
+	if db.MemoryBytes != 300<<20 {
+		t.Errorf("db memory = %d, want %d without the v1 cache", db.MemoryBytes, 300<<20)
+	}
+	// no previous counters yet, so no rates
+	if db.CPUPercent != 0 || db.NetRxBytes != 0 {
+		t.Errorf("db has rates on its first sample: %+v", db)
+	}
+
+	if backup.RestartCount != 1 || backup.MemoryBytes != 0 || backup.CPUPercent != 0 {
+		t.Errorf("stopped container has usage: %+v", backup)
+	}
+
+	if _, ok := containerPrev["gone"]; ok {
+		t.Error("counters of a removed container were kept")
+	}
+	if _, ok := containerPrev["job1"]; ok {
+		t.Error("counters were kept for a stopped container")
+	}
+	if len(containerPrev) != 2 {
+		t.Errorf("kept counters for %d containers, want 2", len(containerPrev))
+	}
+}
+
+func TestGetContainersEngineError(t *testing.T) {
+	fakeEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "engine is down", http.StatusInternalServerError)
+	}))
+	if _, err := GetContainers(context.Background()); err == nil {
+		t.Error("GetContainers did not fail when listing failed")
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+const (
+	maxExecStdout = 1 << 20 // more output is cut off and reported
+	maxExecStderr = 1 << 10 // the end of stderr kept in the check result
+
+	defaultExecInterval = time.Minute
+	defaultExecTimeout  = 10 * time.Second
+
+	// how long a killed command may take to go away. The collector's own timeout
+	// is this much longer than the command's, so the check result of a killed
+	// command is still reported.
+	execKillGrace = 2 * time.Second
+)
+
+// execCollector runs one configured command. Its stdout becomes samples, how
+// it ended becomes a check result. A command that cannot be started makes the
+// collector unavailable, one killed for running past its timeout too, but with
+// a check result with exit code -1. One that exits with an error is a failed
+// check and its output is still read.
+type execCollector struct {
+	cmd     config.ExecCommand
+	timeout time.Duration
+	slots   chan struct{} // shared by all exec collectors, holds one token per running command
+}
+
+func (c *execCollector) Name() string     { return "exec_" + c.cmd.Name }
+func (c *execCollector) Fields() []string { return nil }
+
+func (c *execCollector) Collect(ctx context.Context, out *Output) error {
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-ctx.Done():
+		return errors.New("waited too long for another exec command to finish")
+	}
+
+	var stdout, stderr cappedBuffer
+	stdout.max, stderr.max, stderr.keepTail = maxExecStdout, maxExecStderr, true
+	cmdCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(cmdCtx, c.cmd.Command[0], c.cmd.Command[1:]...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// children that keep the pipes open must not hold up the collector
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	check := &pb.CheckResult{
+		Name:            c.cmd.Name,
+		Stderr:          strings.ToValidUTF8(strings.TrimSpace(stderr.String()), "�"),
+		DurationSeconds: time.Since(start).Seconds(),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("error running %s: %v", c.cmd.Command[0], ctx.Err())
+	case cmdCtx.Err() != nil:
+		check.ExitCode = -1
+		out.Metrics.Checks = append(out.Metrics.Checks, check)
+		return &PartialError{Err: fmt.Errorf("%s was killed after running for %s", c.cmd.Command[0], c.timeout)}
+	case err != nil && !errors.As(err, &exitErr):
+		return fmt.Errorf("error running %s: %v", c.cmd.Command[0], err)
+	}
+	check.ExitCode = int32(cmd.ProcessState.ExitCode())
+	out.Metrics.Checks = append(out.Metrics.Checks, check)
+
+	parse := parseText
+	if c.cmd.Format == "json" {
+		parse = parseJSON
+	}
+	samples, err := parse(&stdout.Buffer)
+	out.Samples = append(out.Samples, samples...)
+	switch {
+	case err != nil:
+		return &PartialError{Err: fmt.Errorf("error parsing the output: %v", err)}
+	case stdout.cut:
+		return &PartialError{Err: fmt.Errorf("output cut off at %d bytes", maxExecStdout)}
+	}
+	return nil
+}
+
+// execCollectors turns the configured commands into collectors that share one
+// limit on how many run at the same time
+func execCollectors(cfg config.ExecConfig) []builtin {
+	slots := make(chan struct{}, cfg.MaxConcurrent)
+	var list []builtin
+	for _, cmd := range cfg.Commands {
+		timeout := cmd.Timeout
+		if timeout == 0 {
+			timeout = defaultExecTimeout
+		}
+		s := Schedule{Enabled: true, Interval: cmd.Interval, Timeout: timeout + execKillGrace}
+		if s.Interval == 0 {
+			s.Interval = defaultExecInterval
+		}
+		list = append(list, builtin{&execCollector{cmd: cmd, timeout: timeout, slots: slots}, s})
+	}
+	return list
+}
+
+// cappedBuffer keeps at most max bytes of what is written to it, the first
+// ones or with keepTail the last ones, and never fails a write
+type cappedBuffer struct {
+	bytes.Buffer
+	max      int
+	keepTail bool
+	cut      bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.keepTail {
+		b.Buffer.Write(p)
+		if extra := b.Len() - b.max; extra > 0 {
+			b.Next(extra)
+			b.cut = true
+		}
+		return n, nil
+	}
+	if room := b.max - b.Len(); len(p) > room {
+		p = p[:room]
+		b.cut = true
+	}
+	b.Buffer.Write(p)
+	return n, nil
+}
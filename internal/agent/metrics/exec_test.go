@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+func runExec(t *testing.T, script, format string, timeout time.Duration) (*Output, error) {
+	t.Helper()
+	c := &execCollector{
+		cmd:     config.ExecCommand{Name: "test", Command: []string{"sh", "-c", script}, Format: format},
+		timeout: timeout,
+		slots:   make(chan struct{}, 1),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+execKillGrace)
+	defer cancel()
+	out := &Output{Metrics: &pb.AgentMetrics{}}
+	return out, c.Collect(ctx, out)
+}
+
+func TestExecSamples(t *testing.T) {
+	out, err := runExec(t, `echo 'ups_charge{ups="main"} 97'; echo 'warming up' >&2`, "", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(out.Samples) != 1 || out.Samples[0].Name != "ups_charge" || out.Samples[0].Value != 97 {
+		t.Errorf("samples = %v", out.Samples)
+	}
+	checks := out.Metrics.Checks
+	if len(checks) != 1 || checks[0].Name != "test" || checks[0].ExitCode != 0 || checks[0].Stderr != "warming up" {
+		t.Errorf("checks = %v", checks)
+	}
+}
+
+func TestExecFailedCommand(t *testing.T) {
+	out, err := runExec(t, `echo '[{"name": "partial", "value": 1}]'; echo 'disk missing' >&2; exit 3`, "json", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	// a failed check still has its output read
+	if len(out.Samples) != 1 || out.Samples[0].Name != "partial" {
+		t.Errorf("samples = %v", out.Samples)
+	}
+	if c := out.Metrics.Checks[0]; c.ExitCode != 3 || c.Stderr != "disk missing" {
+		t.Errorf("check = %v, want exit 3 with its stderr", c)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	out, err := runExec(t, `echo 'stuck on lock' >&2; sleep 10`, "", 200*time.Millisecond)
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("err = %v, want a PartialError", err)
+	}
+	checks := out.Metrics.Checks
+	if len(checks) != 1 {
+		t.Fatalf("got %d check results, want 1", len(checks))
+	}
+	if c := checks[0]; c.ExitCode != -1 || c.Stderr != "stuck on lock" || c.DurationSeconds < 0.2 {
+		t.Errorf("check = %v, want exit -1 with the stderr so far", c)
+	}
+}
+
+func TestExecNotStarted(t *testing.T) {
+	c := &execCollector{
+		cmd:     config.ExecCommand{Name: "missing", Command: []string{"/nonexistent/command"}},
+		timeout: time.Second,
+		slots:   make(chan struct{}, 1),
+	}
+	out := &Output{Metrics: &pb.AgentMetrics{}}
+	err := c.Collect(context.Background(), out)
+	var partial *PartialError
+	if err == nil || errors.As(err, &partial) {
+		t.Errorf("err = %v, want a plain error", err)
+	}
+	if len(out.Metrics.Checks) != 0 {
+		t.Errorf("checks = %v, want none for a command that did not start", out.Metrics.Checks)
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	head := cappedBuffer{max: 5}
+	head.Write([]byte("abc"))
+	head.Write([]byte("defgh"))
+	if head.String() != "abcde" || !head.cut {
+		t.Errorf("head = %q, cut %v", head.String(), head.cut)
+	}
+
+	tail := cappedBuffer{max: 5, keepTail: true}
+	tail.Write([]byte("abc"))
+	tail.Write([]byte("defgh"))
+	if tail.String() != "defgh" || !tail.cut {
+		t.Errorf("tail = %q, cut %v", tail.String(), tail.cut)
+	}
+
+	var whole cappedBuffer
+	whole.max = 10
+	if n, err := whole.Write([]byte(strings.Repeat("x", 20))); n != 20 || err != nil {
+		t.Errorf("Write = %d, %v, want every byte taken", n, err)
+	}
+}
@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+// parseText reads the Prometheus text format: one `name{label="x"} value`
+// per line, with an optional timestamp in milliseconds after the value. HELP
+// and TYPE comments describe the samples that follow, other comments and
+// blank lines are skipped. A bad line does not stop the parsing, the first
+// error is returned with the samples of the good lines.
+func parseText(r io.Reader) ([]*pb.Sample, error) {
+	var samples []*pb.Sample
+	var firstErr error
+	help := make(map[string]string)
+	types := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		var err error
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			err = parseComment(line, help, types)
+		default:
+			var s *pb.Sample
+			if s, err = parseSampleLine(line); err == nil {
+				describeSample(s, help, types)
+				samples = append(samples, s)
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return samples, firstErr
+}
+
+var helpUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+
+// parseComment records a HELP or TYPE comment, other comments are ignored
+func parseComment(line string, help, types map[string]string) error {
+	keyword, rest, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "#")), " ")
+	if keyword != "HELP" && keyword != "TYPE" {
+		return nil
+	}
+	name, text, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	text = strings.TrimSpace(text)
+	if name == "" {
+		return fmt.Errorf("%s without a metric name", keyword)
+	}
+	if keyword == "HELP" {
+		help[name] = helpUnescaper.Replace(text)
+		return nil
+	}
+	switch text {
+	case "counter", "gauge", "histogram", "summary", "untyped":
+		types[name] = text
+		return nil
+	}
+	return fmt.Errorf("unknown type %q", text)
+}
+
+// describeSample sets the help and type of a sample from the comments of its
+// family. The _bucket, _sum and _count series of histograms and summaries
+// are sent as gauges under their own names. So are counters without the
+// _total suffix, the server would add it otherwise.
+func describeSample(s *pb.Sample, help, types map[string]string) {
+	family := s.Name
+	if _, ok := types[family]; !ok {
+		for _, suffix := range []string{"_total", "_bucket", "_sum", "_count", "_created"} {
+			if _, ok := types[strings.TrimSuffix(s.Name, suffix)]; ok && strings.HasSuffix(s.Name, suffix) {
+				family = strings.TrimSuffix(s.Name, suffix)
+				break
+			}
+		}
+	}
+	s.Help = help[family]
+	if types[family] == "counter" && strings.HasSuffix(s.Name, "_total") {
+		s.Type = pb.SampleType_SAMPLE_COUNTER
+	}
+}
+
+func parseSampleLine(line string) (*pb.Sample, error) {
+	if !utf8.ValidString(line) {
+		return nil, errors.New("not valid UTF-8")
+	}
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return nil, errors.New("expected a name and a value")
+	}
+	s := &pb.Sample{Name: line[:end]}
+	rest := line[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		labels, n, err := parseLabels(rest[1:])
+		if err != nil {
+			return nil, err
+		}
+		s.Labels = labels
+		rest = rest[1+n:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return nil, errors.New("missing value")
+	}
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("unexpected %q after the timestamp", fields[2])
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", fields[0])
+	}
+	s.Value = v
+	if len(fields) == 2 {
+		if s.TimestampMs, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+	}
+	return s, nil
+}
+
+// parseLabels reads `a="1",b="2"}` and returns the labels and the length of
+// what it read, closing brace included
+func parseLabels(s string) (map[string]string, int, error) {
+	labels := make(map[string]string)
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i < len(s) && s[i] == '}' {
+			return labels, i + 1, nil
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 {
+			return nil, 0, errors.New("unterminated labels")
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		if name == "" {
+			return nil, 0, errors.New("empty label name")
+		}
+		i += eq + 1
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		value, n, err := parseLabelValue(s[i:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("label %s: %w", name, err)
+		}
+		labels[name] = value
+		i += n
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		switch {
+		case i < len(s) && s[i] == ',':
+			i++
+		case i < len(s) && s[i] == '}':
+		default:
+			return nil, 0, errors.New("expected , or } after a label")
+		}
+	}
+}
+
+// parseLabelValue reads a double quoted value with the escapes of the
+// Prometheus text format: \\, \" and \n
+func parseLabelValue(s string) (string, int, error) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", 0, errors.New("value must be in double quotes")
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", 0, errors.New("unterminated value")
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case '\\', '"':
+				b.WriteByte(s[i])
+			default:
+				return "", 0, fmt.Errorf("unknown escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errors.New("unterminated value")
+}
+
+// jsonSample is a sample as written by an exec command with the json format
+type jsonSample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  *float64          `json:"value"`
+	Unit   string            `json:"unit"`
+	Type   string            `json:"type"` // gauge, the default, or counter
+}
+
+// parseJSON reads a JSON array of samples
+func parseJSON(r io.Reader) ([]*pb.Sample, error) {
+	var list []jsonSample
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+	samples := make([]*pb.Sample, 0, len(list))
+	var firstErr error
+	for i, js := range list {
+		s := &pb.Sample{Name: js.Name, Labels: js.Labels, Unit: js.Unit}
+		var err error
+		switch {
+		case js.Name == "":
+			err = errors.New("missing name")
+		case js.Value == nil:
+			err = errors.New("missing value")
+		case js.Type == "" || js.Type == "gauge":
+		case js.Type == "counter":
+			s.Type = pb.SampleType_SAMPLE_COUNTER
+		default:
+			err = fmt.Errorf("unknown type %q", js.Type)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("sample %d: %w", i, err)
+			}
+			continue
+		}
+		s.Value = *js.Value
+		samples = append(samples, s)
+	}
+	return samples, firstErr
+}
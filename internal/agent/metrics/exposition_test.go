@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+func TestParseText(t *testing.T) {
+	input := `
+# HELP backup_runs_total Backup runs.
+# TYPE backup_runs_total counter
+backup_runs_total{job="nightly"} 42
+# HELP http_requests Requests served,\nby code.
+# TYPE http_requests counter
+http_requests_total{code="200"} 1027 1700000000000
+http_requests_created{code="200"} 1.7e9
+# TYPE legacy counter
+legacy 7
+# TYPE latency histogram
+latency_bucket{le="0.5"} 3
+latency_sum 1.25
+latency_count 4
+# a plain comment
+temperature	21.5
+`
+	samples, err := parseText(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseText: %v", err)
+	}
+
+	want := []struct {
+		name   string
+		labels map[string]string
+		value  float64
+		ts     int64
+		typ    pb.SampleType
+		help   string
+	}{
+		{"backup_runs_total", map[string]string{"job": "nightly"}, 42, 0, pb.SampleType_SAMPLE_COUNTER, "Backup runs."},
+		// the family is named without _total, its help and type still apply
+		{"http_requests_total", map[string]string{"code": "200"}, 1027, 1700000000000, pb.SampleType_SAMPLE_COUNTER, "Requests served,\nby code."},
+		{"http_requests_created", map[string]string{"code": "200"}, 1.7e9, 0, pb.SampleType_SAMPLE_GAUGE, "Requests served,\nby code."},
+		// a counter without _total is sent as a gauge under its own name
+		{"legacy", nil, 7, 0, pb.SampleType_SAMPLE_GAUGE, ""},
+		{"latency_bucket", map[string]string{"le": "0.5"}, 3, 0, pb.SampleType_SAMPLE_GAUGE, ""},
+		{"latency_sum", nil, 1.25, 0, pb.SampleType_SAMPLE_GAUGE, ""},
+		{"latency_count", nil, 4, 0, pb.SampleType_SAMPLE_GAUGE, ""},
+		{"temperature", nil, 21.5, 0, pb.SampleType_SAMPLE_GAUGE, ""},
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("got %d samples, want %d: %v", len(samples), len(want), samples)
+	}
+	for i, w := range want {
+		s := samples[i]
+		if s.Name != w.name || s.Value != w.value || s.TimestampMs != w.ts || s.Type != w.typ || s.Help != w.help {
+			t.Errorf("sample %d = %v, want %+v", i, s, w)
+		}
+		if len(s.Labels) != len(w.labels) {
+			t.Errorf("%s labels = %v, want %v", s.Name, s.Labels, w.labels)
+		}
+		for k, v := range w.labels {
+			if s.Labels[k] != v {
+				t.Errorf("%s label %s = %q, want %q", s.Name, k, s.Labels[k], v)
+			}
+		}
+	}
+}
+
+func TestParseTextBadLines(t *testing.T) {
+	input := `good 1
+bad{job="x" 2
+# TYPE weird enum
+no_value
+also_good{a="b"} 3
+trailing 1 2 3
+nan NaN
+`
+	samples, err := parseText(strings.NewReader(input))
+	// the first bad line is reported, the good lines are kept
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("err = %v, want one for line 2", err)
+	}
+	var names []string
+	for _, s := range samples {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "good,also_good,nan" {
+		t.Errorf("kept %s, want good,also_good,nan", got)
+	}
+}
+
+func TestParseSampleLineErrors(t *testing.T) {
+	tests := []struct {
+		line, err string
+	}{
+		{"{a=\"b\"} 1", "expected a name and a value"},
+		{"name", "expected a name and a value"},
+		{"name{a=\"b\"}", "missing value"},
+		{"name abc", `invalid value "abc"`},
+		{"name 1 soon", `invalid timestamp "soon"`},
+		{"name 1 2 3", `unexpected "3" after the timestamp`},
+		{"name\xff 1", "not valid UTF-8"},
+	}
+	for _, tt := range tests {
+		if _, err := parseSampleLine(tt.line); err == nil || err.Error() != tt.err {
+			t.Errorf("parseSampleLine(%q) = %v, want %q", tt.line, err, tt.err)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		in     string
+		labels map[string]string
+		n      int
+	}{
+		{`}`, map[string]string{}, 1},
+		{`a="1",b="2"} 5`, map[string]string{"a": "1", "b": "2"}, 12},
+		{` a = "1" , b="2" ,} 5`, map[string]string{"a": "1", "b": "2"}, 19},
+		{`path="C:\\temp",msg="say \"hi\"\nbye"}`, map[string]string{"path": `C:\temp`, "msg": "say \"hi\"\nbye"}, 38},
+		{`brace="}{,="}`, map[string]string{"brace": "}{,="}, 13},
+	}
+	for _, tt := range tests {
+		labels, n, err := parseLabels(tt.in)
+		if err != nil {
+			t.Errorf("parseLabels(%q): %v", tt.in, err)
+			continue
+		}
+		if n != tt.n {
+			t.Errorf("parseLabels(%q) read %d bytes, want %d", tt.in, n, tt.n)
+		}
+		if len(labels) != len(tt.labels) {
+			t.Errorf("parseLabels(%q) = %v, want %v", tt.in, labels, tt.labels)
+		}
+		for k, v := range tt.labels {
+			if labels[k] != v {
+				t.Errorf("parseLabels(%q)[%s] = %q, want %q", tt.in, k, labels[k], v)
+			}
+		}
+	}
+}
+
+func TestParseLabelsErrors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{`a="1"`, "expected , or } after a label"},
+		{`a="1" b="2"}`, "expected , or } after a label"},
+		{`a=1}`, "label a: value must be in double quotes"},
+		{`a="1`, "label a: unterminated value"},
+		{`a="1\`, "label a: unterminated value"},
+		{`a="\t"}`, `label a: unknown escape \t`},
+		{`="1"}`, "empty label name"},
+		{`a`, "unterminated labels"},
+	}
+	for _, tt := range tests {
+		if _, _, err := parseLabels(tt.in); err == nil || err.Error() != tt.err {
+			t.Errorf("parseLabels(%q) = %v, want %q", tt.in, err, tt.err)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	input := `[
+		{"name": "ups_charge", "labels": {"ups": "main"}, "value": 97, "unit": "percent"},
+		{"name": "ups_events_total", "value": 3, "type": "counter"},
+		{"name": "zero", "value": 0, "type": "gauge"},
+		{"name": "no_value"},
+		{"value": 1},
+		{"name": "odd", "value": 1, "type": "histogram"}
+	]`
+	samples, err := parseJSON(strings.NewReader(input))
+	if err == nil || err.Error() != "sample 3: missing value" {
+		t.Errorf("err = %v, want the first bad sample", err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want 3: %v", len(samples), samples)
+	}
+	if s := samples[0]; s.Name != "ups_charge" || s.Labels["ups"] != "main" || s.Value != 97 || s.Unit != "percent" || s.Type != pb.SampleType_SAMPLE_GAUGE {
+		t.Errorf("ups_charge = %v", s)
+	}
+	if s := samples[1]; s.Type != pb.SampleType_SAMPLE_COUNTER || s.Value != 3 {
+		t.Errorf("ups_events_total = %v", s)
+	}
+	// a value of 0 is there, not missing
+	if s := samples[2]; s.Name != "zero" || s.Value != 0 {
+		t.Errorf("zero = %v", s)
+	}
+
+	if _, err := parseJSON(strings.NewReader(`{"name": "x"}`)); err == nil {
+		t.Error("parseJSON accepted an object instead of an array")
+	}
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	"github.com/shirou/gopsutil/disk"
+)
+
+// Filesystem is the usage of one mounted filesystem
+type Filesystem struct {
+	Mountpoint  string
+	Device      string
+	FSType      string
+	TotalBytes  uint64
+	UsedBytes   uint64
+	FreeBytes   uint64 // available to unprivileged users
+	InodesTotal uint64
+	InodesUsed  uint64
+	InodesFree  uint64
+}
+
+var fsFilter config.FilesystemConfig
+
+// SetFilesystemFilter sets which mounts GetFilesystems reports, call it before collecting
+func SetFilesystemFilter(cfg config.FilesystemConfig) {
+	fsFilter = cfg
+}
+
+// GetFilesystems reports every mounted filesystem that passes the filter,
+// sorted by mountpoint. A device mounted more than once (bind mounts, btrfs
+// subvolumes) is only reported at its shortest mountpoint.
+func GetFilesystems() ([]Filesystem, error) {
+	partitions, err := disk.Partitions(true)
+	if err != nil {
+		return nil, fmt.Errorf("error listing filesystems: %v", err)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return len(partitions[i].Mountpoint) < len(partitions[j].Mountpoint) })
+
+	var filesystems []Filesystem
+	seen := make(map[string]bool)
+	for _, p := range partitions {
+		if !wantFilesystem(fsFilter, p) {
+			continue
+		}
+		if strings.HasPrefix(p.Device, "/") {
+			if seen[p.Device] {
+				continue
+			}
+			seen[p.Device] = true
+		}
+
+		usage, err := disk.Usage(p.Mountpoint)
+		if err != nil {
+			logger.Debugf("Error getting usage of %s: %v", p.Mountpoint, err)
+			continue
+		}
+		if usage.Total == 0 {
+			continue
+		}
+		filesystems = append(filesystems, Filesystem{
+			Mountpoint:  p.Mountpoint,
+			Device:      p.Device,
+			FSType:      p.Fstype,
+			TotalBytes:  usage.Total,
+			UsedBytes:   usage.Used,
+			FreeBytes:   usage.Free,
+			InodesTotal: usage.InodesTotal,
+			InodesUsed:  usage.InodesUsed,
+			InodesFree:  usage.InodesFree,
+		})
+	}
+	sort.Slice(filesystems, func(i, j int) bool { return filesystems[i].Mountpoint < filesystems[j].Mountpoint })
+	return filesystems, nil
+}
+
+func wantFilesystem(f config.FilesystemConfig, p disk.PartitionStat) bool {
+	if len(f.IncludeFSTypes) > 0 && !contains(f.IncludeFSTypes, p.Fstype) {
+		return false
+	}
+	if contains(f.ExcludeFSTypes, p.Fstype) {
+		return false
+	}
+	if len(f.IncludeMountpoints) > 0 && !matchMountpoint(f.IncludeMountpoints, p.Mountpoint) {
+		return false
+	}
+	return !matchMountpoint(f.ExcludeMountpoints, p.Mountpoint)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMountpoint reports whether a pattern matches the mountpoint or one of
+// its parent directories
+func matchMountpoint(patterns []string, mountpoint string) bool {
+	for dir := filepath.Clean(mountpoint); ; dir = filepath.Dir(dir) {
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, dir); ok {
+				return true
+			}
+		}
+		if dir == "/" || dir == "." {
+			return false
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/net"
+)
+
+// NetworkInterface is the traffic of one interface, per second since the previous collection
+type NetworkInterface struct {
+	Name      string
+	RxBytes   float64
+	TxBytes   float64
+	RxPackets float64
+	TxPackets float64
+	RxErrors  float64
+	TxErrors  float64
+	RxDropped float64
+	TxDropped float64
+}
+
+// DiskDevice is the I/O of one block device, per second since the previous collection
+type DiskDevice struct {
+	Name       string
+	ReadBytes  float64
+	WriteBytes float64
+	Reads      float64
+	Writes     float64
+	Busy       float64 // percent of the time with I/O in flight
+}
+
+var (
+	netFilter   config.NetworkConfig
+	nicPrev     map[string]net.IOCountersStat
+	nicPrevTime time.Time
+
+	diskFilter      config.DiskIOConfig
+	diskDevPrev     map[string]disk.IOCountersStat
+	diskDevPrevTime time.Time
+)
+
+// SetNetworkFilter sets which interfaces GetNetworkInterfaces reports
+func SetNetworkFilter(cfg config.NetworkConfig) {
+	netFilter = cfg
+}
+
+// SetDiskIOFilter sets which block devices GetDiskDevices reports
+func SetDiskIOFilter(cfg config.DiskIOConfig) {
+	diskFilter = cfg
+}
+
+// GetNetworkInterfaces reports the rates of every interface that passes the
+// filter, sorted by name. Like the totals, the first call only records the
+// counters, and an interface shows up one collection after it appears.
+func GetNetworkInterfaces() ([]NetworkInterface, error) {
+	counters, err := net.IOCounters(true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting per interface network counters: %v", err)
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(nicPrevTime).Seconds()
+	first := nicPrev == nil
+	prev := nicPrev
+	nicPrev = make(map[string]net.IOCountersStat, len(counters))
+	nicPrevTime = now
+
+	var interfaces []NetworkInterface
+	for _, c := range counters {
+		if !wantName(netFilter.IncludeInterfaces, netFilter.ExcludeInterfaces, c.Name) {
+			continue
+		}
+		nicPrev[c.Name] = c
+		p, ok := prev[c.Name]
+		if !ok || elapsed <= 0 {
+			continue
+		}
+		interfaces = append(interfaces, NetworkInterface{
+			Name:      c.Name,
+			RxBytes:   rate(c.BytesRecv, p.BytesRecv, elapsed),
+			TxBytes:   rate(c.BytesSent, p.BytesSent, elapsed),
+			RxPackets: rate(c.PacketsRecv, p.PacketsRecv, elapsed),
+			TxPackets: rate(c.PacketsSent, p.PacketsSent, elapsed),
+			RxErrors:  rate(c.Errin, p.Errin, elapsed),
+			TxErrors:  rate(c.Errout, p.Errout, elapsed),
+			RxDropped: rate(c.Dropin, p.Dropin, elapsed),
+			TxDropped: rate(c.Dropout, p.Dropout, elapsed),
+		})
+	}
+	if first {
+		return nil, errNoBaseline
+	}
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Name < interfaces[j].Name })
+	return interfaces, nil
+}
+
+// GetDiskDevices reports the I/O rates of every block device that passes the
+// filter, sorted by name. The first call only records the counters.
+func GetDiskDevices() ([]DiskDevice, error) {
+	counters, err := disk.IOCounters()
+	if err != nil {
+		return nil, fmt.Errorf("error getting per device disk counters: %v", err)
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(diskDevPrevTime).Seconds()
+	first := diskDevPrev == nil
+	prev := diskDevPrev
+	diskDevPrev = make(map[string]disk.IOCountersStat, len(counters))
+	diskDevPrevTime = now
+
+	var devices []DiskDevice
+	for name, c := range counters {
+		if !wantName(diskFilter.IncludeDevices, diskFilter.ExcludeDevices, name) {
+			continue
+		}
+		if isPartition(name) && !matchAny(diskFilter.IncludeDevices, name) {
+			continue
+		}
+		diskDevPrev[name] = c
+		p, ok := prev[name]
+		if !ok || elapsed <= 0 {
+			continue
+		}
+		devices = append(devices, DiskDevice{
+			Name:       name,
+			ReadBytes:  rate(c.ReadBytes, p.ReadBytes, elapsed),
+			WriteBytes: rate(c.WriteBytes, p.WriteBytes, elapsed),
+			Reads:      rate(c.ReadCount, p.ReadCount, elapsed),
+			Writes:     rate(c.WriteCount, p.WriteCount, elapsed),
+			// io_time is in milliseconds
+			Busy: clampPercent(rate(c.IoTime, p.IoTime, elapsed) / 10),
+		})
+	}
+	if first {
+		return nil, errNoBaseline
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+	return devices, nil
+}
+
+// rate is the per second increase of a counter, 0 when it went backwards
+// (wrapped or reset)
+func rate(cur, prev uint64, seconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / seconds
+}
+
+// isPartition reports whether a block device is a partition of another one.
+// On Linux only whole disks are listed in /sys/block, elsewhere nothing is
+// treated as a partition.
+func isPartition(name string) bool {
+	if _, err := os.Stat("/sys/block"); err != nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join("/sys/block", name))
+	return os.IsNotExist(err)
+}
+
+func wantName(include, exclude []string, name string) bool {
+	if len(include) > 0 && !matchAny(include, name) {
+		return false
+	}
+	return !matchAny(exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
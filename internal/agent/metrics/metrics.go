@@ -1,109 +1,186 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"strings"
 	"time"
 
-	"github.com/mansoormajeed/glimpse/internal/common/logger"
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/load"
 	"github.com/shirou/gopsutil/mem"
 	"github.com/shirou/gopsutil/net"
 )
 
-type Metrics struct {
-	CPUUsage        int64
-	MemoryUsage     int64
-	DiskUsage       int64
-	NetworkUpload   int64
-	NetworkDownload int64
-	DiskReadKB      int64
-	DiskWriteKB     int64
-	CPUTemp         int64
-	Uptime          int64 // Uptime in seconds
+// CPUStats covers the time since the previous collection. All values are percentages.
+type CPUStats struct {
+	Usage   float64   // busy across all cores
+	PerCore []float64 // busy per logical core
+	User    float64   // share of all CPU time
+	System  float64
+	IOWait  float64
+	Steal   float64
+	Count   int32 // logical cores
 }
 
-type AgentHeartbeat struct {
-	Hostname     string
-	OS           string
-	Metrics      Metrics
-	LastSeen     time.Time
-	ConnectedFor time.Duration
+// MemoryStats is in bytes, the swap rates in bytes per second since the previous collection
+type MemoryStats struct {
+	Total       uint64
+	Used        uint64 // excludes buffers and page cache
+	Available   uint64
+	Buffers     uint64
+	Cached      uint64
+	UsedPercent float64
+	SwapTotal   uint64
+	SwapUsed    uint64
+	SwapIn      float64
+	SwapOut     float64
 }
 
 var nwPreviousUpload, nwPreviousDownload uint64
 var nwPreviousTime time.Time
 var diskPrevReadBytes, diskPrevWriteBytes uint64
 var diskPrevTime time.Time
+var cpuPrevTimes []cpu.TimesStat
+var swapPrevIn, swapPrevOut uint64
+var swapPrevTime time.Time
 
-func GetAgentMetrics() (Metrics, error) {
-
-	cpuUsage := GetCPUUsage()
-	memoryUsage := GetMemoryUsage()
-	diskUsage := GetDiskUsage()
-	networkUpload, networkDownload := GetNetworkUsage()
-	cpuTemp := GetCPUTemperature()
-	diskReadKB, diskWriteKB := GetDiskIO()
-	uptime := GetHostUptime()
-
-	metrics := Metrics{
-		CPUUsage:        cpuUsage,
-		MemoryUsage:     memoryUsage,
-		DiskUsage:       diskUsage,
-		NetworkUpload:   networkUpload,
-		NetworkDownload: networkDownload,
-		DiskReadKB:      diskReadKB,
-		DiskWriteKB:     diskWriteKB,
-		CPUTemp:         cpuTemp,
-		Uptime:          uptime,
-	}
-	return metrics, nil
-}
+// errNoBaseline is returned by the collectors that report rates on their first
+// call, when there is nothing to compare the counters with yet
+var errNoBaseline = errors.New("waiting for a second sample")
 
-func GetHostUptime() int64 {
+func GetHostUptime() (int64, error) {
 	uptime, err := host.Uptime()
-	logger.Debugf("Uptime: %v", uptime)
 	if err != nil {
-		logger.Errorf("Error getting uptime: %v", err)
-		return 0
+		return 0, fmt.Errorf("error getting uptime: %v", err)
 	}
-	return int64(uptime)
+	return int64(uptime), nil
 }
 
-func GetCPUUsage() int64 {
-	cpuPercent, err := cpu.Percent(time.Second, false) // false means per cpu core? TODO: check
+// GetCPUStats works out CPU usage from the per core time counters, comparing
+// them with the previous call instead of sleeping a second inside cpu.Percent.
+// Like the network and disk rates, the first call only records the counters.
+func GetCPUStats() (CPUStats, error) {
+	var stats CPUStats
+	if count, err := cpu.Counts(true); err == nil {
+		stats.Count = int32(count)
+	}
+
+	times, err := cpu.Times(true)
 	if err != nil {
-		logger.Errorf("Error getting cpu usage: %v", err)
-		return 0
+		return stats, fmt.Errorf("error getting cpu times: %v", err)
+	}
+	if len(times) == 0 {
+		return stats, errors.New("no cpu times reported")
+	}
+	prev := cpuPrevTimes
+	cpuPrevTimes = times
+	if len(prev) != len(times) {
+		return stats, errNoBaseline // first call, or cores went on/offline
+	}
+
+	var total, busy, user, system, iowait, steal float64
+	stats.PerCore = make([]float64, len(times))
+	for i, t := range times {
+		p := prev[i]
+		coreTotal := t.Total() - p.Total()
+		coreIdle := (t.Idle + t.Iowait) - (p.Idle + p.Iowait)
+		if coreTotal <= 0 {
+			continue
+		}
+		stats.PerCore[i] = clampPercent((coreTotal - coreIdle) / coreTotal * 100)
+
+		total += coreTotal
+		busy += coreTotal - coreIdle
+		user += (t.User + t.Nice) - (p.User + p.Nice)
+		system += (t.System + t.Irq + t.Softirq) - (p.System + p.Irq + p.Softirq)
+		iowait += t.Iowait - p.Iowait
+		steal += t.Steal - p.Steal
+	}
+	if total <= 0 {
+		return stats, errNoBaseline
+	}
+
+	stats.Usage = clampPercent(busy / total * 100)
+	stats.User = clampPercent(user / total * 100)
+	stats.System = clampPercent(system / total * 100)
+	stats.IOWait = clampPercent(iowait / total * 100)
+	stats.Steal = clampPercent(steal / total * 100)
+	return stats, nil
+}
+
+// counters can step backwards slightly between reads, keep that out of the numbers
+func clampPercent(v float64) float64 {
+	return math.Max(0, math.Min(100, v))
+}
+
+func GetLoadAverage() (load.AvgStat, error) {
+	avg, err := load.Avg()
+	if err != nil {
+		return load.AvgStat{}, fmt.Errorf("error getting load average: %v", err)
 	}
-	return int64(cpuPercent[0])
+	return *avg, nil
 }
 
-func GetMemoryUsage() int64 {
+// swapFields are the AgentMetrics fields GetMemoryStats leaves out when only the memory could be read
+var swapFields = []string{"swap_total", "swap_used", "swap_in", "swap_out"}
+
+// GetMemoryStats reads memory and swap usage. When only part of it could be
+// read the error is a *PartialError naming the missing fields, the swap rates
+// on the first call for instance.
+func GetMemoryStats() (MemoryStats, error) {
+	var stats MemoryStats
 	memory, err := mem.VirtualMemory()
 	if err != nil {
-		logger.Errorf("Error getting memory usage: %v", err)
-		return 0
+		return stats, fmt.Errorf("error getting memory usage: %v", err)
 	}
-	return int64(memory.UsedPercent)
+	stats.Total = memory.Total
+	stats.Used = memory.Used
+	stats.Available = memory.Available
+	stats.Buffers = memory.Buffers
+	stats.Cached = memory.Cached
+	stats.UsedPercent = memory.UsedPercent
+
+	swap, err := mem.SwapMemory()
+	if err != nil {
+		return stats, &PartialError{Fields: swapFields, Err: fmt.Errorf("error getting swap usage: %v", err)}
+	}
+	stats.SwapTotal = swap.Total
+	stats.SwapUsed = swap.Used
+
+	now := time.Now()
+	elapsed := now.Sub(swapPrevTime).Seconds()
+	first := swapPrevTime.IsZero()
+	if !first && elapsed > 0 {
+		stats.SwapIn = rate(swap.Sin, swapPrevIn, elapsed)
+		stats.SwapOut = rate(swap.Sout, swapPrevOut, elapsed)
+	}
+	swapPrevIn, swapPrevOut, swapPrevTime = swap.Sin, swap.Sout, now
+	if first || elapsed <= 0 {
+		return stats, &PartialError{Fields: []string{"swap_in", "swap_out"}, Err: errNoBaseline}
+	}
+	return stats, nil
 }
 
-func GetDiskUsage() int64 {
+func GetDiskUsage() (int64, error) {
 	diskUsage, err := disk.Usage("/")
 	if err != nil {
-		logger.Errorf("Error getting disk usage: %v", err)
-		return 0
+		return 0, fmt.Errorf("error getting disk usage: %v", err)
 	}
-	return int64(diskUsage.UsedPercent)
+	return int64(diskUsage.UsedPercent), nil
 }
-func GetNetworkUsage() (int64, int64) {
+func GetNetworkUsage() (int64, int64, error) {
 
 	// sum the bytes sent and received across all network interfaces
 	counters, err := net.IOCounters(false)
-	if err != nil || len(counters) == 0 {
-		logger.Errorf("Error getting network usage: %v", err)
-		return 0, 0
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting network usage: %v", err)
+	}
+	if len(counters) == 0 {
+		return 0, 0, errors.New("no network counters reported")
 	}
 
 	now := time.Now()
@@ -116,7 +193,7 @@ func GetNetworkUsage() (int64, int64) {
 	if nwPreviousUpload == 0 {
 		nwPreviousUpload = upload
 		nwPreviousDownload = download
-		return 0, 0 // first call, no previous data
+		return 0, 0, errNoBaseline // first call, no previous data
 	}
 
 	uploadRate := float64(upload-nwPreviousUpload) / duration
@@ -125,14 +202,13 @@ func GetNetworkUsage() (int64, int64) {
 	nwPreviousUpload = upload
 	nwPreviousDownload = download
 
-	return int64(uploadRate / 1024), int64(downloadRate / 1024) // convert to KB
+	return int64(uploadRate / 1024), int64(downloadRate / 1024), nil // convert to KB
 }
 
-func GetDiskIO() (int64, int64) {
+func GetDiskIO() (int64, int64, error) {
 	ioStats, err := disk.IOCounters()
 	if err != nil {
-		logger.Errorf("Error getting disk IO: %v", err)
-		return 0, 0
+		return 0, 0, fmt.Errorf("error getting disk IO: %v", err)
 	}
 
 	var readBytes, writeBytes uint64
@@ -148,7 +224,7 @@ func GetDiskIO() (int64, int64) {
 	if diskPrevReadBytes == 0 {
 		diskPrevReadBytes = readBytes
 		diskPrevWriteBytes = writeBytes
-		return 0, 0 // Skip first sample
+		return 0, 0, errNoBaseline // Skip first sample
 	}
 
 	readRate := float64(readBytes-diskPrevReadBytes) / delta
@@ -157,7 +233,7 @@ func GetDiskIO() (int64, int64) {
 	diskPrevReadBytes = readBytes
 	diskPrevWriteBytes = writeBytes
 
-	return int64(readRate / 1024), int64(writeRate / 1024) // in KB/s
+	return int64(readRate / 1024), int64(writeRate / 1024), nil // in KB/s
 }
 
 // func GetCPUTemperature() int64 {
@@ -187,11 +263,10 @@ func GetDiskIO() (int64, int64) {
 //     This gives a conservative estimate and avoids underreporting.
 //  3. If no CPU-like sensors are found, fall back to the highest temperature across all sensors.
 //     This isn't perfect, but avoids returning something irrelevant like NVMe or USB temps.
-//  4. Long-term: this could be made configurable, or use smarter detection per hardware/vendor.
-func GetCPUTemperature() int64 {
-	temps, err := host.SensorsTemperatures()
-	if err != nil || len(temps) == 0 {
-		logger.Errorf("Error getting CPU temperature: %v", err)
+//  4. When the sensors config names any sensor "cpu", none of the above guessing happens:
+//     the hottest of the sensors named "cpu" is used.
+func GetCPUTemperature(temps []Sensor) int64 {
+	if len(temps) == 0 {
 		return 0
 	}
 
@@ -199,13 +274,23 @@ func GetCPUTemperature() int64 {
 	var found bool
 
 	for _, t := range temps {
-		key := strings.ToLower(t.SensorKey)
+		if t.Name == "cpu" && (t.Celsius > bestTemp || !found) {
+			bestTemp = t.Celsius
+			found = true
+		}
+	}
+	if found {
+		return int64(bestTemp)
+	}
+
+	for _, t := range temps {
+		key := strings.ToLower(t.Key)
 		if strings.Contains(key, "cpu") ||
 			strings.Contains(key, "core") ||
 			strings.Contains(key, "package") ||
 			strings.Contains(key, "tctl") {
-			if t.Temperature > bestTemp || !found {
-				bestTemp = t.Temperature
+			if t.Celsius > bestTemp || !found {
+				bestTemp = t.Celsius
 				found = true
 			}
 		}
@@ -216,10 +301,10 @@ func GetCPUTemperature() int64 {
 	}
 
 	// fallback: max of all temps
-	maxTemp := temps[0].Temperature
+	maxTemp := temps[0].Celsius
 	for _, t := range temps {
-		if t.Temperature > maxTemp {
-			maxTemp = t.Temperature
+		if t.Celsius > maxTemp {
+			maxTemp = t.Celsius
 		}
 	}
 
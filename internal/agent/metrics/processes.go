@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/shirou/gopsutil/process"
+)
+
+// Process is one of the busiest processes at collection time
+type Process struct {
+	PID        int32
+	Name       string
+	User       string
+	Cmdline    string
+	CPUPercent float64 // of one core, since the previous collection
+	RSS        uint64  // bytes
+}
+
+// procTimes is what the previous collection saw of a process. The create time
+// tells a reused pid apart from the process it was compared with.
+type procTimes struct {
+	created int64
+	cpu     float64 // user + system seconds
+}
+
+var (
+	procConfig   config.ProcessesConfig
+	procPrev     map[int32]procTimes
+	procPrevTime time.Time
+)
+
+// SetProcessesConfig sizes the top processes lists
+func SetProcessesConfig(cfg config.ProcessesConfig) {
+	procConfig = cfg
+}
+
+// GetTopProcesses returns the processes using the most CPU and the most
+// memory, procConfig.Top of each. CPU is compared with the previous call, so
+// the first call only returns the memory list, with a *PartialError.
+func GetTopProcesses() (byCPU, byMemory []Process, err error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing processes: %v", err)
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(procPrevTime).Seconds()
+	first := procPrev == nil
+	prev := procPrev
+	procPrev = make(map[int32]procTimes, len(procs))
+	procPrevTime = now
+
+	type sample struct {
+		proc *process.Process
+		cpu  float64
+		rss  uint64
+	}
+	samples := make([]sample, 0, len(procs))
+	for _, p := range procs {
+		// processes can exit between listing and reading, skip those
+		times, err := p.Times()
+		if err != nil {
+			continue
+		}
+		mem, err := p.MemoryInfo()
+		if err != nil {
+			continue
+		}
+		created, _ := p.CreateTime()
+		cur := procTimes{created: created, cpu: times.User + times.System}
+		procPrev[p.Pid] = cur
+
+		s := sample{proc: p, rss: mem.RSS}
+		if last, ok := prev[p.Pid]; ok && last.created == created && elapsed > 0 && cur.cpu >= last.cpu {
+			s.cpu = (cur.cpu - last.cpu) / elapsed * 100
+		}
+		samples = append(samples, s)
+	}
+
+	describe := func(s sample) Process {
+		p := Process{PID: s.proc.Pid, CPUPercent: s.cpu, RSS: s.rss}
+		p.Name, _ = s.proc.Name()
+		p.User, _ = s.proc.Username()
+		p.Cmdline, _ = s.proc.Cmdline()
+		if n := procConfig.CmdlineLength; len(p.Cmdline) > n {
+			p.Cmdline = p.Cmdline[:n]
+		}
+		// proto strings must be valid UTF-8, which neither the cut nor /proc promise
+		p.Name = strings.ToValidUTF8(p.Name, "\uFFFD")
+		p.Cmdline = strings.ToValidUTF8(p.Cmdline, "\uFFFD")
+		return p
+	}
+
+	top := min(procConfig.Top, len(samples))
+	sort.Slice(samples, func(i, j int) bool { return samples[i].rss > samples[j].rss })
+	for _, s := range samples[:top] {
+		byMemory = append(byMemory, describe(s))
+	}
+	if first {
+		return nil, byMemory, &PartialError{Fields: []string{"top_cpu"}, Err: errNoBaseline}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].cpu > samples[j].cpu })
+	for _, s := range samples[:top] {
+		byCPU = append(byCPU, describe(s))
+	}
+	return byCPU, byMemory, nil
+}
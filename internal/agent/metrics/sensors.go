@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/shirou/gopsutil/host"
+)
+
+// Sensor is one temperature reading. Name is the logical name from the
+// sensors config, empty when the key is not mapped.
+type Sensor struct {
+	Key     string
+	Name    string
+	Celsius float64
+}
+
+// Fan is one fan speed, named like Sensor
+type Fan struct {
+	Key  string
+	Name string
+	RPM  float64
+}
+
+var sensorConfig config.SensorsConfig
+
+// SetSensorsConfig sets the logical sensor names and the sensors to ignore
+func SetSensorsConfig(cfg config.SensorsConfig) {
+	sensorConfig = cfg
+}
+
+// GetTemperatures reports every temperature sensor. host.SensorsTemperatures
+// returns the hwmon limits (max, crit, alarms) next to the readings, only the
+// readings are kept and their _input suffix is dropped from the key. Sensors that
+// share a key, like two NVMe drives, get _1, _2, ... appended in hwmon order.
+// Readings that failed are left out, it is an error only when none could be read.
+func GetTemperatures() ([]Sensor, error) {
+	temps, err := host.SensorsTemperatures()
+	if err != nil && len(temps) == 0 {
+		return nil, fmt.Errorf("error getting temperatures: %v", err)
+	}
+
+	var sensors []Sensor
+	seen := make(map[string]int)
+	for _, t := range temps {
+		key := t.SensorKey
+		attr := key[strings.LastIndex(key, "_")+1:]
+		if attr == "input" {
+			key = strings.TrimSuffix(strings.TrimSuffix(key, attr), "_")
+		} else if hwmonLimits[attr] {
+			continue
+		}
+		sensors = appendSensor(sensors, seen, Sensor{Key: key, Celsius: t.Temperature})
+	}
+	return sensors, nil
+}
+
+// hwmonLimits are the temp*_ attributes other than the reading, as they end up
+// in the keys of host.SensorsTemperatures with the underscores removed
+var hwmonLimits = map[string]bool{
+	"max": true, "min": true, "crit": true, "lcrit": true, "emergency": true,
+	"alarm": true, "maxalarm": true, "minalarm": true, "critalarm": true, "lcritalarm": true, "emergencyalarm": true,
+	"maxhyst": true, "minhyst": true, "crithyst": true, "emergencyhyst": true,
+	"lowest": true, "highest": true, "resethistory": true, "offset": true, "type": true, "fault": true, "beep": true, "enable": true,
+}
+
+func appendSensor(sensors []Sensor, seen map[string]int, s Sensor) []Sensor {
+	if n := seen[s.Key]; n > 0 {
+		seen[s.Key]++
+		s.Key = fmt.Sprintf("%s_%d", s.Key, n)
+	} else {
+		seen[s.Key] = 1
+	}
+	if matchAny(sensorConfig.Ignore, s.Key) {
+		return sensors
+	}
+	s.Name = sensorConfig.Names[s.Key]
+	return append(sensors, s)
+}
+
+// GetFans reads the fan speeds from hwmon. Keys follow the temperature ones:
+// the chip name, then the fan label or fanN. Like gopsutil, HOST_SYS points
+// at the host's /sys when running in a container.
+func GetFans() []Fan {
+	sys := os.Getenv("HOST_SYS")
+	if sys == "" {
+		sys = "/sys"
+	}
+	files, err := filepath.Glob(filepath.Join(sys, "class/hwmon/hwmon*/fan*_input"))
+	if err != nil || len(files) == 0 {
+		return nil
+	}
+
+	var fans []Fan
+	seen := make(map[string]int)
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		fan := strings.TrimSuffix(filepath.Base(file), "_input")
+
+		chip, err := os.ReadFile(filepath.Join(dir, "name"))
+		if err != nil {
+			continue
+		}
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		rpm, err := strconv.ParseFloat(strings.TrimSpace(string(raw)), 64)
+		if err != nil {
+			continue
+		}
+		if label, err := os.ReadFile(filepath.Join(dir, fan+"_label")); err == nil {
+			fan = strings.ToLower(strings.Join(strings.Fields(string(label)), ""))
+		}
+
+		// share the naming and dedup rules of the temperatures
+		s := appendSensor(nil, seen, Sensor{Key: strings.TrimSpace(string(chip)) + "_" + fan})
+		if len(s) == 1 {
+			fans = append(fans, Fan{Key: s[0].Key, Name: s[0].Name, RPM: rpm})
+		}
+	}
+	return fans
+}
@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+)
+
+// SystemdUnit is one unit as systemctl reports it
+type SystemdUnit struct {
+	Name        string
+	LoadState   string
+	ActiveState string
+	SubState    string
+	Description string
+}
+
+// SystemdStatus is the overall state of the system manager with the units
+// that failed and the watched ones
+type SystemdStatus struct {
+	State   string // running, degraded, starting, ...
+	Failed  []SystemdUnit
+	Watched []SystemdUnit
+}
+
+var systemdConfig config.SystemdConfig
+
+// SetSystemdConfig sets the units to watch
+func SetSystemdConfig(cfg config.SystemdConfig) {
+	systemdConfig = cfg
+}
+
+// GetSystemdStatus asks systemctl for the failed units and the state of the
+// watched ones. When one of the lists could not be read the error is a
+// *PartialError naming it.
+func GetSystemdStatus(ctx context.Context) (SystemdStatus, error) {
+	var status SystemdStatus
+	// is-system-running exits non-zero for anything but running, the state is on stdout either way
+	out, err := systemctl(ctx, "is-system-running")
+	status.State = strings.TrimSpace(string(out))
+	if status.State == "" {
+		return SystemdStatus{}, fmt.Errorf("error asking systemd for its state: %v", err)
+	}
+
+	partial := &PartialError{}
+	if out, err = systemctl(ctx, "list-units", "--state=failed", "--all", "--plain", "--no-legend"); err != nil {
+		partial.Fields = append(partial.Fields, "failed_units")
+		partial.Err = fmt.Errorf("error listing failed systemd units: %v", err)
+	} else {
+		status.Failed = parseUnitList(out)
+	}
+
+	if len(systemdConfig.Watch) > 0 {
+		args := append([]string{"show", "--property=Id,LoadState,ActiveState,SubState,Description", "--"}, systemdConfig.Watch...)
+		if out, err = systemctl(ctx, args...); err != nil {
+			partial.Fields = append(partial.Fields, "watched_units")
+			partial.Err = fmt.Errorf("error getting the state of watched systemd units: %v", err)
+		} else {
+			status.Watched = parseUnitProperties(out)
+		}
+	}
+
+	if partial.Err != nil {
+		return status, partial
+	}
+	return status, nil
+}
+
+func systemctl(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "systemctl", append([]string{"--no-pager"}, args...)...)
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(out) > 0 {
+		// systemctl uses the exit code to report states, the output is still good
+		return out, nil
+	}
+	return out, err
+}
+
+// parseUnitList parses list-units lines: UNIT LOAD ACTIVE SUB DESCRIPTION.
+// Some versions put a ● in front of failed units even with --plain.
+func parseUnitList(out []byte) []SystemdUnit {
+	var units []SystemdUnit
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "●"))
+		if len(fields) < 4 {
+			continue
+		}
+		units = append(units, SystemdUnit{
+			Name:        fields[0],
+			LoadState:   fields[1],
+			ActiveState: fields[2],
+			SubState:    fields[3],
+			Description: strings.Join(fields[4:], " "),
+		})
+	}
+	return units
+}
+
+// parseUnitProperties parses the output of systemctl show for several units:
+// Key=Value lines, one blank line between units
+func parseUnitProperties(out []byte) []SystemdUnit {
+	var units []SystemdUnit
+	var cur SystemdUnit
+	flush := func() {
+		if cur.Name != "" {
+			units = append(units, cur)
+		}
+		cur = SystemdUnit{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Id":
+			cur.Name = value
+		case "LoadState":
+			cur.LoadState = value
+		case "ActiveState":
+			cur.ActiveState = value
+		case "SubState":
+			cur.SubState = value
+		case "Description":
+			cur.Description = value
+		}
+	}
+	flush()
+	return units
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import "testing"
+
+func TestParseUnitList(t *testing.T) {
+	// systemctl list-units --state=failed --all --plain --no-legend, from systemd 249 and 255
+	out := []byte(`backup-db.service     loaded failed failed Nightly database backup
+● nginx.service        loaded failed failed A high performance web server and a reverse proxy server
+  mnt-nas.mount        not-found failed failed mnt-nas.mount
+broken
+
+`)
+	want := []SystemdUnit{
+		{"backup-db.service", "loaded", "failed", "failed", "Nightly database backup"},
+		{"nginx.service", "loaded", "failed", "failed", "A high performance web server and a reverse proxy server"},
+		{"mnt-nas.mount", "not-found", "failed", "failed", "mnt-nas.mount"},
+	}
+	units := parseUnitList(out)
+	if len(units) != len(want) {
+		t.Fatalf("got %d units, want %d: %+v", len(units), len(want), units)
+	}
+	for i, w := range want {
+		if units[i] != w {
+			t.Errorf("unit %d = %+v, want %+v", i, units[i], w)
+		}
+	}
+
+	if units := parseUnitList(nil); len(units) != 0 {
+		t.Errorf("no failed units parsed as %+v", units)
+	}
+}
+
+func TestParseUnitProperties(t *testing.T) {
+	// systemctl show --property=Id,LoadState,ActiveState,SubState,Description -- nginx.service cron.service nope.service
+	out := []byte(`Id=nginx.service
+LoadState=loaded
+ActiveState=active
+SubState=running
+Description=A high performance web server and a reverse proxy server
+
+Id=cron.service
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+Description=Regular background program processing daemon
+
+Id=nope.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+Description=nope.service
+`)
+	want := []SystemdUnit{
+		{"nginx.service", "loaded", "active", "running", "A high performance web server and a reverse proxy server"},
+		{"cron.service", "loaded", "failed", "failed", "Regular background program processing daemon"},
+		{"nope.service", "not-found", "inactive", "dead", "nope.service"},
+	}
+	units := parseUnitProperties(out)
+	if len(units) != len(want) {
+		t.Fatalf("got %d units, want %d: %+v", len(units), len(want), units)
+	}
+	for i, w := range want {
+		if units[i] != w {
+			t.Errorf("unit %d = %+v, want %+v", i, units[i], w)
+		}
+	}
+}
+
+func TestParseUnitPropertiesOddOutput(t *testing.T) {
+	// properties in another order, a value with = in it, an unknown
+	// property, extra blank lines and a block without an Id
+	out := []byte(`Description=Backup to s3://bucket?region=eu
+ActiveState=activating
+Id=backup.service
+Unexpected=1
+SubState=start
+
+
+LoadState=loaded
+ActiveState=active
+`)
+	units := parseUnitProperties(out)
+	if len(units) != 1 {
+		t.Fatalf("got %d units, want 1: %+v", len(units), units)
+	}
+	want := SystemdUnit{Name: "backup.service", ActiveState: "activating", SubState: "start", Description: "Backup to s3://bucket?region=eu"}
+	if units[0] != want {
+		t.Errorf("unit = %+v, want %+v", units[0], want)
+	}
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+// textfileCollector reads the *.prom files of a directory, the way
+// node_exporter's textfile collector does. A file that cannot be read or
+// parsed is left out whole, so a half written one never shows up, and the
+// collector reports the error without dropping the other files. Every file
+// also gets a textfile_scrape_error and a textfile_mtime_seconds sample. Like
+// node_exporter, a file with a metric of another type than in an earlier file
+// is an error, the server could not expose both.
+type textfileCollector struct {
+	dir string
+}
+
+func (c *textfileCollector) Name() string     { return "textfile" }
+func (c *textfileCollector) Fields() []string { return nil }
+
+func (c *textfileCollector) Collect(_ context.Context, out *Output) error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("error reading the textfile directory: %v", err)
+	}
+
+	var failed []string
+	types := make(map[string]pb.SampleType) // family name -> type, of the files read so far
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".prom") {
+			continue
+		}
+		labels := map[string]string{"file": e.Name()}
+		samples, mtime, err := readTextfile(filepath.Join(c.dir, e.Name()))
+		if err == nil {
+			err = checkFamilyTypes(types, samples)
+		}
+		scrapeError := 0.0
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", e.Name(), err))
+			scrapeError = 1
+		} else {
+			out.Samples = append(out.Samples, samples...)
+			out.Samples = append(out.Samples, &pb.Sample{
+				Name: "textfile_mtime_seconds", Labels: labels, Value: mtime, Unit: "seconds",
+				Help: "When the textfile was last changed, unix seconds.",
+			})
+		}
+		out.Samples = append(out.Samples, &pb.Sample{
+			Name: "textfile_scrape_error", Labels: labels, Value: scrapeError,
+			Help: "1 if the textfile could not be read or parsed.",
+		})
+	}
+
+	if len(failed) > 0 {
+		return &PartialError{Err: errors.New(strings.Join(failed, "; "))}
+	}
+	return nil
+}
+
+// checkFamilyTypes fails if a metric of samples was seen in types with another
+// type, and otherwise adds the metrics to types. A counter foo or foo_total
+// is kept under both names, as it clashes with a gauge of either.
+func checkFamilyTypes(types map[string]pb.SampleType, samples []*pb.Sample) error {
+	own := make(map[string]pb.SampleType)
+	for _, s := range samples {
+		names := []string{s.Name}
+		if s.Type == pb.SampleType_SAMPLE_COUNTER {
+			base := strings.TrimSuffix(s.Name, "_total")
+			names = []string{base, base + "_total"}
+		}
+		for _, n := range names {
+			if t, ok := types[n]; ok && t != s.Type {
+				return fmt.Errorf("%s is a %s in an earlier file", n, typeName(t))
+			}
+			own[n] = s.Type
+		}
+	}
+	for n, t := range own {
+		types[n] = t
+	}
+	return nil
+}
+
+func typeName(t pb.SampleType) string {
+	if t == pb.SampleType_SAMPLE_COUNTER {
+		return "counter"
+	}
+	return "gauge"
+}
+
+// readTextfile parses one file, all of it or nothing, and returns its
+// modification time in unix seconds
+func readTextfile(path string) ([]*pb.Sample, float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	samples, err := parseText(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return samples, float64(info.ModTime().UnixNano()) / 1e9, nil
+}
@@ -0,0 +1,388 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+)
+
+// AgentConfig holds everything the agent needs to know to reach the server
+type AgentConfig struct {
+	ServerAddr        string        `yaml:"server_addr"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	LogLevel          string        `yaml:"log_level"`
+
+	// JoinToken is presented once to enroll with a server that has auth enabled.
+	// The secret the server hands back is stored next to the agent ID.
+	JoinToken string `yaml:"join_token"`
+
+	TLS AgentTLSConfig `yaml:"tls"`
+
+	Buffer BufferConfig `yaml:"buffer"`
+
+	Filesystems FilesystemConfig `yaml:"filesystems"`
+	Network     NetworkConfig    `yaml:"network"`
+	DiskIO      DiskIOConfig     `yaml:"disk_io"`
+	Processes   ProcessesConfig  `yaml:"processes"`
+	Sensors     SensorsConfig    `yaml:"sensors"`
+	Containers  ContainersConfig `yaml:"containers"`
+	Systemd     SystemdConfig    `yaml:"systemd"`
+	Exec        ExecConfig       `yaml:"exec"`
+	Textfile    TextfileConfig   `yaml:"textfile"`
+
+	// Collectors overrides how the metric collectors run, keyed by collector name
+	Collectors map[string]CollectorConfig `yaml:"collectors"`
+}
+
+// BufferConfig bounds the heartbeats kept while the server cannot be reached,
+// to be replayed in order once it is back. The newest MemoryEntries stay in
+// memory, older ones are spilled to files in Directory, which also keeps them
+// across a restart of the agent. Past MaxEntries the oldest are dropped; 0
+// turns buffering off. An empty Directory means a buffer directory in the
+// agent's state directory.
+type BufferConfig struct {
+	MaxEntries    int    `yaml:"max_entries"`
+	MemoryEntries int    `yaml:"memory_entries"`
+	Directory     string `yaml:"directory"`
+}
+
+// CollectorConfig overrides the defaults of one collector. Enabled, when set,
+// wins over the enabled switch of the collector's own section. A collector
+// runs at most once per interval, heartbeats in between repeat its last
+// result; unset keeps the collector's default, which for most is every
+// heartbeat. A run that takes longer than the timeout is reported unavailable.
+type CollectorConfig struct {
+	Enabled  *bool         `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// FilesystemConfig picks the mounted filesystems the agent reports. Include
+// lists, when set, are the only ones reported; exclude lists win over them.
+// Mountpoint patterns are filepath.Match globs and also cover everything
+// mounted below a match, so "/run" skips /run/user/1000 as well.
+type FilesystemConfig struct {
+	IncludeFSTypes     []string `yaml:"include_fstypes"`
+	ExcludeFSTypes     []string `yaml:"exclude_fstypes"`
+	IncludeMountpoints []string `yaml:"include_mountpoints"`
+	ExcludeMountpoints []string `yaml:"exclude_mountpoints"`
+}
+
+// NetworkConfig picks the interfaces reported one by one, by filepath.Match
+// glob on the name. Exclude wins over include.
+type NetworkConfig struct {
+	IncludeInterfaces []string `yaml:"include_interfaces"`
+	ExcludeInterfaces []string `yaml:"exclude_interfaces"`
+}
+
+// DiskIOConfig picks the block devices reported one by one, by filepath.Match
+// glob on the name. Partitions are left out unless an include pattern names
+// them, their I/O is already counted on the whole disk.
+type DiskIOConfig struct {
+	IncludeDevices []string `yaml:"include_devices"`
+	ExcludeDevices []string `yaml:"exclude_devices"`
+}
+
+// ProcessesConfig turns on reporting the busiest processes with every heartbeat
+type ProcessesConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	Top           int  `yaml:"top"`            // processes reported by CPU and by memory
+	CmdlineLength int  `yaml:"cmdline_length"` // longer command lines are cut off
+}
+
+// SensorsConfig names temperature sensors and fans. Names maps a sensor key as
+// reported by the agent (e.g. "k10temp_tctl") to a logical name (e.g. "cpu").
+// When any sensor is named "cpu", cpu_temp is the hottest of those instead of
+// a guess. Sensors matching an Ignore glob are not reported.
+type SensorsConfig struct {
+	Names  map[string]string `yaml:"names"`
+	Ignore []string          `yaml:"ignore"`
+}
+
+// ContainersConfig turns on reporting the containers of a local Docker or
+// Podman engine, asked over its API socket. How often is set under
+// collectors.containers.
+type ContainersConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Socket  string `yaml:"socket"` // e.g. /run/podman/podman.sock for Podman
+}
+
+// SystemdConfig turns on reporting failed systemd units and the state of the
+// units in Watch, asked from systemctl. How often is set under collectors.systemd.
+type SystemdConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Watch   []string `yaml:"watch"` // e.g. nginx.service, zfs-scrub.timer
+}
+
+// ExecConfig runs external commands and reports what they print as samples.
+// Each command is a collector named exec_<name>. At most MaxConcurrent
+// commands run at the same time, waiting for a turn counts towards the timeout.
+type ExecConfig struct {
+	MaxConcurrent int           `yaml:"max_concurrent"`
+	Commands      []ExecCommand `yaml:"commands"`
+}
+
+// ExecCommand is one command to run. Command is run directly, not through a
+// shell. Format is text, the Prometheus text format, or json, an array of
+// samples. Interval and Timeout default to a minute and 10 seconds.
+type ExecCommand struct {
+	Name     string        `yaml:"name"`
+	Command  []string      `yaml:"command"` // e.g. ["upsc", "ups@localhost"]
+	Format   string        `yaml:"format"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// TextfileConfig reads the *.prom files in Directory, in the Prometheus text
+// format, as written for node_exporter's textfile collector. Empty turns it
+// off. How often is set under collectors.textfile.
+type TextfileConfig struct {
+	Directory string `yaml:"directory"`
+}
+
+// AgentTLSConfig controls how the agent connects to the server. cert_file and
+// key_file are only needed when the server requires client certificates.
+type AgentTLSConfig struct {
+	Enabled            bool          `yaml:"enabled"`
+	CAFile             string        `yaml:"ca_file"` // empty uses the system roots
+	CertFile           string        `yaml:"cert_file"`
+	KeyFile            string        `yaml:"key_file"`
+	ServerName         string        `yaml:"server_name"` // overrides the name checked against the server certificate
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify"`
+	ReloadInterval     time.Duration `yaml:"reload_interval"`
+}
+
+func DefaultAgentConfig() AgentConfig {
+	return AgentConfig{
+		ServerAddr:        "localhost:5001",
+		HeartbeatInterval: time.Second,
+		LogLevel:          "info",
+		TLS: AgentTLSConfig{
+			ReloadInterval: 30 * time.Second,
+		},
+		Buffer: BufferConfig{
+			MaxEntries:    3600,
+			MemoryEntries: 120,
+		},
+		Filesystems: FilesystemConfig{
+			ExcludeFSTypes: []string{
+				"tmpfs", "devtmpfs", "overlay", "squashfs", "proc", "sysfs", "cgroup", "cgroup2",
+				"devpts", "mqueue", "debugfs", "tracefs", "securityfs", "pstore", "bpf", "autofs",
+				"configfs", "fusectl", "hugetlbfs", "binfmt_misc", "nsfs", "ramfs", "efivarfs", "rpc_pipefs",
+			},
+			ExcludeMountpoints: []string{"/proc", "/sys", "/dev", "/run", "/snap/*", "/var/lib/docker/*", "/var/lib/containers/*"},
+		},
+		Network: NetworkConfig{
+			ExcludeInterfaces: []string{"lo", "veth*", "docker*", "br-*", "virbr*", "cni*", "flannel*", "cali*", "tap*"},
+		},
+		DiskIO: DiskIOConfig{
+			ExcludeDevices: []string{"loop*", "ram*", "zram*", "fd*", "sr*"},
+		},
+		Processes: ProcessesConfig{
+			Top:           10,
+			CmdlineLength: 256,
+		},
+		Containers: ContainersConfig{
+			Socket: "/var/run/docker.sock",
+		},
+		Exec: ExecConfig{
+			MaxConcurrent: 4,
+		},
+	}
+}
+
+// LoadAgentConfig resolves the agent config from defaults, the config file,
+// GLIMPSE_* env vars and the given command line args
+func LoadAgentConfig(args []string) (*AgentConfig, error) {
+	return load[AgentConfig]("glimpse-agent", args, DefaultAgentConfig)
+}
+
+func (c *AgentConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.ServerAddr, "server", c.ServerAddr, "Address (host:port) of the glimpse server")
+	fs.DurationVar(&c.HeartbeatInterval, "interval", c.HeartbeatInterval, "Interval between heartbeats")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "Log level (debug, info, warn, error)")
+	fs.StringVar(&c.JoinToken, "join-token", c.JoinToken, "Join token used to enroll with the server")
+	fs.BoolVar(&c.TLS.Enabled, "tls", c.TLS.Enabled, "Connect to the server over TLS")
+	fs.StringVar(&c.TLS.CAFile, "tls-ca", c.TLS.CAFile, "CA bundle used to verify the server certificate")
+	fs.StringVar(&c.TLS.CertFile, "tls-cert", c.TLS.CertFile, "Client certificate for mutual TLS")
+	fs.StringVar(&c.TLS.KeyFile, "tls-key", c.TLS.KeyFile, "Client private key for mutual TLS")
+	fs.StringVar(&c.TLS.ServerName, "tls-server-name", c.TLS.ServerName, "Server name to verify the server certificate against")
+	fs.BoolVar(&c.Processes.Enabled, "processes", c.Processes.Enabled, "Report the busiest processes with every heartbeat")
+	fs.BoolVar(&c.Containers.Enabled, "containers", c.Containers.Enabled, "Report the containers of the local Docker or Podman engine")
+	fs.BoolVar(&c.Systemd.Enabled, "systemd", c.Systemd.Enabled, "Report failed and watched systemd units")
+	fs.StringVar(&c.Textfile.Directory, "textfile-directory", c.Textfile.Directory, "Directory of *.prom files to report")
+	fs.Var(collectorsFlag{c, true}, "enable-collectors", "Comma separated collectors to turn on")
+	fs.Var(collectorsFlag{c, false}, "disable-collectors", "Comma separated collectors to turn off")
+	fs.Var(debugFlag{&c.LogLevel}, "debug", "Enable debug logging (same as -log-level=debug)")
+}
+
+func (c *AgentConfig) applyEnv(lookup func(string) (string, bool)) error {
+	envString(lookup, "GLIMPSE_SERVER_ADDR", &c.ServerAddr)
+	envString(lookup, "GLIMPSE_LOG_LEVEL", &c.LogLevel)
+	envString(lookup, "GLIMPSE_JOIN_TOKEN", &c.JoinToken)
+	envString(lookup, "GLIMPSE_TLS_CA_FILE", &c.TLS.CAFile)
+	envString(lookup, "GLIMPSE_TLS_CERT_FILE", &c.TLS.CertFile)
+	envString(lookup, "GLIMPSE_TLS_KEY_FILE", &c.TLS.KeyFile)
+	envString(lookup, "GLIMPSE_TLS_SERVER_NAME", &c.TLS.ServerName)
+	if err := envBool(lookup, "GLIMPSE_TLS", &c.TLS.Enabled); err != nil {
+		return err
+	}
+	envList(lookup, "GLIMPSE_FS_INCLUDE_FSTYPES", &c.Filesystems.IncludeFSTypes)
+	envList(lookup, "GLIMPSE_FS_EXCLUDE_FSTYPES", &c.Filesystems.ExcludeFSTypes)
+	envList(lookup, "GLIMPSE_FS_INCLUDE_MOUNTPOINTS", &c.Filesystems.IncludeMountpoints)
+	envList(lookup, "GLIMPSE_FS_EXCLUDE_MOUNTPOINTS", &c.Filesystems.ExcludeMountpoints)
+	envList(lookup, "GLIMPSE_NET_INCLUDE_INTERFACES", &c.Network.IncludeInterfaces)
+	envList(lookup, "GLIMPSE_NET_EXCLUDE_INTERFACES", &c.Network.ExcludeInterfaces)
+	envList(lookup, "GLIMPSE_DISK_INCLUDE_DEVICES", &c.DiskIO.IncludeDevices)
+	envList(lookup, "GLIMPSE_DISK_EXCLUDE_DEVICES", &c.DiskIO.ExcludeDevices)
+	if err := envBool(lookup, "GLIMPSE_PROCESSES", &c.Processes.Enabled); err != nil {
+		return err
+	}
+	if err := envInt(lookup, "GLIMPSE_PROCESSES_TOP", &c.Processes.Top); err != nil {
+		return err
+	}
+	envList(lookup, "GLIMPSE_SENSORS_IGNORE", &c.Sensors.Ignore)
+	if err := envBool(lookup, "GLIMPSE_CONTAINERS", &c.Containers.Enabled); err != nil {
+		return err
+	}
+	envString(lookup, "GLIMPSE_CONTAINERS_SOCKET", &c.Containers.Socket)
+	if err := envBool(lookup, "GLIMPSE_SYSTEMD", &c.Systemd.Enabled); err != nil {
+		return err
+	}
+	envList(lookup, "GLIMPSE_SYSTEMD_WATCH", &c.Systemd.Watch)
+	envString(lookup, "GLIMPSE_TEXTFILE_DIRECTORY", &c.Textfile.Directory)
+	envString(lookup, "GLIMPSE_BUFFER_DIRECTORY", &c.Buffer.Directory)
+	if err := envInt(lookup, "GLIMPSE_BUFFER_MAX_ENTRIES", &c.Buffer.MaxEntries); err != nil {
+		return err
+	}
+	if v, ok := lookup("GLIMPSE_COLLECTORS_ENABLE"); ok {
+		c.setCollectorsEnabled(splitList(v), true)
+	}
+	if v, ok := lookup("GLIMPSE_COLLECTORS_DISABLE"); ok {
+		c.setCollectorsEnabled(splitList(v), false)
+	}
+	return envDuration(lookup, "GLIMPSE_HEARTBEAT_INTERVAL", &c.HeartbeatInterval)
+}
+
+func (c *AgentConfig) Validate() error {
+	if err := validateAddr("server_addr", c.ServerAddr); err != nil {
+		return err
+	}
+	if c.HeartbeatInterval < 100*time.Millisecond {
+		return errors.New("heartbeat_interval must be at least 100ms")
+	}
+	if !logger.ValidLevel(c.LogLevel) {
+		return fmt.Errorf("unknown log_level %q", c.LogLevel)
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+	if err := c.Filesystems.Validate(); err != nil {
+		return err
+	}
+	if err := validatePatterns("network interface", c.Network.IncludeInterfaces, c.Network.ExcludeInterfaces); err != nil {
+		return err
+	}
+	if err := validatePatterns("disk_io device", c.DiskIO.IncludeDevices, c.DiskIO.ExcludeDevices); err != nil {
+		return err
+	}
+	if err := validatePatterns("sensors ignore", c.Sensors.Ignore); err != nil {
+		return err
+	}
+	if c.Containers.Enabled && c.Containers.Socket == "" {
+		return errors.New("containers.socket must be set when containers are enabled")
+	}
+	if c.Buffer.MaxEntries < 0 {
+		return errors.New("buffer.max_entries must not be negative")
+	}
+	if c.Buffer.MemoryEntries < 1 {
+		return errors.New("buffer.memory_entries must be at least 1")
+	}
+	if err := c.Exec.Validate(); err != nil {
+		return err
+	}
+	for name, cc := range c.Collectors {
+		if cc.Interval < 0 || cc.Timeout < 0 {
+			return fmt.Errorf("collectors.%s: interval and timeout must not be negative", name)
+		}
+	}
+	if c.Processes.Top < 1 || c.Processes.Top > 100 {
+		return errors.New("processes.top must be between 1 and 100")
+	}
+	if c.Processes.CmdlineLength < 0 {
+		return errors.New("processes.cmdline_length must not be negative")
+	}
+	return nil
+}
+
+// setCollectorsEnabled turns the named collectors on or off, whatever their
+// own sections say
+func (c *AgentConfig) setCollectorsEnabled(names []string, enabled bool) {
+	if c.Collectors == nil {
+		c.Collectors = make(map[string]CollectorConfig)
+	}
+	for _, name := range names {
+		cc := c.Collectors[name]
+		cc.Enabled = &enabled
+		c.Collectors[name] = cc
+	}
+}
+
+var execNameRE = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func (e ExecConfig) Validate() error {
+	if e.MaxConcurrent < 1 {
+		return errors.New("exec.max_concurrent must be at least 1")
+	}
+	seen := make(map[string]bool)
+	for i, cmd := range e.Commands {
+		if !execNameRE.MatchString(cmd.Name) {
+			return fmt.Errorf("exec.commands[%d]: name must be letters, digits, _ and -", i)
+		}
+		if seen[cmd.Name] {
+			return fmt.Errorf("exec.commands: %q is listed twice", cmd.Name)
+		}
+		seen[cmd.Name] = true
+		if len(cmd.Command) == 0 || cmd.Command[0] == "" {
+			return fmt.Errorf("exec.commands.%s: command must be set", cmd.Name)
+		}
+		if cmd.Format != "" && cmd.Format != "text" && cmd.Format != "json" {
+			return fmt.Errorf("exec.commands.%s: unknown format %q, use text or json", cmd.Name, cmd.Format)
+		}
+		if cmd.Interval < 0 || cmd.Timeout < 0 {
+			return fmt.Errorf("exec.commands.%s: interval and timeout must not be negative", cmd.Name)
+		}
+	}
+	return nil
+}
+
+func (f FilesystemConfig) Validate() error {
+	return validatePatterns("filesystems mountpoint", f.IncludeMountpoints, f.ExcludeMountpoints)
+}
+
+// validatePatterns checks that every entry is a valid filepath.Match pattern
+func validatePatterns(what string, lists ...[]string) error {
+	for _, list := range lists {
+		for _, p := range list {
+			if _, err := filepath.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid %s pattern %q", what, p)
+			}
+		}
+	}
+	return nil
+}
+
+func (t AgentTLSConfig) Validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+	if !t.Enabled && (t.CAFile != "" || t.CertFile != "" || t.ServerName != "") {
+		return errors.New("tls settings are present but tls.enabled is false")
+	}
+	return nil
+}
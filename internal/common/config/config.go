@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config values are resolved in this order, later sources winning:
+//  1. built-in defaults
+//  2. the YAML file given by -config (or GLIMPSE_CONFIG)
+//  3. GLIMPSE_* environment variables
+//  4. command line flags that were explicitly set
+//
+// The flags are registered against the config struct twice: once to parse the
+// command line (which also tells us where the config file is), and once more
+// against the final struct so that only the flags the user actually passed are
+// replayed on top of the file and env values. The replay parses the command
+// line again, so flags that set the same value apply in the order they were
+// given and the last one wins: -debug -log-level=info logs at info.
+
+const configEnvVar = "GLIMPSE_CONFIG"
+
+// loader is implemented by AgentConfig and ServerConfig
+type loader[T any] interface {
+	*T
+	registerFlags(fs *flag.FlagSet)
+	applyEnv(lookup func(string) (string, bool)) error
+	Validate() error
+}
+
+func load[T any, P loader[T]](name string, args []string, defaults func() T) (*T, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(configEnvVar), "Path to YAML config file")
+
+	parsed := defaults()
+	P(&parsed).registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaults()
+	if *configPath != "" {
+		if err := loadFile(*configPath, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := P(&cfg).applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	// replay explicitly set flags on top of file + env, in command line order
+	replay := flag.NewFlagSet(name, flag.ContinueOnError)
+	replay.SetOutput(io.Discard)
+	replay.String("config", "", "")
+	P(&cfg).registerFlags(replay)
+	if err := replay.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := P(&cfg).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &cfg, nil
+}
+
+func loadFile(path string, out interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return nil
+}
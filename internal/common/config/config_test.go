@@ -0,0 +1,195 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a YAML config file and returns its path
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "glimpse.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestServerDefaults(t *testing.T) {
+	cfg, err := LoadServerConfig(nil)
+	if err != nil {
+		t.Fatalf("LoadServerConfig: %v", err)
+	}
+	if cfg.GRPCListenAddr != ":5001" || cfg.HTTPListenAddr != ":5000" || cfg.HistorySize != 60 || cfg.LogLevel != "info" {
+		t.Errorf("defaults = %+v", cfg)
+	}
+}
+
+func TestServerPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+grpc_listen_addr: ":6001"
+http_listen_addr: ":6000"
+history_size: 100
+log_level: warn
+data_dir: /srv/glimpse
+status:
+  late_after: 5
+  offline_after: 20
+`)
+	t.Setenv("GLIMPSE_HTTP_LISTEN_ADDR", ":7000")
+	t.Setenv("GLIMPSE_LOG_LEVEL", "error")
+	t.Setenv("GLIMPSE_HISTORY_SIZE", "200")
+
+	cfg, err := LoadServerConfig([]string{"-config", path, "-history-size", "300", "-port", "8001"})
+	if err != nil {
+		t.Fatalf("LoadServerConfig: %v", err)
+	}
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		// set in the file only
+		{"data_dir", cfg.DataDir, "/srv/glimpse"},
+		{"status.late_after", cfg.Status.LateAfter, 5},
+		// not in the file, so the default stays
+		{"status.sweep_interval", cfg.Status.SweepInterval, time.Second},
+		// env wins over the file
+		{"http_listen_addr", cfg.HTTPListenAddr, ":7000"},
+		{"log_level", cfg.LogLevel, "error"},
+		// flags win over env and the file. -port keeps the host of the file address
+		{"history_size", cfg.HistorySize, 300},
+		{"grpc_listen_addr", cfg.GRPCListenAddr, ":8001"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestConfigPathFromEnv(t *testing.T) {
+	t.Setenv(configEnvVar, writeConfig(t, "history_size: 42\n"))
+	cfg, err := LoadServerConfig(nil)
+	if err != nil {
+		t.Fatalf("LoadServerConfig: %v", err)
+	}
+	if cfg.HistorySize != 42 {
+		t.Errorf("history_size = %d, want 42 from the file in %s", cfg.HistorySize, configEnvVar)
+	}
+}
+
+func TestFlagsApplyInOrder(t *testing.T) {
+	t.Setenv("GLIMPSE_LOG_LEVEL", "warn")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "warn"},
+		{[]string{"-debug"}, "debug"},
+		{[]string{"-debug", "-log-level=info"}, "info"},
+		{[]string{"-log-level=info", "-debug"}, "debug"},
+		// -debug=false leaves the level alone
+		{[]string{"-debug=false"}, "warn"},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadAgentConfig(tt.args)
+		if err != nil {
+			t.Errorf("LoadAgentConfig(%v): %v", tt.args, err)
+			continue
+		}
+		if cfg.LogLevel != tt.want {
+			t.Errorf("LoadAgentConfig(%v) log_level = %q, want %q", tt.args, cfg.LogLevel, tt.want)
+		}
+	}
+}
+
+func TestAgentCollectorsPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+collectors:
+  processes:
+    enabled: true
+    interval: 10s
+  sensors:
+    enabled: true
+`)
+	t.Setenv("GLIMPSE_COLLECTORS_DISABLE", "processes,sensors")
+	t.Setenv("GLIMPSE_HEARTBEAT_INTERVAL", "5s")
+
+	cfg, err := LoadAgentConfig([]string{"-config", path, "-enable-collectors", "sensors,systemd", "-disable-collectors", "systemd"})
+	if err != nil {
+		t.Fatalf("LoadAgentConfig: %v", err)
+	}
+	if cfg.HeartbeatInterval != 5*time.Second {
+		t.Errorf("heartbeat_interval = %s, want 5s from env", cfg.HeartbeatInterval)
+	}
+	enabled := func(name string) bool {
+		e := cfg.Collectors[name].Enabled
+		return e != nil && *e
+	}
+	if enabled("processes") {
+		t.Error("processes is enabled, want it turned off by env")
+	}
+	if cfg.Collectors["processes"].Interval != 10*time.Second {
+		t.Errorf("processes interval = %s, want 10s from the file", cfg.Collectors["processes"].Interval)
+	}
+	if !enabled("sensors") {
+		t.Error("sensors is disabled, want it turned back on by the flag")
+	}
+	if enabled("systemd") {
+		t.Error("systemd is enabled, want the later -disable-collectors to win")
+	}
+}
+
+func TestInvalidServerConfig(t *testing.T) {
+	tests := []struct {
+		name, yaml, err string
+	}{
+		{"unknown field", "histroy_size: 10\n", "field histroy_size not found"},
+		{"wrong type", "history_size: lots\n", "cannot unmarshal"},
+		{"history size", "history_size: 0\n", "history_size must be at least 1"},
+		{"log level", "log_level: loud\n", `unknown log_level "loud"`},
+		{"listen addr", "grpc_listen_addr: localhost\n", "grpc_listen_addr"},
+		{"status order", "status:\n  late_after: 5\n  offline_after: 5\n", "status.offline_after must be greater than status.late_after"},
+		{"join token", "auth:\n  enabled: true\n  join_tokens: [short]\n", "at least 16 characters"},
+		{"tls pair", "tls:\n  cert_file: server.crt\n", "tls.cert_file and tls.key_file must be set together"},
+		{"rollup order", "storage:\n  rollups:\n    - {resolution: 5m, retention: 24h}\n    - {resolution: 1m, retention: 48h}\n", "storage.rollups[1].resolution must be coarser"},
+		{"alert notifier", "alerts:\n  rules:\n    - {name: cpu, expr: cpu_usage > 90, notify: [pager]}\n", `unknown notifier "pager"`},
+	}
+	for _, tt := range tests {
+		_, err := LoadServerConfig([]string{"-config", writeConfig(t, tt.yaml)})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestInvalidAgentConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		{"env bool", map[string]string{"GLIMPSE_TLS": "sometimes"}, nil, "invalid value for GLIMPSE_TLS"},
+		{"env duration", map[string]string{"GLIMPSE_HEARTBEAT_INTERVAL": "5"}, nil, "invalid value for GLIMPSE_HEARTBEAT_INTERVAL"},
+		{"env interval", map[string]string{"GLIMPSE_HEARTBEAT_INTERVAL": "10ms"}, nil, "heartbeat_interval must be at least 100ms"},
+		{"flag interval", nil, []string{"-interval", "10ms"}, "heartbeat_interval must be at least 100ms"},
+		{"unknown flag", nil, []string{"-verbose"}, "flag provided but not defined"},
+		{"missing file", nil, []string{"-config", "/nonexistent/glimpse.yaml"}, "error opening config file"},
+		{"server addr", map[string]string{"GLIMPSE_SERVER_ADDR": "no-port"}, nil, "server_addr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := LoadAgentConfig(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// debugFlag keeps the old -debug switch working as a shortcut for -log-level=debug
+type debugFlag struct {
+	level *string
+}
+
+func (d debugFlag) IsBoolFlag() bool { return true }
+
+func (d debugFlag) String() string {
+	if d.level != nil && *d.level == "debug" {
+		return "true"
+	}
+	return "false"
+}
+
+func (d debugFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if v {
+		*d.level = "debug"
+	}
+	return nil
+}
+
+// portFlag keeps the old server -port flag working by rewriting the port of a listen address
+type portFlag struct {
+	addr *string
+}
+
+func (p portFlag) String() string {
+	if p.addr == nil {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(*p.addr)
+	if err != nil {
+		return ""
+	}
+	return port
+}
+
+func (p portFlag) Set(s string) error {
+	port, err := strconv.Atoi(s)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port: %q", s)
+	}
+	host, _, _ := net.SplitHostPort(*p.addr)
+	*p.addr = net.JoinHostPort(host, s)
+	return nil
+}
+
+// collectorsFlag turns a comma separated list of collectors on or off
+type collectorsFlag struct {
+	cfg     *AgentConfig
+	enabled bool
+}
+
+func (f collectorsFlag) String() string { return "" }
+
+func (f collectorsFlag) Set(s string) error {
+	f.cfg.setCollectorsEnabled(splitList(s), f.enabled)
+	return nil
+}
+
+// env helpers. Each one leaves the target untouched when the variable is unset.
+
+func envString(lookup func(string) (string, bool), key string, dst *string) {
+	if v, ok := lookup(key); ok {
+		*dst = v
+	}
+}
+
+func envList(lookup func(string) (string, bool), key string, dst *[]string) {
+	if v, ok := lookup(key); ok {
+		*dst = splitList(v)
+	}
+}
+
+// splitList splits a comma separated env value, dropping empty entries
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func envBool(lookup func(string) (string, bool), key string, dst *bool) error {
+	v, ok := lookup(key)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+func envInt(lookup func(string) (string, bool), key string, dst *int) error {
+	v, ok := lookup(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envDuration(lookup func(string) (string, bool), key string, dst *time.Duration) error {
+	v, ok := lookup(key)
+	if !ok {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
+
+func validateAddr(name, addr string) error {
+	if addr == "" {
+		return fmt.Errorf("%s must not be empty", name)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("%s %q: %w", name, addr, err)
+	}
+	return nil
+}
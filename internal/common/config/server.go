@@ -0,0 +1,465 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+)
+
+// ServerConfig holds the listen addresses and storage settings of the server
+type ServerConfig struct {
+	GRPCListenAddr string `yaml:"grpc_listen_addr"`
+	HTTPListenAddr string `yaml:"http_listen_addr"`
+	HistorySize    int    `yaml:"history_size"` // number of samples kept in memory per agent
+	LogLevel       string `yaml:"log_level"`
+
+	// AgentHeartbeatInterval is pushed to streaming agents when they connect. 0 leaves agents alone.
+	AgentHeartbeatInterval time.Duration `yaml:"agent_heartbeat_interval"`
+
+	// DataDir holds everything the server persists
+	DataDir string `yaml:"data_dir"`
+
+	TLS     ServerTLSConfig     `yaml:"tls"`
+	Auth    ServerAuthConfig    `yaml:"auth"`
+	Storage ServerStorageConfig `yaml:"storage"`
+	Status  ServerStatusConfig  `yaml:"status"`
+	Alerts  ServerAlertsConfig  `yaml:"alerts"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Export        ExportConfig        `yaml:"export"`
+}
+
+// ExportConfig forwards every received heartbeat to Prometheus remote-write
+// endpoints and OTLP/HTTP receivers. Each target has its own queue of up to
+// QueueSize samples, sent in batches of BatchSize at least every FlushInterval.
+type ExportConfig struct {
+	RemoteWrite   []ExportTargetConfig `yaml:"remote_write"`
+	OTLP          []ExportTargetConfig `yaml:"otlp"`
+	QueueSize     int                  `yaml:"queue_size"`
+	BatchSize     int                  `yaml:"batch_size"`
+	FlushInterval time.Duration        `yaml:"flush_interval"`
+	MaxRetries    int                  `yaml:"max_retries"`
+}
+
+type ExportTargetConfig struct {
+	Name    string            `yaml:"name"` // used in logs and metrics, defaults to the URL host
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"` // per request, defaults to 10s
+}
+
+// NotificationsConfig lists where alerts are sent. Rules pick notifiers by
+// name with their notify list, rules without one use Default.
+type NotificationsConfig struct {
+	Notifiers    []NotifierConfig `yaml:"notifiers"`
+	Default      []string         `yaml:"default"`
+	SendResolved bool             `yaml:"send_resolved"`
+	RateLimit    int              `yaml:"rate_limit"`  // max notifications per notifier per minute, extra ones are dropped
+	MaxRetries   int              `yaml:"max_retries"` // retries after a failed send, with exponential backoff
+}
+
+// NotifierConfig is one notification channel. Type is one of webhook, smtp,
+// ntfy, gotify, slack, discord or matrix. Everything but smtp posts to URL.
+type NotifierConfig struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`
+	URL     string            `yaml:"url"`
+	Token   string            `yaml:"token"`   // ntfy access token or gotify app token
+	Headers map[string]string `yaml:"headers"` // extra headers for webhook requests
+	SMTP    SMTPConfig        `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	StartTLS bool     `yaml:"starttls"` // upgrade the connection before authenticating
+}
+
+// ServerAlertsConfig lists the alert rules evaluated against agent metrics.
+// Rules are checked every EvalInterval; resolved alerts stay visible for KeepResolved.
+type ServerAlertsConfig struct {
+	EvalInterval time.Duration     `yaml:"eval_interval"`
+	KeepResolved time.Duration     `yaml:"keep_resolved"`
+	Rules        []AlertRuleConfig `yaml:"rules"`
+}
+
+// AlertRuleConfig is one alert rule. Expr is either "<field> <op> <value> [for <duration>]",
+// e.g. "cpu_usage > 90 for 5m", or "agent offline|late [for <duration>]".
+type AlertRuleConfig struct {
+	Name     string   `yaml:"name"`
+	Expr     string   `yaml:"expr"`
+	Severity string   `yaml:"severity"` // free form, defaults to warning
+	Notify   []string `yaml:"notify"`   // notifier names, defaults to notifications.default
+}
+
+// ServerStatusConfig sets when an agent that stopped sending heartbeats is
+// marked late, offline and finally retired. LateAfter and OfflineAfter count
+// missed heartbeat intervals, so they follow whatever interval the agent uses.
+type ServerStatusConfig struct {
+	LateAfter     int           `yaml:"late_after"`
+	OfflineAfter  int           `yaml:"offline_after"`
+	RetireAfter   time.Duration `yaml:"retire_after"`   // retired agents are hidden from the dashboard. 0 never retires
+	SweepInterval time.Duration `yaml:"sweep_interval"` // how often agent status is re-evaluated
+}
+
+// ServerStorageConfig selects where metric history is kept. "disk" writes
+// segment files under <data_dir>/history, "memory" keeps only the ring buffer
+// and the rollups. Retention applies to raw samples, each rollup tier keeps
+// min/max/avg buckets of its resolution for its own retention.
+type ServerStorageConfig struct {
+	Type      string         `yaml:"type"`
+	Retention time.Duration  `yaml:"retention"`
+	Rollups   []RollupConfig `yaml:"rollups"`
+}
+
+type RollupConfig struct {
+	Resolution time.Duration `yaml:"resolution"`
+	Retention  time.Duration `yaml:"retention"`
+}
+
+// ServerTLSConfig enables TLS on the gRPC listener when cert_file is set.
+// Setting client_ca_file turns on client certificate verification (mutual TLS).
+type ServerTLSConfig struct {
+	CertFile       string        `yaml:"cert_file"`
+	KeyFile        string        `yaml:"key_file"`
+	ClientCAFile   string        `yaml:"client_ca_file"`
+	ClientAuth     string        `yaml:"client_auth"`     // none, request or require. defaults to require when client_ca_file is set
+	ReloadInterval time.Duration `yaml:"reload_interval"` // how often the files are checked for changes
+}
+
+func (t ServerTLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// ServerAuthConfig turns on agent enrollment. Agents enroll once with one of the
+// join tokens and then authenticate every call with the secret they were issued.
+type ServerAuthConfig struct {
+	Enabled         bool     `yaml:"enabled"`
+	JoinTokens      []string `yaml:"join_tokens"`
+	CredentialsFile string   `yaml:"credentials_file"` // defaults to <data_dir>/credentials.json
+	AdminToken      string   `yaml:"admin_token"`      // bearer token for the admin HTTP API. empty disables it
+}
+
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		GRPCListenAddr: ":5001",
+		HTTPListenAddr: ":5000",
+		HistorySize:    60,
+		LogLevel:       "info",
+		DataDir:        "data",
+		TLS: ServerTLSConfig{
+			ReloadInterval: 30 * time.Second,
+		},
+		Storage: ServerStorageConfig{
+			Type:      "disk",
+			Retention: 10 * time.Minute,
+			Rollups: []RollupConfig{
+				{Resolution: time.Minute, Retention: 24 * time.Hour},
+				{Resolution: 15 * time.Minute, Retention: 30 * 24 * time.Hour},
+			},
+		},
+		Status: ServerStatusConfig{
+			LateAfter:     3,
+			OfflineAfter:  10,
+			RetireAfter:   24 * time.Hour,
+			SweepInterval: time.Second,
+		},
+		Alerts: ServerAlertsConfig{
+			EvalInterval: 5 * time.Second,
+			KeepResolved: time.Hour,
+		},
+		Notifications: NotificationsConfig{
+			SendResolved: true,
+			RateLimit:    10,
+			MaxRetries:   5,
+		},
+		Export: ExportConfig{
+			QueueSize:     10000,
+			BatchSize:     500,
+			FlushInterval: 5 * time.Second,
+			MaxRetries:    5,
+		},
+	}
+}
+
+// LoadServerConfig resolves the server config from defaults, the config file,
+// GLIMPSE_* env vars and the given command line args
+func LoadServerConfig(args []string) (*ServerConfig, error) {
+	return load[ServerConfig]("glimpse-server", args, DefaultServerConfig)
+}
+
+func (c *ServerConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.GRPCListenAddr, "grpc-listen", c.GRPCListenAddr, "Listen address for the gRPC server")
+	fs.StringVar(&c.HTTPListenAddr, "http-listen", c.HTTPListenAddr, "Listen address for the HTTP dashboard")
+	fs.IntVar(&c.HistorySize, "history-size", c.HistorySize, "Number of samples kept in memory per agent")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "Log level (debug, info, warn, error)")
+	fs.DurationVar(&c.AgentHeartbeatInterval, "agent-interval", c.AgentHeartbeatInterval, "Heartbeat interval pushed to streaming agents (0 to let agents decide)")
+	fs.StringVar(&c.DataDir, "data-dir", c.DataDir, "Directory the server keeps its state in")
+	fs.StringVar(&c.Storage.Type, "storage", c.Storage.Type, "History storage: disk or memory")
+	fs.DurationVar(&c.Storage.Retention, "retention", c.Storage.Retention, "How long raw samples are kept")
+	fs.DurationVar(&c.Status.RetireAfter, "retire-after", c.Status.RetireAfter, "Hide agents from the dashboard after this long without a heartbeat (0 to never)")
+	fs.BoolVar(&c.Auth.Enabled, "auth", c.Auth.Enabled, "Require agents to enroll and authenticate")
+	fs.StringVar(&c.TLS.CertFile, "tls-cert", c.TLS.CertFile, "TLS certificate file for the gRPC server")
+	fs.StringVar(&c.TLS.KeyFile, "tls-key", c.TLS.KeyFile, "TLS private key file for the gRPC server")
+	fs.StringVar(&c.TLS.ClientCAFile, "tls-client-ca", c.TLS.ClientCAFile, "CA bundle used to verify agent certificates (enables mutual TLS)")
+	fs.StringVar(&c.TLS.ClientAuth, "tls-client-auth", c.TLS.ClientAuth, "Client certificate policy: none, request or require")
+	fs.Var(portFlag{&c.GRPCListenAddr}, "port", "Port to listen on for gRPC (overrides the port of -grpc-listen)")
+	fs.Var(debugFlag{&c.LogLevel}, "debug", "Enable debug logging (same as -log-level=debug)")
+}
+
+func (c *ServerConfig) applyEnv(lookup func(string) (string, bool)) error {
+	envString(lookup, "GLIMPSE_GRPC_LISTEN_ADDR", &c.GRPCListenAddr)
+	envString(lookup, "GLIMPSE_HTTP_LISTEN_ADDR", &c.HTTPListenAddr)
+	envString(lookup, "GLIMPSE_LOG_LEVEL", &c.LogLevel)
+	envString(lookup, "GLIMPSE_DATA_DIR", &c.DataDir)
+	envString(lookup, "GLIMPSE_STORAGE", &c.Storage.Type)
+	if err := envDuration(lookup, "GLIMPSE_RETENTION", &c.Storage.Retention); err != nil {
+		return err
+	}
+	if err := envDuration(lookup, "GLIMPSE_RETIRE_AFTER", &c.Status.RetireAfter); err != nil {
+		return err
+	}
+	envString(lookup, "GLIMPSE_ADMIN_TOKEN", &c.Auth.AdminToken)
+	if v, ok := lookup("GLIMPSE_JOIN_TOKENS"); ok {
+		c.Auth.JoinTokens = splitList(v)
+	}
+	if err := envBool(lookup, "GLIMPSE_AUTH", &c.Auth.Enabled); err != nil {
+		return err
+	}
+	envString(lookup, "GLIMPSE_TLS_CERT_FILE", &c.TLS.CertFile)
+	envString(lookup, "GLIMPSE_TLS_KEY_FILE", &c.TLS.KeyFile)
+	envString(lookup, "GLIMPSE_TLS_CLIENT_CA_FILE", &c.TLS.ClientCAFile)
+	envString(lookup, "GLIMPSE_TLS_CLIENT_AUTH", &c.TLS.ClientAuth)
+	if err := envDuration(lookup, "GLIMPSE_AGENT_HEARTBEAT_INTERVAL", &c.AgentHeartbeatInterval); err != nil {
+		return err
+	}
+	return envInt(lookup, "GLIMPSE_HISTORY_SIZE", &c.HistorySize)
+}
+
+func (c *ServerConfig) Validate() error {
+	if err := validateAddr("grpc_listen_addr", c.GRPCListenAddr); err != nil {
+		return err
+	}
+	if err := validateAddr("http_listen_addr", c.HTTPListenAddr); err != nil {
+		return err
+	}
+	if c.HistorySize < 1 {
+		return errors.New("history_size must be at least 1")
+	}
+	if c.AgentHeartbeatInterval != 0 && c.AgentHeartbeatInterval < 100*time.Millisecond {
+		return errors.New("agent_heartbeat_interval must be 0 or at least 100ms")
+	}
+	if !logger.ValidLevel(c.LogLevel) {
+		return fmt.Errorf("unknown log_level %q", c.LogLevel)
+	}
+	if c.DataDir == "" {
+		return errors.New("data_dir must not be empty")
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+	if err := c.Storage.Validate(); err != nil {
+		return err
+	}
+	if err := c.Status.Validate(); err != nil {
+		return err
+	}
+	if err := c.Alerts.Validate(); err != nil {
+		return err
+	}
+	if err := c.Notifications.Validate(c.Alerts.Rules); err != nil {
+		return err
+	}
+	if err := c.Export.Validate(); err != nil {
+		return err
+	}
+	return c.Auth.Validate()
+}
+
+// CredentialsPath is where enrolled agent credentials are kept
+func (c *ServerConfig) CredentialsPath() string {
+	if c.Auth.CredentialsFile != "" {
+		return c.Auth.CredentialsFile
+	}
+	return filepath.Join(c.DataDir, "credentials.json")
+}
+
+// HistoryPath is where the disk storage keeps its segment files
+func (c *ServerConfig) HistoryPath() string {
+	return filepath.Join(c.DataDir, "history")
+}
+
+func (st ServerStorageConfig) Validate() error {
+	switch st.Type {
+	case "memory", "disk":
+	default:
+		return fmt.Errorf("unknown storage.type %q (want disk or memory)", st.Type)
+	}
+	if st.Retention < time.Minute {
+		return errors.New("storage.retention must be at least 1m")
+	}
+
+	// every tier must be coarser and kept longer than the one before it
+	prevRes, prevRet := time.Duration(0), st.Retention
+	for i, r := range st.Rollups {
+		if r.Resolution < time.Second || r.Resolution%time.Second != 0 {
+			return fmt.Errorf("storage.rollups[%d].resolution must be a whole number of seconds", i)
+		}
+		if r.Resolution <= prevRes {
+			return fmt.Errorf("storage.rollups[%d].resolution must be coarser than the tier before it", i)
+		}
+		if r.Retention < prevRet {
+			return fmt.Errorf("storage.rollups[%d].retention must not be shorter than the tier before it", i)
+		}
+		prevRes, prevRet = r.Resolution, r.Retention
+	}
+	return nil
+}
+
+func (st ServerStatusConfig) Validate() error {
+	if st.LateAfter < 1 {
+		return errors.New("status.late_after must be at least 1")
+	}
+	if st.OfflineAfter <= st.LateAfter {
+		return errors.New("status.offline_after must be greater than status.late_after")
+	}
+	if st.RetireAfter < 0 {
+		return errors.New("status.retire_after must not be negative")
+	}
+	if st.SweepInterval < 100*time.Millisecond {
+		return errors.New("status.sweep_interval must be at least 100ms")
+	}
+	return nil
+}
+
+// Validate checks the shape of the rules. The expressions themselves are
+// parsed by the alert engine when the server starts.
+func (al ServerAlertsConfig) Validate() error {
+	if al.EvalInterval < time.Second {
+		return errors.New("alerts.eval_interval must be at least 1s")
+	}
+	if al.KeepResolved < 0 {
+		return errors.New("alerts.keep_resolved must not be negative")
+	}
+	seen := make(map[string]bool)
+	for i, r := range al.Rules {
+		if r.Name == "" || r.Expr == "" {
+			return fmt.Errorf("alerts.rules[%d] needs a name and an expr", i)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("alerts.rules[%d]: duplicate rule name %q", i, r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return nil
+}
+
+// Validate checks the notifiers and that every notifier a rule names exists
+func (n NotificationsConfig) Validate(rules []AlertRuleConfig) error {
+	if n.RateLimit < 1 {
+		return errors.New("notifications.rate_limit must be at least 1")
+	}
+	if n.MaxRetries < 0 {
+		return errors.New("notifications.max_retries must not be negative")
+	}
+
+	names := make(map[string]bool)
+	for i, nc := range n.Notifiers {
+		if nc.Name == "" {
+			return fmt.Errorf("notifications.notifiers[%d] needs a name", i)
+		}
+		if names[nc.Name] {
+			return fmt.Errorf("notifications.notifiers[%d]: duplicate name %q", i, nc.Name)
+		}
+		names[nc.Name] = true
+
+		switch nc.Type {
+		case "webhook", "ntfy", "gotify", "slack", "discord", "matrix":
+			if nc.URL == "" {
+				return fmt.Errorf("notifier %s: %s needs a url", nc.Name, nc.Type)
+			}
+		case "smtp":
+			if nc.SMTP.Host == "" || nc.SMTP.From == "" || len(nc.SMTP.To) == 0 {
+				return fmt.Errorf("notifier %s: smtp needs smtp.host, smtp.from and smtp.to", nc.Name)
+			}
+		default:
+			return fmt.Errorf("notifier %s: unknown type %q", nc.Name, nc.Type)
+		}
+	}
+
+	for _, name := range n.Default {
+		if !names[name] {
+			return fmt.Errorf("notifications.default: unknown notifier %q", name)
+		}
+	}
+	for _, r := range rules {
+		for _, name := range r.Notify {
+			if !names[name] {
+				return fmt.Errorf("alert rule %s: unknown notifier %q", r.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (e ExportConfig) Validate() error {
+	if e.BatchSize < 1 || e.QueueSize < e.BatchSize {
+		return errors.New("export.batch_size must be at least 1 and export.queue_size at least export.batch_size")
+	}
+	if e.FlushInterval < 100*time.Millisecond {
+		return errors.New("export.flush_interval must be at least 100ms")
+	}
+	if e.MaxRetries < 0 {
+		return errors.New("export.max_retries must not be negative")
+	}
+	for i, t := range e.RemoteWrite {
+		if t.URL == "" {
+			return fmt.Errorf("export.remote_write[%d] needs a url", i)
+		}
+	}
+	for i, t := range e.OTLP {
+		if t.URL == "" {
+			return fmt.Errorf("export.otlp[%d] needs a url", i)
+		}
+	}
+	return nil
+}
+
+func (a ServerAuthConfig) Validate() error {
+	if a.Enabled && len(a.JoinTokens) == 0 {
+		return errors.New("auth.enabled needs at least one auth.join_tokens entry")
+	}
+	for _, t := range a.JoinTokens {
+		if len(t) < 16 {
+			return errors.New("auth.join_tokens entries must be at least 16 characters")
+		}
+	}
+	return nil
+}
+
+func (t ServerTLSConfig) Validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("tls.cert_file and tls.key_file must be set together")
+	}
+	if !t.Enabled() && (t.ClientCAFile != "" || t.ClientAuth != "") {
+		return errors.New("tls.client_ca_file and tls.client_auth need tls.cert_file")
+	}
+	switch t.ClientAuth {
+	case "", "none":
+	case "request", "require":
+		if t.ClientCAFile == "" {
+			return fmt.Errorf("tls.client_auth %q needs tls.client_ca_file", t.ClientAuth)
+		}
+	default:
+		return fmt.Errorf("unknown tls.client_auth %q (want none, request or require)", t.ClientAuth)
+	}
+	return nil
+}
@@ -22,6 +22,22 @@ func SetDebugLevel() {
 	log.SetLevel(logrus.DebugLevel)
 }
 
+// SetLevel sets the log level from its name (debug, info, warn, error)
+func SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(lvl)
+	return nil
+}
+
+// ValidLevel reports whether level is a log level name SetLevel accepts
+func ValidLevel(level string) bool {
+	_, err := logrus.ParseLevel(level)
+	return err == nil
+}
+
 // Wrapper functions for logging
 
 func Debug(args ...interface{}) {
@@ -9,7 +9,7 @@ import (
 
 func PrettyPrint(v interface{}) {
 	yamlBytes, _ := yaml.Marshal(v)
-	output := text.Colors{text.FgGreen}.Sprintf(string(yamlBytes))
+	output := text.Colors{text.FgGreen}.Sprint(string(yamlBytes))
 	fmt.Println(output)
 }
 
@@ -0,0 +1,247 @@
+// Package tlsutil builds the TLS configs used between the agent and the server.
+// Certificates and CA bundles are re-read from disk when they change, so
+// rotating them does not need a restart.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+)
+
+// ServerConfig returns a TLS config for the gRPC server. Client certificates are
+// verified against the configured CA bundle according to client_auth.
+func ServerConfig(cfg config.ServerTLSConfig) (*tls.Config, error) {
+	clientAuth, err := parseClientAuth(cfg.ClientAuth, cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CertFile == "" {
+		return nil, errors.New("both cert_file and key_file are required")
+	}
+	r, err := newReloader(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile, cfg.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// build a fresh config per handshake so reloaded certs and CAs are picked up
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   clientAuth,
+				NextProtos:   []string{"h2"},
+			}, nil
+		},
+	}, nil
+}
+
+// ClientConfig returns a TLS config for the agent. The server is verified
+// against ca_file (or the system roots) and the client certificate, if any, is
+// presented for mutual TLS.
+func ClientConfig(cfg config.AgentTLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile == "" && cfg.CertFile == "" && cfg.KeyFile == "" {
+		return tlsCfg, nil
+	}
+
+	r, err := newReloader(cfg.CertFile, cfg.KeyFile, cfg.CAFile, cfg.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CAFile != "" && !cfg.InsecureSkipVerify {
+		// RootCAs would pin the CA file read at startup, so the chain is
+		// verified here against the current pool instead
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			_, pool := r.current()
+			return verifyServer(cs, pool)
+		}
+	}
+
+	if cfg.CertFile != "" {
+		tlsCfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		}
+	}
+
+	return tlsCfg, nil
+}
+
+// verifyServer does what crypto/tls does for a client with RootCAs set: the
+// server's chain must lead to the pool and its certificate must match the
+// server name
+func verifyServer(cs tls.ConnectionState, pool *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server sent no certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+func parseClientAuth(mode, caFile string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "":
+		if caFile != "" {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.NoClientCert, nil
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown client_auth %q", mode)
+	}
+}
+
+func loadCAPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", path)
+	}
+	return pool, nil
+}
+
+// reloader holds a key pair and a CA pool, either of them optional, re-reading
+// them when the files' modification times change. Files are checked at most once per interval,
+// lazily from the handshake path, so there is no background goroutine.
+type reloader struct {
+	certFile, keyFile, caFile string
+	interval                  time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func newReloader(certFile, keyFile, caFile string, interval time.Duration) (*reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("both cert_file and key_file are required")
+	}
+	r := &reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *reloader) files() []string {
+	var files []string
+	if r.certFile != "" {
+		files = append(files, r.certFile, r.keyFile)
+	}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+// load reads everything from disk. Caller must hold mu (or be the constructor).
+func (r *reloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", f, err)
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("error loading key pair: %w", err)
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	var err error
+	if r.caFile != "" {
+		pool, err = loadCAPool(r.caFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.cert = cert
+	r.pool = pool
+	r.modTimes = modTimes
+	r.lastCheck = time.Now()
+	return nil
+}
+
+func (r *reloader) changed() bool {
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			// mid-rotation, try again next time
+			return false
+		}
+		if !info.ModTime().Equal(r.modTimes[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// current returns the key pair and CA pool, reloading them first if they changed on disk.
+// A failed reload keeps serving the previous certificates.
+func (r *reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interval > 0 && time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				logger.Errorf("Error reloading TLS certificates, keeping the old ones: %v", err)
+			} else {
+				logger.Infof("Reloaded TLS certificates from %s", strings.Join(r.files(), ", "))
+			}
+		}
+	}
+	return r.cert, r.pool
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/auth"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	"github.com/mansoormajeed/glimpse/internal/server/notify"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type enrollmentView struct {
+	AgentID    string `json:"agent_id"`
+	Hostname   string `json:"hostname"`
+	EnrolledAt string `json:"enrolled_at"`
+	Revoked    bool   `json:"revoked"`
+}
+
+// commandRequest is the body of POST /api/v1/agents/{id}/commands
+type commandRequest struct {
+	Type     string `json:"type"`     // set_interval or reregister
+	Interval string `json:"interval"` // for set_interval, e.g. "5s"
+	Reason   string `json:"reason"`
+}
+
+// RegisterAdminHandlers adds the admin API for managing agents. The enrollment
+// endpoints are only added when agent auth is enabled (agentAuth != nil).
+// Every request needs "Authorization: Bearer <admin token>".
+func RegisterAdminHandlers(store *ServerStore, agentAuth *AgentAuth, streams *GlimpseServer, adminToken string) {
+	http.HandleFunc("DELETE /api/v1/agents/{id}", requireAdmin(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		agentID := r.PathValue("id")
+		found, err := store.Forget(agentID)
+		if err != nil {
+			logger.Errorf("Error deleting history of agent %s: %v", agentID, err)
+			http.Error(w, "error deleting history", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "unknown agent", http.StatusNotFound)
+			return
+		}
+		logger.Infof("Forgot agent %s", agentID)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	http.HandleFunc("POST /api/v1/agents/{id}/commands", requireAdmin(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		agentID := r.PathValue("id")
+		var req commandRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		cmd := &pb.ServerCommand{Reason: req.Reason}
+		switch req.Type {
+		case "set_interval":
+			interval, err := time.ParseDuration(req.Interval)
+			if err != nil || interval <= 0 {
+				http.Error(w, "interval must be a positive duration", http.StatusBadRequest)
+				return
+			}
+			cmd.Type = pb.CommandType_COMMAND_SET_INTERVAL
+			cmd.IntervalMs = interval.Milliseconds()
+		case "reregister":
+			cmd.Type = pb.CommandType_COMMAND_REREGISTER
+		default:
+			http.Error(w, "type must be set_interval or reregister", http.StatusBadRequest)
+			return
+		}
+		if cmd.Reason == "" {
+			cmd.Reason = "requested by an admin"
+		}
+		if !streams.SendCommand(agentID, cmd) {
+			http.Error(w, "agent has no open metrics stream", http.StatusConflict)
+			return
+		}
+		logger.Infof("Sent %s to agent %s", cmd.Type, agentID)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	if agentAuth == nil {
+		return
+	}
+
+	http.HandleFunc("GET /api/v1/enrollments", requireAdmin(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		list := agentAuth.List()
+		views := make([]enrollmentView, 0, len(list))
+		for _, c := range list {
+			views = append(views, enrollmentView{
+				AgentID:    c.AgentID,
+				Hostname:   c.Hostname,
+				EnrolledAt: c.EnrolledAt.Format(time.RFC3339),
+				Revoked:    c.Revoked(),
+			})
+		}
+		writeJSON(w, views)
+	}))
+
+	http.HandleFunc("POST /api/v1/enrollments/{id}/revoke", requireAdmin(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		agentID := r.PathValue("id")
+		found, err := agentAuth.Revoke(agentID)
+		if err != nil {
+			logger.Errorf("Error revoking agent %s: %v", agentID, err)
+			http.Error(w, "error saving credentials", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "agent not enrolled", http.StatusNotFound)
+			return
+		}
+		streams.Disconnect(agentID, status.Error(codes.PermissionDenied, errRevokedAgent.Error()))
+		logger.Infof("Revoked agent %s", agentID)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	http.HandleFunc("DELETE /api/v1/enrollments/{id}", requireAdmin(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		agentID := r.PathValue("id")
+		found, err := agentAuth.Forget(agentID)
+		if err != nil {
+			logger.Errorf("Error deleting the enrollment of agent %s: %v", agentID, err)
+			http.Error(w, "error saving credentials", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "agent not enrolled", http.StatusNotFound)
+			return
+		}
+		// the agent drops its secret and enrolls again, if it has a join token
+		streams.Disconnect(agentID, status.Error(codes.Unauthenticated, errForgottenAgent.Error()))
+		logger.Infof("Deleted the enrollment of agent %s", agentID)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// RegisterNotifierHandlers adds POST /api/v1/notifiers/{name}/test, which sends
+// a sample notification through one notifier and reports whether it got through
+func RegisterNotifierHandlers(dispatcher *notify.Dispatcher, adminToken string) {
+	http.HandleFunc("POST /api/v1/notifiers/{name}/test", requireAdmin(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		err := dispatcher.Test(r.Context(), name)
+		if errors.Is(err, notify.ErrUnknownNotifier) {
+			http.Error(w, "unknown notifier", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Warnf("Test notification through %s failed: %v", name, err)
+			http.Error(w, "sending failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func requireAdmin(adminToken string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !auth.Equal(token, adminToken) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Errorf("Error encoding JSON response: %v", err)
+	}
+}
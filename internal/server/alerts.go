@@ -0,0 +1,428 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	"github.com/mansoormajeed/glimpse/internal/server/notify"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+type AlertState string
+
+const (
+	AlertPending  AlertState = "pending"  // condition holds, waiting for the rule's for duration
+	AlertFiring   AlertState = "firing"   // condition held for long enough
+	AlertResolved AlertState = "resolved" // was firing, condition no longer holds
+)
+
+// Alert is the state of one rule for one agent. There is at most one alert per
+// rule and agent at a time, so a condition that keeps holding does not pile up alerts.
+type Alert struct {
+	Rule        string     `json:"rule"`
+	Expr        string     `json:"expr"`
+	Severity    string     `json:"severity"`
+	AgentID     string     `json:"agent_id"`
+	Hostname    string     `json:"hostname"`
+	State       AlertState `json:"state"`
+	Value       float64    `json:"value"`        // last value seen, failed units for unit rules, seconds since last heartbeat for agent rules
+	ActiveSince time.Time  `json:"active_since"` // when the condition started holding
+	FiredAt     *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// alertRule is a parsed AlertRuleConfig. Metric rules compare a field of the
+// latest sample, unit rules look for failed systemd units and agent rules
+// (neither field nor unit set) check the agent status.
+type alertRule struct {
+	name     string
+	expr     string
+	severity string
+	forDur   time.Duration
+
+	field     string
+	op        string
+	threshold float64
+
+	unit string // glob on the unit name
+
+	status AgentStatus
+}
+
+// parseAlertRule parses "<field> <op> <value> [for <duration>]",
+// "unit <name> failed [for <duration>]" or "agent late|offline [for <duration>]"
+func parseAlertRule(cfg config.AlertRuleConfig) (*alertRule, error) {
+	r := &alertRule{name: cfg.Name, expr: cfg.Expr, severity: cfg.Severity}
+	if r.severity == "" {
+		r.severity = "warning"
+	}
+
+	tokens := strings.Fields(cfg.Expr)
+	if i := indexOf(tokens, "for"); i >= 0 {
+		if i != len(tokens)-2 {
+			return nil, fmt.Errorf("rule %s: \"for\" must be followed by a single duration at the end", cfg.Name)
+		}
+		d, err := time.ParseDuration(tokens[i+1])
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("rule %s: invalid for duration %q", cfg.Name, tokens[i+1])
+		}
+		r.forDur = d
+		tokens = tokens[:i]
+	}
+
+	if len(tokens) == 2 && tokens[0] == "agent" {
+		switch tokens[1] {
+		case "late":
+			r.status = StatusLate
+		case "offline":
+			r.status = StatusOffline
+		default:
+			return nil, fmt.Errorf("rule %s: unknown agent status %q (want late or offline)", cfg.Name, tokens[1])
+		}
+		return r, nil
+	}
+
+	if len(tokens) == 3 && tokens[0] == "unit" && tokens[2] == "failed" {
+		if _, err := path.Match(tokens[1], ""); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid unit pattern %q", cfg.Name, tokens[1])
+		}
+		r.unit = tokens[1]
+		return r, nil
+	}
+
+	if len(tokens) != 3 {
+		return nil, fmt.Errorf("rule %s: expected \"<field> <op> <value> [for <duration>]\", \"unit <name> failed [for <duration>]\" or \"agent offline [for <duration>]\"", cfg.Name)
+	}
+	if indexOf(metricFieldNames(), tokens[0]) < 0 {
+		return nil, fmt.Errorf("rule %s: unknown field %q", cfg.Name, tokens[0])
+	}
+	switch tokens[1] {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return nil, fmt.Errorf("rule %s: unknown operator %q", cfg.Name, tokens[1])
+	}
+	threshold, err := strconv.ParseFloat(tokens[2], 64)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: invalid threshold %q", cfg.Name, tokens[2])
+	}
+	r.field, r.op, r.threshold = tokens[0], tokens[1], threshold
+	return r, nil
+}
+
+func (r *alertRule) matches(v float64) bool {
+	switch r.op {
+	case ">":
+		return v > r.threshold
+	case ">=":
+		return v >= r.threshold
+	case "<":
+		return v < r.threshold
+	case "<=":
+		return v <= r.threshold
+	case "==":
+		return v == r.threshold
+	case "!=":
+		return v != r.threshold
+	}
+	return false
+}
+
+// check reports whether the rule's condition holds for an agent right now,
+// the value it saw and since when the condition has held
+func (r *alertRule) check(a alertAgent, now time.Time) (bool, float64, time.Time) {
+	if a.Status == StatusRetired {
+		return false, 0, time.Time{}
+	}
+
+	if r.field == "" && r.unit == "" {
+		return a.Status >= r.status, now.Sub(a.LastSeen).Seconds(), a.StatusSince
+	}
+
+	// stale samples of a silent agent say nothing about its current state,
+	// agent offline rules cover that case
+	if a.Status >= StatusOffline || len(a.History) == 0 {
+		return false, 0, time.Time{}
+	}
+
+	latest := a.History[len(a.History)-1]
+	v, holds := r.sample(latest.Metrics)
+	if !holds {
+		return false, v, time.Time{}
+	}
+
+	// walk back through the ring buffer to find where the run of matching samples started
+	since := latest.Timestamp
+	for i := len(a.History) - 2; i >= 0; i-- {
+		if _, holds := r.sample(a.History[i].Metrics); !holds {
+			break
+		}
+		since = a.History[i].Timestamp
+	}
+	return true, v, since
+}
+
+// sample reports the rule's value for one sample and whether the condition
+// holds. For unit rules the value is the number of matching failed units.
+func (r *alertRule) sample(m *pb.AgentMetrics) (float64, bool) {
+	if r.unit != "" {
+		n := failedUnits(m, r.unit)
+		return float64(n), n > 0
+	}
+	v, ok := metricValue(m, r.field)
+	return v, ok && r.matches(v)
+}
+
+// failedUnits counts the failed and watched units matching pattern that are failed
+func failedUnits(m *pb.AgentMetrics, pattern string) int {
+	failed := make(map[string]bool)
+	for _, u := range append(m.GetFailedUnits(), m.GetWatchedUnits()...) {
+		if ok, _ := path.Match(pattern, u.Name); ok && u.ActiveState == "failed" {
+			failed[u.Name] = true
+		}
+	}
+	return len(failed)
+}
+
+func metricValue(m *pb.AgentMetrics, field string) (float64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	var (
+		value float64
+		found bool
+	)
+	eachNumericField(m, func(name string, v float64) {
+		if name == field {
+			value, found = v, true
+		}
+	})
+	return value, found
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// alertAgent is what the rules need to know about an agent, copied out of the store
+type alertAgent struct {
+	AgentID     string
+	Hostname    string
+	Status      AgentStatus
+	StatusSince time.Time
+	LastSeen    time.Time
+	History     []MetricEntry
+}
+
+func (s *ServerStore) alertAgents() []alertAgent {
+	s.Lock()
+	defer s.Unlock()
+
+	agents := make([]alertAgent, 0, len(s.agents))
+	for _, a := range s.agents {
+		agents = append(agents, alertAgent{
+			AgentID:     a.AgentID,
+			Hostname:    a.Hostname,
+			Status:      a.Status,
+			StatusSince: a.StatusSince,
+			LastSeen:    a.LastSeen,
+			History:     a.History(),
+		})
+	}
+	return agents
+}
+
+// AlertEngine evaluates the alert rules against the store on a fixed interval
+type AlertEngine struct {
+	store        *ServerStore
+	rules        []*alertRule
+	evalInterval time.Duration
+	keepResolved time.Duration
+	dispatcher   *notify.Dispatcher // nil sends no notifications
+
+	mu     sync.Mutex
+	alerts map[string]*Alert // rule name + "/" + agent id
+	stop   chan struct{}
+	done   chan struct{} // closed when the evaluation loop exits
+}
+
+// NewAlertEngine parses the configured rules. Evaluation starts with Start.
+// Alerts that start firing or resolve are handed to dispatcher, if not nil.
+func NewAlertEngine(store *ServerStore, cfg config.ServerAlertsConfig, dispatcher *notify.Dispatcher) (*AlertEngine, error) {
+	e := &AlertEngine{
+		store:        store,
+		evalInterval: cfg.EvalInterval,
+		keepResolved: cfg.KeepResolved,
+		dispatcher:   dispatcher,
+		alerts:       make(map[string]*Alert),
+	}
+	for _, rc := range cfg.Rules {
+		r, err := parseAlertRule(rc)
+		if err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, r)
+	}
+	return e, nil
+}
+
+func (e *AlertEngine) Start() {
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.evalInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case now := <-ticker.C:
+				e.evaluate(now)
+			}
+		}
+	}()
+	logger.Infof("Evaluating %d alert rules every %s", len(e.rules), e.evalInterval)
+}
+
+// Stop waits for an evaluation in progress, so nothing is handed to the
+// dispatcher once Stop returns
+func (e *AlertEngine) Stop() {
+	if e.stop != nil {
+		close(e.stop)
+		<-e.done
+	}
+}
+
+func (e *AlertEngine) evaluate(now time.Time) {
+	agents := e.store.alertAgents()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, r := range e.rules {
+		for _, a := range agents {
+			key := r.name + "/" + a.AgentID
+			seen[key] = true
+
+			holds, value, since := r.check(a, now)
+			if holds {
+				e.activate(key, r, a, value, since, now)
+			} else {
+				e.deactivate(key, now)
+			}
+		}
+	}
+
+	for key, alert := range e.alerts {
+		if !seen[key] {
+			// the agent was forgotten or the rule is gone
+			e.deactivate(key, now)
+		}
+		if alert.State == AlertResolved && now.Sub(*alert.ResolvedAt) > e.keepResolved {
+			delete(e.alerts, key)
+		}
+	}
+}
+
+func (e *AlertEngine) activate(key string, r *alertRule, a alertAgent, value float64, since, now time.Time) {
+	alert, ok := e.alerts[key]
+	if !ok || alert.State == AlertResolved {
+		alert = &Alert{
+			Rule:        r.name,
+			Expr:        r.expr,
+			Severity:    r.severity,
+			AgentID:     a.AgentID,
+			State:       AlertPending,
+			ActiveSince: since,
+		}
+		e.alerts[key] = alert
+	}
+	alert.Hostname = a.Hostname
+	alert.Value = value
+
+	if alert.State == AlertPending && now.Sub(alert.ActiveSince) >= r.forDur {
+		alert.State = AlertFiring
+		alert.FiredAt = &now
+		logger.Warnf("Alert %s firing for %s (%s): %s, value %g", r.name, a.Hostname, a.AgentID, r.expr, value)
+		e.notify(alert, now)
+	}
+}
+
+func (e *AlertEngine) deactivate(key string, now time.Time) {
+	alert, ok := e.alerts[key]
+	if !ok {
+		return
+	}
+	switch alert.State {
+	case AlertPending:
+		delete(e.alerts, key)
+	case AlertFiring:
+		alert.State = AlertResolved
+		alert.ResolvedAt = &now
+		logger.Infof("Alert %s resolved for %s (%s)", alert.Rule, alert.Hostname, alert.AgentID)
+		e.notify(alert, now)
+	}
+}
+
+func (e *AlertEngine) notify(alert *Alert, now time.Time) {
+	if e.dispatcher == nil {
+		return
+	}
+	e.dispatcher.Dispatch(notify.Notification{
+		Rule:     alert.Rule,
+		Expr:     alert.Expr,
+		Severity: alert.Severity,
+		AgentID:  alert.AgentID,
+		Hostname: alert.Hostname,
+		State:    string(alert.State),
+		Value:    alert.Value,
+		Since:    alert.ActiveSince,
+		Time:     now,
+	})
+}
+
+// Alerts returns copies of the current alerts, firing first, then pending,
+// then resolved, oldest first within each state. Resolved alerts are only
+// included when asked for.
+func (e *AlertEngine) Alerts(includeResolved bool) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		if a.State == AlertResolved && !includeResolved {
+			continue
+		}
+		list = append(list, *a)
+	}
+
+	order := map[AlertState]int{AlertFiring: 0, AlertPending: 1, AlertResolved: 2}
+	sort.Slice(list, func(i, j int) bool {
+		if order[list[i].State] != order[list[j].State] {
+			return order[list[i].State] < order[list[j].State]
+		}
+		return list[i].ActiveSince.Before(list[j].ActiveSince)
+	})
+	return list
+}
+
+// RegisterAlertHandlers adds GET /api/v1/alerts. Resolved alerts are included with ?state=all.
+func RegisterAlertHandlers(engine *AlertEngine) {
+	http.HandleFunc("GET /api/v1/alerts", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, engine.Alerts(r.URL.Query().Get("state") == "all"))
+	})
+}
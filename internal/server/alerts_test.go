@@ -0,0 +1,297 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/server/notify"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		expr      string
+		field, op string
+		threshold float64
+		unit      string
+		status    AgentStatus
+		forDur    time.Duration
+	}{
+		{expr: "cpu_usage > 90", field: "cpu_usage", op: ">", threshold: 90},
+		{expr: "memory_usage >= 95.5 for 5m", field: "memory_usage", op: ">=", threshold: 95.5, forDur: 5 * time.Minute},
+		{expr: "  disk_usage   !=  0  ", field: "disk_usage", op: "!=", threshold: 0},
+		{expr: "unit nginx.service failed", unit: "nginx.service"},
+		{expr: "unit backup-*.service failed for 10m", unit: "backup-*.service", forDur: 10 * time.Minute},
+		{expr: "agent late", status: StatusLate},
+		{expr: "agent offline for 2m", status: StatusOffline, forDur: 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		r, err := parseAlertRule(config.AlertRuleConfig{Name: "test", Expr: tt.expr})
+		if err != nil {
+			t.Errorf("parseAlertRule(%q): %v", tt.expr, err)
+			continue
+		}
+		if r.field != tt.field || r.op != tt.op || r.threshold != tt.threshold || r.unit != tt.unit || r.status != tt.status || r.forDur != tt.forDur {
+			t.Errorf("parseAlertRule(%q) = %+v", tt.expr, r)
+		}
+		if r.severity != "warning" {
+			t.Errorf("parseAlertRule(%q) severity = %q, want the warning default", tt.expr, r.severity)
+		}
+	}
+
+	r, _ := parseAlertRule(config.AlertRuleConfig{Name: "test", Expr: "agent offline", Severity: "critical"})
+	if r.severity != "critical" {
+		t.Errorf("severity = %q, want critical", r.severity)
+	}
+}
+
+func TestParseAlertRuleErrors(t *testing.T) {
+	tests := []struct {
+		expr, err string
+	}{
+		{"cpu_usage > 90 for", `"for" must be followed by a single duration at the end`},
+		{"cpu_usage > 90 for 5m now", `"for" must be followed by a single duration at the end`},
+		{"cpu_usage > 90 for soon", `invalid for duration "soon"`},
+		{"cpu_usage > 90 for -5m", `invalid for duration "-5m"`},
+		{"agent asleep", `unknown agent status "asleep"`},
+		{"unit [nginx failed", `invalid unit pattern "[nginx"`},
+		{"cpu_usage > ", "expected"},
+		{"cpu > 90", `unknown field "cpu"`},
+		{"cpu_usage => 90", `unknown operator "=>"`},
+		{"cpu_usage > high", `invalid threshold "high"`},
+	}
+	for _, tt := range tests {
+		_, err := parseAlertRule(config.AlertRuleConfig{Name: "test", Expr: tt.expr})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("parseAlertRule(%q) = %v, want an error containing %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestAlertRuleMatches(t *testing.T) {
+	tests := []struct {
+		op   string
+		v    float64
+		want bool
+	}{
+		{">", 90, false}, {">", 91, true},
+		{">=", 90, true}, {">=", 89, false},
+		{"<", 90, false}, {"<", 89, true},
+		{"<=", 90, true}, {"<=", 91, false},
+		{"==", 90, true}, {"==", 91, false},
+		{"!=", 90, false}, {"!=", 91, true},
+	}
+	for _, tt := range tests {
+		r := &alertRule{op: tt.op, threshold: 90}
+		if got := r.matches(tt.v); got != tt.want {
+			t.Errorf("%g %s 90 = %v, want %v", tt.v, tt.op, got, tt.want)
+		}
+	}
+}
+
+func newTestAlertEngine(t *testing.T, s *statusStore, dispatcher *notify.Dispatcher, rules ...config.AlertRuleConfig) *AlertEngine {
+	t.Helper()
+	e, err := NewAlertEngine(s.ServerStore, config.ServerAlertsConfig{KeepResolved: time.Hour, Rules: rules}, dispatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// heartbeatCPU sends a heartbeat with the given cpu_usage and evaluates the rules at the same time
+func heartbeatCPU(s *statusStore, e *AlertEngine, cpu int64) {
+	s.AddOrUpdateAgent(&pb.HeartbeatRequest{AgentId: "agent-1", Hostname: "web", Metrics: &pb.AgentMetrics{CpuUsage: cpu}})
+	e.evaluate(s.clock)
+}
+
+func onlyAlert(t *testing.T, e *AlertEngine) *Alert {
+	t.Helper()
+	alerts := e.Alerts(true)
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1: %+v", len(alerts), alerts)
+	}
+	return &alerts[0]
+}
+
+func TestAlertLifecycle(t *testing.T) {
+	var states []string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n notify.Notification
+		json.NewDecoder(r.Body).Decode(&n)
+		states = append(states, n.State)
+	}))
+	defer hook.Close()
+	rule := config.AlertRuleConfig{Name: "cpu", Expr: "cpu_usage > 90 for 30s", Severity: "critical"}
+	dispatcher, err := notify.NewDispatcher(config.NotificationsConfig{
+		Notifiers:    []config.NotifierConfig{{Name: "hook", Type: "webhook", URL: hook.URL}},
+		Default:      []string{"hook"},
+		SendResolved: true,
+		RateLimit:    10,
+	}, []config.AlertRuleConfig{rule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Start()
+
+	s := newStatusStore(testStatusConfig, 0)
+	e := newTestAlertEngine(t, s, dispatcher, rule)
+	start := s.clock
+
+	heartbeatCPU(s, e, 95)
+	a := onlyAlert(t, e)
+	if a.State != AlertPending || !a.ActiveSince.Equal(start) || a.Value != 95 || a.Severity != "critical" || a.Hostname != "web" {
+		t.Errorf("alert = %+v, want pending since the first sample", a)
+	}
+
+	s.clock = start.Add(20 * time.Second)
+	heartbeatCPU(s, e, 97)
+	if a := onlyAlert(t, e); a.State != AlertPending || a.Value != 97 {
+		t.Errorf("alert = %+v, want still pending with the latest value", a)
+	}
+
+	s.clock = start.Add(30 * time.Second)
+	heartbeatCPU(s, e, 96)
+	a = onlyAlert(t, e)
+	if a.State != AlertFiring || a.FiredAt == nil || !a.FiredAt.Equal(s.clock) || !a.ActiveSince.Equal(start) {
+		t.Errorf("alert = %+v, want firing after 30s", a)
+	}
+
+	// it keeps firing without firing again
+	s.clock = start.Add(40 * time.Second)
+	heartbeatCPU(s, e, 99)
+	if a := onlyAlert(t, e); a.State != AlertFiring || !a.FiredAt.Equal(start.Add(30*time.Second)) {
+		t.Errorf("alert = %+v, want the same firing alert", a)
+	}
+
+	s.clock = start.Add(50 * time.Second)
+	heartbeatCPU(s, e, 40)
+	a = onlyAlert(t, e)
+	if a.State != AlertResolved || a.ResolvedAt == nil || !a.ResolvedAt.Equal(s.clock) {
+		t.Errorf("alert = %+v, want resolved", a)
+	}
+	if active := e.Alerts(false); len(active) != 0 {
+		t.Errorf("resolved alert listed as active: %+v", active)
+	}
+
+	// a new breach starts a new alert, the resolved one is replaced
+	s.clock = start.Add(time.Minute)
+	heartbeatCPU(s, e, 95)
+	if a := onlyAlert(t, e); a.State != AlertPending || !a.ActiveSince.Equal(s.clock) {
+		t.Errorf("alert = %+v, want a new pending one", a)
+	}
+
+	dispatcher.Stop()
+	if got := strings.Join(states, ","); got != "firing,resolved" {
+		t.Errorf("notified %s, want firing,resolved", got)
+	}
+}
+
+func TestAlertPendingClears(t *testing.T) {
+	s := newStatusStore(testStatusConfig, 0)
+	e := newTestAlertEngine(t, s, nil, config.AlertRuleConfig{Name: "cpu", Expr: "cpu_usage > 90 for 1m"})
+	heartbeatCPU(s, e, 95)
+	s.clock = s.clock.Add(10 * time.Second)
+	heartbeatCPU(s, e, 50)
+	if alerts := e.Alerts(true); len(alerts) != 0 {
+		t.Errorf("alerts = %+v, want a pending alert that never fired gone without a trace", alerts)
+	}
+}
+
+func TestAlertActiveSinceFromHistory(t *testing.T) {
+	s := newStatusStore(testStatusConfig, 0)
+	e := newTestAlertEngine(t, s, nil, config.AlertRuleConfig{Name: "cpu", Expr: "cpu_usage > 90 for 20s"})
+	start := s.clock
+	for i, cpu := range []int64{50, 95, 96, 97} {
+		s.clock = start.Add(time.Duration(i) * 10 * time.Second)
+		s.AddOrUpdateAgent(&pb.HeartbeatRequest{AgentId: "agent-1", Hostname: "web", Metrics: &pb.AgentMetrics{CpuUsage: cpu}})
+	}
+	// the rule was added after the samples came in, the run of matching samples still counts
+	e.evaluate(s.clock)
+	if a := onlyAlert(t, e); a.State != AlertFiring || !a.ActiveSince.Equal(start.Add(10*time.Second)) {
+		t.Errorf("alert = %+v, want firing since the first matching sample", a)
+	}
+}
+
+func TestAgentStatusAlert(t *testing.T) {
+	s := newStatusStore(testStatusConfig, 0)
+	e := newTestAlertEngine(t, s, nil,
+		config.AlertRuleConfig{Name: "down", Expr: "agent offline for 5s"},
+		config.AlertRuleConfig{Name: "cpu", Expr: "cpu_usage > 90"},
+	)
+	heartbeatCPU(s, e, 95)
+	if a := onlyAlert(t, e); a.Rule != "cpu" || a.State != AlertFiring {
+		t.Fatalf("alert = %+v, want cpu firing", a)
+	}
+
+	// offline after 10 missed 1s intervals, firing 5s later
+	s.advance(10 * time.Second)
+	e.evaluate(s.clock)
+	alerts := e.Alerts(true)
+	if len(alerts) != 2 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	for _, a := range alerts {
+		switch a.Rule {
+		case "down":
+			if a.State != AlertPending {
+				t.Errorf("down = %+v, want pending", a)
+			}
+		case "cpu":
+			// stale samples of an offline agent say nothing
+			if a.State != AlertResolved {
+				t.Errorf("cpu = %+v, want resolved while the agent is offline", a)
+			}
+		}
+	}
+	s.advance(5 * time.Second)
+	e.evaluate(s.clock)
+	if a := e.Alerts(false); len(a) != 1 || a[0].Rule != "down" || a[0].State != AlertFiring || a[0].Value != 15 {
+		t.Errorf("active alerts = %+v, want down firing 15s after the last heartbeat", a)
+	}
+
+	s.clock = s.clock.Add(time.Second)
+	heartbeatCPU(s, e, 10)
+	for _, a := range e.Alerts(true) {
+		if a.State != AlertResolved {
+			t.Errorf("%s = %s, want resolved once the agent is back", a.Rule, a.State)
+		}
+	}
+}
+
+func TestAlertsForgottenAndExpired(t *testing.T) {
+	s := newStatusStore(testStatusConfig, 0)
+	e := newTestAlertEngine(t, s, nil, config.AlertRuleConfig{Name: "cpu", Expr: "cpu_usage > 90"})
+	heartbeatCPU(s, e, 95)
+
+	// a forgotten agent's alerts resolve
+	s.Forget("agent-1")
+	e.evaluate(s.clock)
+	if a := onlyAlert(t, e); a.State != AlertResolved {
+		t.Errorf("alert = %+v, want resolved once the agent is forgotten", a)
+	}
+
+	// and are dropped after keep_resolved
+	e.evaluate(s.clock.Add(time.Hour + time.Second))
+	if alerts := e.Alerts(true); len(alerts) != 0 {
+		t.Errorf("alerts = %+v, want none past keep_resolved", alerts)
+	}
+}
+
+func TestUnitAlert(t *testing.T) {
+	s := newStatusStore(testStatusConfig, 0)
+	e := newTestAlertEngine(t, s, nil, config.AlertRuleConfig{Name: "backup", Expr: "unit backup-*.service failed"})
+	s.AddOrUpdateAgent(&pb.HeartbeatRequest{AgentId: "agent-1", Hostname: "web", Metrics: &pb.AgentMetrics{
+		FailedUnits:  []*pb.SystemdUnit{{Name: "backup-db.service", ActiveState: "failed"}, {Name: "nginx.service", ActiveState: "failed"}},
+		WatchedUnits: []*pb.SystemdUnit{{Name: "backup-db.service", ActiveState: "failed"}, {Name: "backup-web.service", ActiveState: "active"}},
+	}})
+	e.evaluate(s.clock)
+	// backup-db is failed and watched, it counts once
+	if a := onlyAlert(t, e); a.State != AlertFiring || a.Value != 1 {
+		t.Errorf("alert = %+v, want firing for one failed unit", a)
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+const (
+	defaultQueryRange  = 15 * time.Minute
+	defaultQueryPoints = 300   // used to pick a step when none is given
+	maxQueryPoints     = 11000 // a day at 8s steps, plenty for a chart
+)
+
+// SeriesValues holds one value per timestamp of the response. Slots without
+// data are null.
+type SeriesValues struct {
+	Avg []*float64 `json:"avg"`
+	Min []*float64 `json:"min"`
+	Max []*float64 `json:"max"`
+}
+
+type MetricsQueryResponse struct {
+	AgentID    string                  `json:"agent_id"`
+	Tier       string                  `json:"tier"`
+	Step       float64                 `json:"step"`       // seconds
+	Timestamps []int64                 `json:"timestamps"` // unix milliseconds, start of each step
+	Series     map[string]SeriesValues `json:"series"`     // keyed by field name, e.g. cpu_usage
+}
+
+// AgentView is an agent as listed by /api/v1/agents
+type AgentView struct {
+	AgentID           string      `json:"agent_id"`
+	Hostname          string      `json:"hostname"`
+	OS                string      `json:"os"`
+	Status            AgentStatus `json:"status"`
+	LastSeen          time.Time   `json:"last_seen"`
+	HeartbeatInterval float64     `json:"heartbeat_interval"` // seconds, estimated. 0 until known
+}
+
+func registerAPIHandlers(store *ServerStore) {
+	// every agent the server knows about, retired ones included
+	http.HandleFunc("GET /api/v1/agents", func(w http.ResponseWriter, r *http.Request) {
+		agents := store.GetAllAgents()
+		sort.Slice(agents, func(i, j int) bool { return agents[i].Hostname < agents[j].Hostname })
+
+		views := make([]AgentView, 0, len(agents))
+		for _, a := range agents {
+			views = append(views, AgentView{
+				AgentID:           a.AgentID,
+				Hostname:          a.Hostname,
+				OS:                a.OS,
+				Status:            a.Status,
+				LastSeen:          a.LastSeen,
+				HeartbeatInterval: a.Interval.Seconds(),
+			})
+		}
+		writeJSON(w, views)
+	})
+	http.HandleFunc("GET /api/v1/agents/{id}/metrics", func(w http.ResponseWriter, r *http.Request) {
+		handleMetricsQuery(store, w, r)
+	})
+}
+
+// handleMetricsQuery serves /api/v1/agents/{id}/metrics?from=&to=&step=&fields=
+//
+// from and to take RFC 3339, unix seconds, or a negative duration relative to
+// now (from=-1h). step is a duration and fields a comma separated list of
+// AgentMetrics field names and sample keys like ups_charge{ups="main"}.
+// Everything is optional: the default is the last 15 minutes of every field
+// and every sample in the range at a step that gives about 300 points.
+func handleMetricsQuery(store *ServerStore, w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("id")
+	if _, ok := store.GetAgentData(agentID); !ok {
+		http.Error(w, "unknown agent", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	now := time.Now()
+
+	to, err := parseQueryTime(q.Get("to"), now, now)
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseQueryTime(q.Get("from"), now, to.Add(-defaultQueryRange))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	var step time.Duration
+	if s := q.Get("step"); s != "" {
+		step, err = time.ParseDuration(s)
+		if err != nil || step < time.Second {
+			http.Error(w, "invalid step: must be a duration of at least 1s", http.StatusBadRequest)
+			return
+		}
+	}
+
+	fields, err := parseFields(q.Get("fields"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tier, buckets, err := store.QueryHistory(agentID, from, to, step)
+	if err != nil {
+		logger.Errorf("Error querying history for agent %s: %v", agentID, err)
+		http.Error(w, "error reading history", http.StatusInternalServerError)
+		return
+	}
+
+	if fields == nil {
+		fields = append(metricFieldNames(), bucketSampleKeys(buckets)...)
+	}
+	if step == 0 {
+		step = defaultStep(tier, to.Sub(from))
+	}
+	// a step finer than the tier would only produce gaps
+	if step < tier.Resolution {
+		step = tier.Resolution
+	}
+	if to.Sub(from)/step > maxQueryPoints {
+		http.Error(w, fmt.Sprintf("too many points, use a step of at least %s", to.Sub(from)/maxQueryPoints), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, alignSeries(agentID, tier, buckets, from, to, step, fields))
+}
+
+// alignSeries merges buckets into fixed step slots starting at from truncated to the step
+func alignSeries(agentID string, tier Tier, buckets []Bucket, from, to time.Time, step time.Duration, fields []string) MetricsQueryResponse {
+	start := from.Truncate(step)
+	n := int(to.Sub(start)/step) + 1
+
+	slots := make([]*Bucket, n)
+	for _, b := range buckets {
+		i := int(b.Start.Sub(start) / step)
+		if b.Start.Before(start) || i >= n {
+			continue
+		}
+		if slots[i] == nil {
+			slots[i] = &Bucket{Start: start.Add(time.Duration(i) * step), Fields: make(map[string]FieldAgg)}
+		}
+		slots[i].merge(b)
+	}
+
+	resp := MetricsQueryResponse{
+		AgentID:    agentID,
+		Tier:       tier.Name,
+		Step:       step.Seconds(),
+		Timestamps: make([]int64, n),
+		Series:     make(map[string]SeriesValues, len(fields)),
+	}
+	for i := range resp.Timestamps {
+		resp.Timestamps[i] = start.Add(time.Duration(i) * step).UnixMilli()
+	}
+	for _, f := range fields {
+		sv := SeriesValues{
+			Avg: make([]*float64, n),
+			Min: make([]*float64, n),
+			Max: make([]*float64, n),
+		}
+		for i, slot := range slots {
+			if slot == nil {
+				continue
+			}
+			agg, ok := slot.Fields[f]
+			if !ok {
+				continue
+			}
+			avg, _ := slot.Avg(f)
+			sv.Avg[i], sv.Min[i], sv.Max[i] = &avg, &agg.Min, &agg.Max
+		}
+		resp.Series[f] = sv
+	}
+	return resp
+}
+
+func defaultStep(tier Tier, span time.Duration) time.Duration {
+	step := (span / defaultQueryPoints).Round(time.Second)
+	return max(step, tier.Resolution, time.Second)
+}
+
+// parseQueryTime accepts RFC 3339, unix seconds or a negative duration relative to now
+func parseQueryTime(s string, now, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseFields checks a comma separated field list. Names that are no
+// AgentMetrics field are read as sample keys. Empty means all, and gives nil.
+func parseFields(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	known := make(map[string]bool)
+	for _, name := range metricFieldNames() {
+		known[name] = true
+	}
+
+	var fields []string
+	for _, f := range splitKeys(s) {
+		f = strings.TrimSpace(f)
+		if !known[f] {
+			key, err := parseSampleKey(f)
+			if err != nil {
+				return nil, fmt.Errorf("unknown field %q", f)
+			}
+			f = key
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// bucketSampleKeys lists the sample keys found in buckets, sorted
+func bucketSampleKeys(buckets []Bucket) []string {
+	known := make(map[string]bool)
+	for _, name := range metricFieldNames() {
+		known[name] = true
+	}
+	var keys []string
+	for _, b := range buckets {
+		for key := range b.Fields {
+			if !known[key] {
+				known[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// metricFieldNames lists the numeric AgentMetrics fields in declaration order
+func metricFieldNames() []string {
+	var names []string
+	eachNumericField(&pb.AgentMetrics{}, func(name string, _ float64) {
+		names = append(names, name)
+	})
+	return names
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/auth"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errUnknownAgent   = errors.New("unknown agent")
+	errRevokedAgent   = errors.New("agent has been revoked")
+	errForgottenAgent = errors.New("agent enrollment was deleted")
+)
+
+// AgentCredential is one enrolled agent. Only a hash of the secret is kept.
+type AgentCredential struct {
+	AgentID    string     `json:"agent_id"`
+	Hostname   string     `json:"hostname"`
+	SecretHash string     `json:"secret_hash"`
+	EnrolledAt time.Time  `json:"enrolled_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (c *AgentCredential) Revoked() bool {
+	return c.RevokedAt != nil
+}
+
+// AgentAuth keeps track of enrolled agents and persists them to a JSON file
+type AgentAuth struct {
+	sync.Mutex
+	path        string
+	joinTokens  []string
+	credentials map[string]*AgentCredential
+}
+
+// NewAgentAuth loads previously enrolled agents from path, if it exists
+func NewAgentAuth(path string, joinTokens []string) (*AgentAuth, error) {
+	a := &AgentAuth{
+		path:        path,
+		joinTokens:  joinTokens,
+		credentials: make(map[string]*AgentCredential),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading credentials file: %w", err)
+	}
+
+	var list []*AgentCredential
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("error parsing credentials file %s: %w", path, err)
+	}
+	for _, c := range list {
+		a.credentials[c.AgentID] = c
+	}
+	logger.Infof("Loaded %d agent credentials from %s", len(list), path)
+	return a, nil
+}
+
+// Enroll checks the join token and issues a new secret for agentID. An agent
+// ID can only enroll once: the join token alone must not be enough to take
+// over an enrolled agent by replacing its secret. An agent that lost its
+// secret enrolls again after an admin deleted its enrollment. Revoked agents
+// cannot enroll again.
+func (a *AgentAuth) Enroll(agentID, hostname, joinToken string) (string, error) {
+	if !a.validJoinToken(joinToken) {
+		return "", status.Error(codes.Unauthenticated, "invalid join token")
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	existing, ok := a.credentials[agentID]
+	switch {
+	case ok && existing.Revoked():
+		return "", status.Error(codes.PermissionDenied, errRevokedAgent.Error())
+	case ok:
+		return "", status.Error(codes.AlreadyExists, "agent is already enrolled, an admin must delete its enrollment before it can enroll again")
+	}
+
+	secret, err := auth.NewSecret()
+	if err != nil {
+		return "", status.Error(codes.Internal, "error generating secret")
+	}
+	a.credentials[agentID] = &AgentCredential{
+		AgentID:    agentID,
+		Hostname:   hostname,
+		SecretHash: auth.HashSecret(secret),
+		EnrolledAt: time.Now(),
+	}
+	if err := a.save(); err != nil {
+		// nothing was enrolled under this ID before, so there is nothing to restore
+		delete(a.credentials, agentID)
+		logger.Errorf("Error saving credentials: %v", err)
+		return "", status.Error(codes.Internal, "error saving credentials")
+	}
+	return secret, nil
+}
+
+// Verify checks an agent ID and secret pair
+func (a *AgentAuth) Verify(agentID, secret string) error {
+	a.Lock()
+	defer a.Unlock()
+
+	c, ok := a.credentials[agentID]
+	if !ok {
+		return errUnknownAgent
+	}
+	if c.Revoked() {
+		return errRevokedAgent
+	}
+	if !auth.Equal(c.SecretHash, auth.HashSecret(secret)) {
+		return errUnknownAgent
+	}
+	return nil
+}
+
+// Active reports whether agentID is enrolled and not revoked
+func (a *AgentAuth) Active(agentID string) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	c, ok := a.credentials[agentID]
+	return ok && !c.Revoked()
+}
+
+// Revoke marks an agent as revoked. It returns false if the agent is not enrolled.
+func (a *AgentAuth) Revoke(agentID string) (bool, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	c, ok := a.credentials[agentID]
+	if !ok {
+		return false, nil
+	}
+	if c.Revoked() {
+		return true, nil
+	}
+	now := time.Now()
+	c.RevokedAt = &now
+	if err := a.save(); err != nil {
+		c.RevokedAt = nil
+		return true, err
+	}
+	return true, nil
+}
+
+// Forget deletes the enrollment of an agent, revoked or not, so its ID can
+// enroll again. It returns false if the agent is not enrolled.
+func (a *AgentAuth) Forget(agentID string) (bool, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	c, ok := a.credentials[agentID]
+	if !ok {
+		return false, nil
+	}
+	delete(a.credentials, agentID)
+	if err := a.save(); err != nil {
+		a.credentials[agentID] = c
+		return true, err
+	}
+	return true, nil
+}
+
+// List returns all enrolled agents sorted by agent ID
+func (a *AgentAuth) List() []AgentCredential {
+	a.Lock()
+	defer a.Unlock()
+
+	list := make([]AgentCredential, 0, len(a.credentials))
+	for _, c := range a.credentials {
+		list = append(list, *c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AgentID < list[j].AgentID })
+	return list
+}
+
+func (a *AgentAuth) validJoinToken(token string) bool {
+	valid := false
+	for _, t := range a.joinTokens {
+		// no early return, compare against every token
+		if auth.Equal(t, token) {
+			valid = true
+		}
+	}
+	return token != "" && valid
+}
+
+// save writes the credentials file atomically. Caller must hold the lock.
+func (a *AgentAuth) save() error {
+	list := make([]*AgentCredential, 0, len(a.credentials))
+	for _, c := range a.credentials {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AgentID < list[j].AgentID })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0700); err != nil {
+		return err
+	}
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+type agentIDKey struct{}
+
+// authenticate pulls the agent credentials out of the call metadata
+func (a *AgentAuth) authenticate(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	ids := md.Get(auth.MetadataAgentID)
+	secrets := md.Get(auth.MetadataAgentSecret)
+	if len(ids) != 1 || len(secrets) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+
+	switch err := a.Verify(ids[0], secrets[0]); {
+	case errors.Is(err, errRevokedAgent):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, agentIDKey{}, ids[0]), nil
+}
+
+// Enroll is the only call that goes through without credentials
+func isEnrollMethod(fullMethod string) bool {
+	return fullMethod == pb.GlimpseService_Enroll_FullMethodName
+}
+
+func (a *AgentAuth) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isEnrollMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *AgentAuth) StreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
+
+// checkAgentID makes sure an authenticated agent only reports as itself.
+// Without auth there is nothing in the context and anything goes.
+func checkAgentID(ctx context.Context, agentID string) error {
+	authed, ok := ctx.Value(agentIDKey{}).(string)
+	if ok && authed != agentID {
+		return status.Error(codes.PermissionDenied, "agent id does not match credentials")
+	}
+	return nil
+}
@@ -3,48 +3,47 @@ package server
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"net/http"
 
 	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	"github.com/mansoormajeed/glimpse/internal/server/export"
 	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
 )
 
 //go:embed templates/*.html
 var templateFS embed.FS
-var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"bytes":     formatBytes,
+	"usedShare": fsUsedPercent,
+	"share":     sharePercent,
+	"byteRate":  formatByteRate,
+	"missing":   isUnavailable,
+	"sample":    formatSampleValue,
+}).ParseFS(templateFS, "templates/*.html"))
 
 type DashboardAgent struct {
+	AgentID         string
 	Hostname        string
 	OS              string
+	Status          AgentStatus
 	LastSeenAgo     string
 	FormattedUptime string
 	Metrics         *pb.AgentMetrics
+	Samples         []*pb.Sample
 }
 
-func StartHTTPServer(store *ServerStore) {
+// StartHTTPServer serves the dashboard and the APIs in the background until the
+// returned server is shut down
+func StartHTTPServer(store *ServerStore, forwarder *export.Forwarder, addr string) *http.Server {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		templates.ExecuteTemplate(w, "layout.html", nil)
 	})
 
 	http.HandleFunc("/agents", func(w http.ResponseWriter, r *http.Request) {
 
-		rawAgents := store.GetAllAgents()
-		agentList := make([]DashboardAgent, 0, len(rawAgents))
-
-		for _, a := range rawAgents {
-			latest := a.Latest()
-			if latest == nil {
-				continue
-			}
-			agentList = append(agentList, DashboardAgent{
-				Hostname:        a.Hostname,
-				OS:              a.OS,
-				LastSeenAgo:     formatRelative(a.LastSeen),
-				Metrics:         latest,
-				FormattedUptime: formatUptime(latest.Uptime),
-			})
-		}
+		agentList := dashboardAgents(store)
 
 		err := templates.ExecuteTemplate(w, "agents.html", agentList)
 		if err != nil {
@@ -54,32 +53,82 @@ func StartHTTPServer(store *ServerStore) {
 	})
 
 	http.HandleFunc("/agents/data", func(w http.ResponseWriter, r *http.Request) {
-		rawAgents := store.GetAllAgents()
-		agentList := make([]DashboardAgent, 0, len(rawAgents))
-
-		for _, a := range rawAgents {
-			latest := a.Latest()
-			if latest == nil {
-				continue
-			}
-			agentList = append(agentList, DashboardAgent{
-				Hostname:        a.Hostname,
-				OS:              a.OS,
-				LastSeenAgo:     formatRelative(a.LastSeen),
-				Metrics:         latest,
-				FormattedUptime: formatUptime(latest.Uptime),
-			})
-		}
+		agentList := dashboardAgents(store)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(agentList)
 	})
 
+	// detail page of one agent, with the top processes when the agent sends them
+	http.HandleFunc("GET /agent/{id}", func(w http.ResponseWriter, r *http.Request) {
+		agent, ok := dashboardAgentByID(store, r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if err := templates.ExecuteTemplate(w, "agent.html", agent); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	http.HandleFunc("GET /agent/{id}/data", func(w http.ResponseWriter, r *http.Request) {
+		agent, ok := dashboardAgentByID(store, r.PathValue("id"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, agent)
+	})
+
+	registerAPIHandlers(store)
+	registerPrometheusHandler(store, forwarder)
+
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 
+	srv := &http.Server{Addr: addr}
 	go func() {
-		if err := http.ListenAndServe(":5000", nil); err != nil {
+		logger.Infof("Starting HTTP server on %s...", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Fatalf("Failed to start HTTP server: %v", err)
 		}
 	}()
+	return srv
+}
+
+func dashboardAgents(store *ServerStore) []DashboardAgent {
+	rawAgents := store.GetAllAgents()
+	agentList := make([]DashboardAgent, 0, len(rawAgents))
+
+	for _, a := range rawAgents {
+		if agent, ok := dashboardAgent(a); ok {
+			agentList = append(agentList, agent)
+		}
+	}
+	return agentList
+}
+
+func dashboardAgentByID(store *ServerStore, agentID string) (DashboardAgent, bool) {
+	a, ok := store.GetAgentData(agentID)
+	if !ok {
+		return DashboardAgent{}, false
+	}
+	return dashboardAgent(a)
+}
+
+// dashboardAgent leaves out retired agents and those that have not sent metrics yet
+func dashboardAgent(a *AgentData) (DashboardAgent, bool) {
+	latest := a.Latest()
+	if latest == nil || a.Status == StatusRetired {
+		return DashboardAgent{}, false
+	}
+	return DashboardAgent{
+		AgentID:         a.AgentID,
+		Hostname:        a.Hostname,
+		OS:              a.OS,
+		Status:          a.Status,
+		LastSeenAgo:     formatRelative(a.LastSeen),
+		Metrics:         latest,
+		Samples:         a.LatestSamples(),
+		FormattedUptime: formatUptime(latest.Uptime),
+	}, true
 }
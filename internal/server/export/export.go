@@ -0,0 +1,303 @@
+// Package export forwards received samples to downstream metric stores over
+// Prometheus remote-write and OTLP/HTTP.
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	initialBackoff = time.Second
+	maxBackoff     = 30 * time.Second
+	stopTimeout    = 10 * time.Second // for the last flush on Stop
+)
+
+// Sample is one value of one series at one point in time
+type Sample struct {
+	Name      string
+	Labels    [][2]string // name, value
+	Value     float64
+	Timestamp time.Time
+}
+
+// Exporter sends one batch to a downstream system. Errors wrapped in
+// permanentError are not retried.
+type Exporter interface {
+	Export(ctx context.Context, batch []Sample) error
+}
+
+// permanentError is a failure retrying will not fix, like a 400 from the receiver
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Stats counts what happened to the samples of one target
+type Stats struct {
+	Name    string
+	Type    string // remote_write or otlp
+	Sent    uint64
+	Dropped uint64 // queue was full, or still queued when Stop ran out of time
+	Failed  uint64 // batch failed permanently or ran out of retries
+	Queued  int
+}
+
+// Forwarder fans samples out to every configured target. Each target has its
+// own bounded queue and worker, a slow receiver only drops its own samples.
+type Forwarder struct {
+	targets []*target
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+type target struct {
+	name          string
+	typ           string
+	exporter      Exporter
+	timeout       time.Duration
+	queue         chan Sample
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+
+	sent, dropped, failed atomic.Uint64
+}
+
+func NewForwarder(cfg config.ExportConfig) *Forwarder {
+	f := &Forwarder{stop: make(chan struct{})}
+	for _, tc := range cfg.RemoteWrite {
+		f.add(cfg, tc, "remote_write", &remoteWrite{url: tc.URL, headers: tc.Headers})
+	}
+	for _, tc := range cfg.OTLP {
+		f.add(cfg, tc, "otlp", &otlp{url: tc.URL, headers: tc.Headers})
+	}
+	return f
+}
+
+func (f *Forwarder) add(cfg config.ExportConfig, tc config.ExportTargetConfig, typ string, e Exporter) {
+	name := tc.Name
+	if name == "" {
+		name = typ
+		if u, err := url.Parse(tc.URL); err == nil {
+			name = u.Host
+		}
+	}
+	timeout := tc.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	f.targets = append(f.targets, &target{
+		name:          name,
+		typ:           typ,
+		exporter:      e,
+		timeout:       timeout,
+		queue:         make(chan Sample, cfg.QueueSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		maxRetries:    cfg.MaxRetries,
+	})
+}
+
+// Enabled reports whether there is anywhere to forward to
+func (f *Forwarder) Enabled() bool {
+	return f != nil && len(f.targets) > 0
+}
+
+func (f *Forwarder) Start() {
+	for _, t := range f.targets {
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			t.run(f.stop)
+		}()
+		logger.Infof("Forwarding samples to %s (%s)", t.name, t.typ)
+	}
+}
+
+// Stop makes one last attempt to send what is queued, within stopTimeout, and
+// waits for the workers
+func (f *Forwarder) Stop() {
+	close(f.stop)
+	f.wg.Wait()
+}
+
+// Forward queues samples on every target without blocking. Samples that do
+// not fit in a target's queue are dropped and counted.
+func (f *Forwarder) Forward(samples []Sample) {
+	if !f.Enabled() {
+		return
+	}
+	for _, t := range f.targets {
+		for _, s := range samples {
+			select {
+			case t.queue <- s:
+			default:
+				t.dropped.Add(1)
+			}
+		}
+	}
+}
+
+func (f *Forwarder) Stats() []Stats {
+	if f == nil {
+		return nil
+	}
+	stats := make([]Stats, 0, len(f.targets))
+	for _, t := range f.targets {
+		stats = append(stats, Stats{
+			Name:    t.name,
+			Type:    t.typ,
+			Sent:    t.sent.Load(),
+			Dropped: t.dropped.Load(),
+			Failed:  t.failed.Load(),
+			Queued:  len(t.queue),
+		})
+	}
+	return stats
+}
+
+// run collects samples into batches and sends a batch when it is full or the
+// flush interval passes, whichever comes first
+func (t *target) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Sample, 0, t.batchSize)
+	flush := func(ctx context.Context, retry bool) {
+		if len(batch) > 0 {
+			t.send(ctx, batch, retry, stop)
+			batch = make([]Sample, 0, t.batchSize)
+		}
+	}
+
+	for {
+		select {
+		case <-stop:
+			t.drain(batch)
+			return
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) == t.batchSize {
+				flush(context.Background(), true)
+			}
+		case <-ticker.C:
+			flush(context.Background(), true)
+		}
+	}
+}
+
+// drain sends batch and what is queued, without retries, dropping whatever is
+// left once stopTimeout runs out so shutdown stays quick
+func (t *target) drain(batch []Sample) {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+
+	for {
+		full := false
+		for !full {
+			select {
+			case s := <-t.queue:
+				batch = append(batch, s)
+				full = len(batch) == t.batchSize
+			default:
+				full = true
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if ctx.Err() != nil {
+			left := len(batch)
+			for len(t.queue) > 0 {
+				<-t.queue
+				left++
+			}
+			t.dropped.Add(uint64(left))
+			logger.Warnf("Dropping %d samples for %s, out of time to send them on shutdown", left, t.name)
+			return
+		}
+		t.send(ctx, batch, false, nil)
+		batch = make([]Sample, 0, t.batchSize)
+	}
+}
+
+// send exports one batch, each attempt limited by the target's timeout and
+// ctx. Without retry a failed batch is dropped right away.
+func (t *target) send(ctx context.Context, batch []Sample, retry bool, stop <-chan struct{}) {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, t.timeout)
+		err := t.exporter.Export(attemptCtx, batch)
+		cancel()
+		if err == nil {
+			t.sent.Add(uint64(len(batch)))
+			return
+		}
+
+		var perm permanentError
+		if errors.As(err, &perm) || !retry || attempt >= t.maxRetries {
+			t.failed.Add(uint64(len(batch)))
+			logger.Errorf("Dropping %d samples for %s after %d attempts: %v", len(batch), t.name, attempt+1, err)
+			return
+		}
+		logger.Warnf("Sending %d samples to %s failed, retrying in %s: %v", len(batch), t.name, backoff, err)
+
+		select {
+		case <-stop:
+			retry = false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+var httpClient = &http.Client{}
+
+// post sends body and sorts the response into success, a retryable error
+// (network errors, 429 and 5xx) or a permanent one (any other status)
+func post(ctx context.Context, url string, headers map[string]string, body []byte, extra map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	for k, v := range extra {
+		req.Header.Set(k, v)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("User-Agent", "glimpse-server")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return permanentError{err}
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+)
+
+// fakeExporter returns the errors in errs one call at a time, then nil
+type fakeExporter struct {
+	mu      sync.Mutex
+	errs    []error
+	calls   int
+	batches [][]Sample
+}
+
+func (e *fakeExporter) Export(ctx context.Context, batch []Sample) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.calls++
+	if len(e.errs) > 0 {
+		err := e.errs[0]
+		e.errs = e.errs[1:]
+		return err
+	}
+	e.batches = append(e.batches, batch)
+	return nil
+}
+
+func newTestTarget(e Exporter, queueSize, batchSize, maxRetries int) *target {
+	return &target{
+		name:          "test",
+		exporter:      e,
+		timeout:       time.Second,
+		queue:         make(chan Sample, queueSize),
+		batchSize:     batchSize,
+		flushInterval: time.Hour,
+		maxRetries:    maxRetries,
+	}
+}
+
+func samples(n int) []Sample {
+	out := make([]Sample, n)
+	for i := range out {
+		out[i] = Sample{Name: "s", Value: float64(i), Timestamp: time.Now()}
+	}
+	return out
+}
+
+func TestForwardDropsWhenQueueIsFull(t *testing.T) {
+	f := &Forwarder{targets: []*target{newTestTarget(&fakeExporter{}, 3, 10, 0)}}
+	f.Forward(samples(5))
+
+	stats := f.Stats()[0]
+	if stats.Queued != 3 || stats.Dropped != 2 {
+		t.Errorf("queued %d, dropped %d, want 3 and 2", stats.Queued, stats.Dropped)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	e := &fakeExporter{errs: []error{errors.New("connection refused")}}
+	tg := newTestTarget(e, 10, 10, 1)
+
+	start := time.Now()
+	tg.send(context.Background(), samples(4), true, make(chan struct{}))
+	if e.calls != 2 {
+		t.Errorf("got %d attempts, want 2", e.calls)
+	}
+	if elapsed := time.Since(start); elapsed < initialBackoff {
+		t.Errorf("retried after %s, want a backoff of at least %s", elapsed, initialBackoff)
+	}
+	if tg.sent.Load() != 4 || tg.failed.Load() != 0 {
+		t.Errorf("sent %d, failed %d, want 4 and 0", tg.sent.Load(), tg.failed.Load())
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		maxRetries int
+		retry      bool
+	}{
+		{"permanent", permanentError{errors.New("400 bad request")}, 3, true},
+		{"out of retries", errors.New("503 unavailable"), 0, true},
+		{"no retry", errors.New("503 unavailable"), 3, false},
+	}
+	for _, tt := range tests {
+		e := &fakeExporter{errs: []error{tt.err, tt.err, tt.err, tt.err}}
+		tg := newTestTarget(e, 10, 10, tt.maxRetries)
+		tg.send(context.Background(), samples(3), tt.retry, make(chan struct{}))
+
+		if e.calls != 1 {
+			t.Errorf("%s: got %d attempts, want 1", tt.name, e.calls)
+		}
+		if tg.sent.Load() != 0 || tg.failed.Load() != 3 {
+			t.Errorf("%s: sent %d, failed %d, want 0 and 3", tt.name, tg.sent.Load(), tg.failed.Load())
+		}
+	}
+}
+
+func TestSendStopsRetryingOnStop(t *testing.T) {
+	e := &fakeExporter{errs: []error{errors.New("503"), errors.New("503")}}
+	tg := newTestTarget(e, 10, 10, 5)
+	stop := make(chan struct{})
+	close(stop)
+
+	// the attempt after stop is the last one
+	tg.send(context.Background(), samples(2), true, stop)
+	if e.calls != 2 || tg.failed.Load() != 2 {
+		t.Errorf("got %d attempts and %d failed, want 2 and 2", e.calls, tg.failed.Load())
+	}
+}
+
+func TestStopFlushesQueue(t *testing.T) {
+	e := &fakeExporter{}
+	f := &Forwarder{stop: make(chan struct{}), targets: []*target{newTestTarget(e, 100, 4, 0)}}
+	f.Start()
+	f.Forward(samples(10))
+	f.Stop()
+
+	sent := 0
+	for _, b := range e.batches {
+		if len(b) > 4 {
+			t.Errorf("batch of %d samples, want at most 4", len(b))
+		}
+		sent += len(b)
+	}
+	if sent != 10 || f.Stats()[0].Sent != 10 {
+		t.Errorf("sent %d samples (stats %d), want 10", sent, f.Stats()[0].Sent)
+	}
+}
+
+func TestNewForwarderNames(t *testing.T) {
+	f := NewForwarder(config.ExportConfig{
+		QueueSize:     10,
+		BatchSize:     5,
+		FlushInterval: time.Second,
+		RemoteWrite:   []config.ExportTargetConfig{{URL: "http://prom:9090/api/v1/write"}},
+		OTLP:          []config.ExportTargetConfig{{Name: "collector", URL: "http://otel:4318/v1/metrics"}},
+	})
+	stats := f.Stats()
+	if len(stats) != 2 || stats[0].Name != "prom:9090" || stats[0].Type != "remote_write" || stats[1].Name != "collector" || stats[1].Type != "otlp" {
+		t.Errorf("targets = %+v", stats)
+	}
+	if !f.Enabled() {
+		t.Error("forwarder with targets is not enabled")
+	}
+	var none *Forwarder
+	if none.Enabled() {
+		t.Error("nil forwarder is enabled")
+	}
+}
@@ -0,0 +1,105 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+// otlp sends batches to an OTLP/HTTP receiver, e.g. http://collector:4318/v1/metrics,
+// using the JSON encoding of ExportMetricsServiceRequest. Every sample becomes
+// a gauge data point with its labels as attributes.
+type otlp struct {
+	url     string
+	headers map[string]string
+}
+
+func (o *otlp) Export(ctx context.Context, batch []Sample) error {
+	body, err := json.Marshal(buildOTLPRequest(batch))
+	if err != nil {
+		return permanentError{err}
+	}
+	return post(ctx, o.url, o.headers, body, map[string]string{"Content-Type": "application/json"})
+}
+
+// The types below mirror the parts of the OTLP metrics protos we use, with
+// the field names of the protobuf JSON mapping.
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name  string    `json:"name"`
+	Gauge otlpGauge `json:"gauge"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	Attributes   []otlpKeyValue `json:"attributes"`
+	TimeUnixNano string         `json:"timeUnixNano"` // 64 bit integers are strings in protobuf JSON
+	AsDouble     float64        `json:"asDouble"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func buildOTLPRequest(batch []Sample) otlpRequest {
+	var metrics []otlpMetric
+	byName := make(map[string]int)
+	for _, s := range batch {
+		i, ok := byName[s.Name]
+		if !ok {
+			i = len(metrics)
+			byName[s.Name] = i
+			metrics = append(metrics, otlpMetric{Name: s.Name})
+		}
+
+		attrs := make([]otlpKeyValue, 0, len(s.Labels))
+		for _, l := range s.Labels {
+			attrs = append(attrs, otlpKeyValue{Key: l[0], Value: otlpValue{StringValue: l[1]}})
+		}
+		metrics[i].Gauge.DataPoints = append(metrics[i].Gauge.DataPoints, otlpDataPoint{
+			Attributes:   attrs,
+			TimeUnixNano: strconv.FormatInt(s.Timestamp.UnixNano(), 10),
+			AsDouble:     s.Value,
+		})
+	}
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: otlpValue{StringValue: "glimpse"}},
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "glimpse"},
+			Metrics: metrics,
+		}},
+	}}}
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOTLP(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding the request: %v", err)
+		}
+		requests <- req
+	}))
+	defer srv.Close()
+
+	ts := time.Unix(1700000000, 123)
+	batch := []Sample{
+		{Name: "cpu", Labels: [][2]string{{"host", "a"}}, Value: 1.5, Timestamp: ts},
+		{Name: "mem", Value: 42, Timestamp: ts},
+		{Name: "cpu", Labels: [][2]string{{"host", "b"}}, Value: 2.5, Timestamp: ts},
+	}
+	if err := (&otlp{url: srv.URL}).Export(context.Background(), batch); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	req := <-requests
+	if len(req.ResourceMetrics) != 1 || len(req.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("want one resource with one scope, got %+v", req)
+	}
+	rm := req.ResourceMetrics[0]
+	if attrs := rm.Resource.Attributes; len(attrs) != 1 || attrs[0].Key != "service.name" || attrs[0].Value.StringValue != "glimpse" {
+		t.Errorf("resource attributes = %+v", attrs)
+	}
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	if len(metrics) != 2 || metrics[0].Name != "cpu" || metrics[1].Name != "mem" {
+		t.Fatalf("metrics = %+v, want cpu and mem in order", metrics)
+	}
+	points := metrics[0].Gauge.DataPoints
+	if len(points) != 2 {
+		t.Fatalf("cpu has %d data points, want 2", len(points))
+	}
+	p := points[1]
+	if p.AsDouble != 2.5 || p.TimeUnixNano != "1700000000000000123" {
+		t.Errorf("data point = %+v", p)
+	}
+	if len(p.Attributes) != 1 || p.Attributes[0].Key != "host" || p.Attributes[0].Value.StringValue != "b" {
+		t.Errorf("data point attributes = %+v", p.Attributes)
+	}
+	if attrs := metrics[1].Gauge.DataPoints[0].Attributes; attrs == nil || len(attrs) != 0 {
+		t.Errorf("mem attributes = %#v, want an empty list", attrs)
+	}
+}
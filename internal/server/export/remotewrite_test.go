@@ -0,0 +1,169 @@
+package export
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type writeSample struct {
+	value float64
+	ts    int64
+}
+
+type timeSeries struct {
+	labels  [][2]string
+	samples []writeSample
+}
+
+// fields splits an encoded message into its fields, calling fn with the
+// field number and the raw value of each
+func fields(t *testing.T, b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte)) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("bad tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			t.Fatalf("bad field %d: %v", num, protowire.ParseError(n))
+		}
+		fn(num, typ, b[:n])
+		b = b[n:]
+	}
+}
+
+func bytesValue(t *testing.T, v []byte) []byte {
+	t.Helper()
+	b, n := protowire.ConsumeBytes(v)
+	if n < 0 {
+		t.Fatalf("bad bytes: %v", protowire.ParseError(n))
+	}
+	return b
+}
+
+// decodeWriteRequest is the reverse of encodeWriteRequest
+func decodeWriteRequest(t *testing.T, b []byte) []timeSeries {
+	t.Helper()
+	var out []timeSeries
+	fields(t, b, func(num protowire.Number, _ protowire.Type, v []byte) {
+		if num != 1 {
+			t.Fatalf("unexpected WriteRequest field %d", num)
+		}
+		var ts timeSeries
+		fields(t, bytesValue(t, v), func(num protowire.Number, _ protowire.Type, v []byte) {
+			switch num {
+			case 1:
+				var l [2]string
+				fields(t, bytesValue(t, v), func(num protowire.Number, _ protowire.Type, v []byte) {
+					l[num-1] = string(bytesValue(t, v))
+				})
+				ts.labels = append(ts.labels, l)
+			case 2:
+				var s writeSample
+				fields(t, bytesValue(t, v), func(num protowire.Number, _ protowire.Type, v []byte) {
+					switch num {
+					case 1:
+						bits, _ := protowire.ConsumeFixed64(v)
+						s.value = math.Float64frombits(bits)
+					case 2:
+						ts, _ := protowire.ConsumeVarint(v)
+						s.ts = int64(ts)
+					}
+				})
+				ts.samples = append(ts.samples, s)
+			default:
+				t.Fatalf("unexpected TimeSeries field %d", num)
+			}
+		})
+		out = append(out, ts)
+	})
+	return out
+}
+
+func TestRemoteWrite(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Encoding"); got != "snappy" {
+			t.Errorf("Content-Encoding = %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/x-protobuf" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if got := r.Header.Get("X-Prometheus-Remote-Write-Version"); got != "0.1.0" {
+			t.Errorf("X-Prometheus-Remote-Write-Version = %q", got)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer rw" {
+			t.Errorf("Authorization = %q", got)
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("body is not snappy encoded: %v", err)
+		}
+		bodies <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	t0 := time.UnixMilli(1700000000000)
+	batch := []Sample{
+		{Name: "cpu", Labels: [][2]string{{"host", "a"}}, Value: 1.5, Timestamp: t0.Add(time.Second)},
+		{Name: "mem", Labels: [][2]string{{"host", "a"}}, Value: 42, Timestamp: t0},
+		// same series as the first, but earlier: samples are sorted by time
+		{Name: "cpu", Labels: [][2]string{{"host", "a"}}, Value: 0.5, Timestamp: t0},
+	}
+	rw := &remoteWrite{url: srv.URL, headers: map[string]string{"Authorization": "Bearer rw"}}
+	if err := rw.Export(context.Background(), batch); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	got := decodeWriteRequest(t, <-bodies)
+	if len(got) != 2 {
+		t.Fatalf("got %d series, want 2: %+v", len(got), got)
+	}
+	cpu := got[0]
+	if len(cpu.labels) != 2 || cpu.labels[0] != [2]string{"__name__", "cpu"} || cpu.labels[1] != [2]string{"host", "a"} {
+		t.Errorf("cpu labels = %v, want __name__ first and sorted", cpu.labels)
+	}
+	want := []writeSample{{0.5, t0.UnixMilli()}, {1.5, t0.UnixMilli() + 1000}}
+	if len(cpu.samples) != 2 || cpu.samples[0] != want[0] || cpu.samples[1] != want[1] {
+		t.Errorf("cpu samples = %v, want %v", cpu.samples, want)
+	}
+	if got[1].labels[0][1] != "mem" || len(got[1].samples) != 1 || got[1].samples[0].value != 42 {
+		t.Errorf("mem series = %+v", got[1])
+	}
+}
+
+func TestPostErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", tt.status)
+		}))
+		err := post(context.Background(), srv.URL, nil, nil, nil)
+		srv.Close()
+
+		_, permanent := err.(permanentError)
+		if err == nil || permanent != tt.permanent {
+			t.Errorf("status %d: err = %v, permanent %v, want permanent %v", tt.status, err, permanent, tt.permanent)
+		}
+	}
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"time"
+
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
 )
 
 func formatUptime(seconds int64) string {
@@ -30,6 +34,41 @@ func formatUptime(seconds int64) string {
 	}
 }
 
+// formatBytes renders a size with a binary unit, e.g. 1.5 GiB
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+func formatByteRate(perSecond float64) string {
+	return formatBytes(uint64(perSecond)) + "/s"
+}
+
+// fsUsedPercent is the share of the space usable by unprivileged users that
+// is taken, the same number df shows
+func fsUsedPercent(fs *pb.Filesystem) float64 {
+	if fs.UsedBytes+fs.FreeBytes == 0 {
+		return 0
+	}
+	return float64(fs.UsedBytes) / float64(fs.UsedBytes+fs.FreeBytes) * 100
+}
+
+// sharePercent is part as a percentage of total, 0 when total is unknown
+func sharePercent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
 func formatRelative(t time.Time) string {
 	diff := time.Since(t)
 
@@ -51,3 +90,21 @@ func formatRelative(t time.Time) string {
 		return t.Format("Jan 2, 2006 at 15:04")
 	}
 }
+
+// isUnavailable reports whether the agent could not collect field in its last heartbeat
+func isUnavailable(m *pb.AgentMetrics, field string) bool {
+	return unavailableFields(m)[field]
+}
+
+// formatSampleValue renders a sample with its unit. Byte sizes get a binary
+// unit, other values are rounded to two decimals.
+func formatSampleValue(s *pb.Sample) string {
+	if s.Unit == "bytes" && s.Value >= 0 {
+		return formatBytes(uint64(s.Value))
+	}
+	v := strconv.FormatFloat(math.Round(s.Value*100)/100, 'f', -1, 64)
+	if s.Unit == "" {
+		return v
+	}
+	return v + " " + s.Unit
+}
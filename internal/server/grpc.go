@@ -2,23 +2,51 @@ package server
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net"
+	"sync"
+	"time"
 
+	"github.com/mansoormajeed/glimpse/internal/common/config"
 	"github.com/mansoormajeed/glimpse/internal/common/logger"
 	"github.com/mansoormajeed/glimpse/internal/common/logger/util"
+	"github.com/mansoormajeed/glimpse/internal/server/export"
 	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type GlimpseServer struct {
 	pb.UnimplementedGlimpseServiceServer
-	store *ServerStore
+	store     *ServerStore
+	auth      *AgentAuth        // nil when agent auth is disabled
+	forwarder *export.Forwarder // nil when nothing is exported
+
+	// interval pushed to every agent when its stream opens. 0 leaves the agent's own setting alone
+	agentInterval time.Duration
+
+	streamsMu sync.Mutex
+	streams   map[string]*agentStream // by agent id
+	closing   bool                    // set by Shutdown, no new streams are taken
+}
+
+// agentStream is the open metrics stream of one agent
+type agentStream struct {
+	commands chan *pb.ServerCommand // waiting to be pushed
+	closed   chan struct{}          // closed by Disconnect
+	err      error                  // the status the stream is closed with after Disconnect
 }
 
 // NewGlimpseServer creates a new instance of GlimpseServer
-func NewGlimpseServer(store *ServerStore) *GlimpseServer {
+func NewGlimpseServer(store *ServerStore, agentAuth *AgentAuth, forwarder *export.Forwarder, cfg *config.ServerConfig) *GlimpseServer {
 	return &GlimpseServer{
-		store: store, // for now use buffer 5. later change to 60
+		store:         store,
+		auth:          agentAuth,
+		forwarder:     forwarder,
+		agentInterval: cfg.AgentHeartbeatInterval,
+		streams:       make(map[string]*agentStream),
 	}
 }
 
@@ -26,8 +54,10 @@ func (s *GlimpseServer) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest)
 	// Log the heartbeat request
 	logger.Debugf("Received heartbeat : %v", req)
 
-	s.store.AddOrUpdateAgent(req)
-	logger.Debugf("updated agent: %v", req.Hostname)
+	if err := checkAgentID(ctx, req.AgentId); err != nil {
+		return nil, err
+	}
+	s.handleHeartbeat(req)
 
 	resp := &pb.HeartbeatResponse{
 		Message:      "Heartbeat received",
@@ -35,23 +65,275 @@ func (s *GlimpseServer) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest)
 		StatusCode:   200,
 		ErrorMessage: "",
 	}
-	logger.Debugf(util.PrettyYaml(s.store.agents))
 	return resp, nil
 }
 
-func StartGRPCServer(store *ServerStore) {
-	logger.Info("Starting gRPC server on port 5001...")
-	lis, err := net.Listen("tcp", ":5001")
+// StreamMetrics receives heartbeats over a long lived stream and pushes any
+// queued commands for that agent back down the same stream.
+func (s *GlimpseServer) StreamMetrics(stream pb.GlimpseService_StreamMetricsServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	agentID := first.AgentId
+	if agentID == "" {
+		stream.Send(&pb.ServerCommand{
+			Type:   pb.CommandType_COMMAND_REREGISTER,
+			Reason: "missing agent id",
+		})
+		return status.Error(codes.InvalidArgument, "missing agent id")
+	}
+	if err := checkAgentID(stream.Context(), agentID); err != nil {
+		return err
+	}
+
+	logger.Infof("Agent %s (%s) opened a metrics stream", first.Hostname, agentID)
+	st := s.registerStream(agentID)
+	if st == nil {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	defer s.unregisterStream(agentID, st)
+
+	if s.agentInterval > 0 {
+		err := stream.Send(&pb.ServerCommand{
+			Type:       pb.CommandType_COMMAND_SET_INTERVAL,
+			IntervalMs: s.agentInterval.Milliseconds(),
+			Reason:     "server configured interval",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	s.store.AgentConnected(agentID)
+	s.handleHeartbeat(first)
+
+	// Recv and Send may run concurrently, but each only from a single goroutine
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			if req.AgentId != agentID {
+				recvErr <- status.Error(codes.InvalidArgument, "agent id changed mid-stream")
+				return
+			}
+			// Disconnect cuts off the stream of a revoked agent, this catches a
+			// heartbeat that was already on its way
+			if s.auth != nil && !s.auth.Active(agentID) {
+				recvErr <- status.Error(codes.PermissionDenied, errRevokedAgent.Error())
+				return
+			}
+			s.handleHeartbeat(req)
+		}
+	}()
+
+	for {
+		select {
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				logger.Infof("Agent %s closed its metrics stream", agentID)
+				return nil
+			}
+			return err
+		case cmd := <-st.commands:
+			if err := stream.Send(cmd); err != nil {
+				return err
+			}
+		case <-st.closed:
+			logger.Infof("Closing the metrics stream of agent %s: %v", agentID, status.Convert(st.err).Message())
+			return st.err
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// Enroll issues a per-agent secret in exchange for a valid join token
+func (s *GlimpseServer) Enroll(ctx context.Context, req *pb.EnrollRequest) (*pb.EnrollResponse, error) {
+	if s.auth == nil {
+		return nil, status.Error(codes.FailedPrecondition, "agent enrollment is not enabled on this server")
+	}
+	if req.AgentId == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing agent id")
+	}
+
+	secret, err := s.auth.Enroll(req.AgentId, req.Hostname, req.JoinToken)
+	if err != nil {
+		logger.Warnf("Rejected enrollment for agent %s (%s): %v", req.AgentId, req.Hostname, err)
+		return nil, err
+	}
+	logger.Infof("Enrolled agent %s (%s)", req.AgentId, req.Hostname)
+	return &pb.EnrollResponse{AgentSecret: secret}, nil
+}
+
+// SendCommand queues a command for an agent with an open stream. It returns
+// false if the agent is not connected over a stream or its queue is full.
+func (s *GlimpseServer) SendCommand(agentID string, cmd *pb.ServerCommand) bool {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	st, ok := s.streams[agentID]
+	if !ok {
+		return false
+	}
+	select {
+	case st.commands <- cmd:
+		return true
+	default:
+		return false
+	}
+}
+
+// Disconnect closes the open stream of an agent, if it has one, with err. err
+// should be a gRPC status, its code tells the agent what to do next.
+func (s *GlimpseServer) Disconnect(agentID string, err error) {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	if st, ok := s.streams[agentID]; ok {
+		st.err = err
+		close(st.closed)
+		delete(s.streams, agentID)
+	}
+}
+
+// Shutdown closes every open metrics stream, which would otherwise keep a
+// graceful stop of the gRPC server waiting forever. Agents reconnect later.
+func (s *GlimpseServer) Shutdown() {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	s.closing = true
+	for agentID, st := range s.streams {
+		st.err = status.Error(codes.Unavailable, "server is shutting down")
+		close(st.closed)
+		delete(s.streams, agentID)
+	}
+}
+
+// registerStream returns nil once the server is shutting down
+func (s *GlimpseServer) registerStream(agentID string) *agentStream {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	if s.closing {
+		return nil
+	}
+
+	// a reconnecting agent replaces its old stream
+	st := &agentStream{
+		commands: make(chan *pb.ServerCommand, 8),
+		closed:   make(chan struct{}),
+	}
+	s.streams[agentID] = st
+	return st
+}
+
+func (s *GlimpseServer) unregisterStream(agentID string, st *agentStream) {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+
+	if s.streams[agentID] == st {
+		delete(s.streams, agentID)
+	}
+}
+
+func (s *GlimpseServer) handleHeartbeat(req *pb.HeartbeatRequest) {
+	req.Samples = cleanSamples(req.AgentId, req.Samples)
+	// metrics from the future are taken as live. Ones older than every tier
+	// keeps would only be pruned again, and their delay may not even fit a
+	// time.Duration, so they are dropped before it is converted.
+	req.DelayMs = max(req.DelayMs, 0)
+	if req.DelayMs > s.store.maxRetention().Milliseconds() {
+		logger.Debugf("Dropping heartbeat of agent %s collected %dms ago, older than any history is kept", req.AgentId, req.DelayMs)
+		return
+	}
+	s.store.AddOrUpdateAgent(req)
+	if s.forwarder.Enabled() {
+		s.forwarder.Forward(heartbeatSamples(req, time.Now().Add(-time.Duration(req.DelayMs)*time.Millisecond)))
+	}
+	logger.Debugf("updated agent: %v", req.Hostname)
+	logger.Debug(util.PrettyYaml(req))
+}
+
+// heartbeatSamples turns a heartbeat into one sample per AgentMetrics field
+// and generic sample, named and labeled like the series on /metrics
+func heartbeatSamples(req *pb.HeartbeatRequest, ts time.Time) []export.Sample {
+	labels := [][2]string{{"agent_id", req.AgentId}, {"hostname", req.Hostname}, {"os", req.Os}}
+
+	var samples []export.Sample
+	for _, s := range req.Samples {
+		name, typ := sampleFamily(s)
+		if typ == "counter" {
+			name += "_total"
+		}
+		samples = append(samples, export.Sample{
+			Name:      name,
+			Labels:    withSampleLabels(labels, s),
+			Value:     s.Value,
+			Timestamp: ts,
+		})
+	}
+	if req.Metrics == nil {
+		return samples
+	}
+	eachNumericField(req.Metrics, func(name string, v float64) {
+		samples = append(samples, export.Sample{
+			Name:      "glimpse_agent_" + name,
+			Labels:    labels,
+			Value:     v,
+			Timestamp: ts,
+		})
+	})
+	eachLabeledSeries(req.Metrics, func(name, _ string, own [][2]string, v float64) {
+		samples = append(samples, export.Sample{
+			Name:      "glimpse_agent_" + name,
+			Labels:    withLabels(labels, own),
+			Value:     v,
+			Timestamp: ts,
+		})
+	})
+	return samples
+}
+
+// StartGRPCServer serves in the background until the returned server is stopped
+func StartGRPCServer(glimpseServer *GlimpseServer, addr string, opts ...grpc.ServerOption) *grpc.Server {
+	logger.Infof("Starting gRPC server on %s...", addr)
+	lis, err := net.Listen("tcp", addr)
 
 	if err != nil {
 		logger.Fatalf("Failed to listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
-	glimpseServer := NewGlimpseServer(store)
+	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterGlimpseServiceServer(grpcServer, glimpseServer)
 
-	if err := grpcServer.Serve(lis); err != nil {
-		logger.Fatalf("Failed to serve: %v", err)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			logger.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+	return grpcServer
+}
+
+// StopGRPCServer closes the agent streams and waits up to timeout for the
+// calls in progress to finish before cutting them off
+func StopGRPCServer(grpcServer *grpc.Server, glimpseServer *GlimpseServer, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	glimpseServer.Shutdown()
+
+	select {
+	case <-stopped:
+	case <-time.After(timeout):
+		logger.Warn("gRPC calls still running after the shutdown timeout, closing them")
+		grpcServer.Stop()
 	}
 }
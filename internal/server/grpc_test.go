@@ -0,0 +1,48 @@
+package server
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+func TestHandleHeartbeatDelay(t *testing.T) {
+	tests := []struct {
+		name  string
+		delay int64 // ms
+		kept  bool
+		age   time.Duration // of the stored sample
+	}{
+		{"live", 0, true, 0},
+		{"from the future", -5000, true, 0},
+		{"replayed", time.Minute.Milliseconds(), true, time.Minute},
+		{"at the longest retention", time.Hour.Milliseconds(), true, time.Hour},
+		// older than the 1h rollups keep
+		{"past retention", time.Hour.Milliseconds() + 1, false, 0},
+		// would overflow a time.Duration
+		{"overflow", math.MaxInt64, false, 0},
+	}
+	for _, tt := range tests {
+		s := &GlimpseServer{store: NewServerStore(10, nil, testTiers)}
+		before := time.Now()
+		s.handleHeartbeat(&pb.HeartbeatRequest{AgentId: "agent-1", Metrics: &pb.AgentMetrics{}, DelayMs: tt.delay})
+
+		agent, ok := s.store.GetAgentData("agent-1")
+		if ok != tt.kept {
+			t.Errorf("%s: kept = %v, want %v", tt.name, ok, tt.kept)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		history := agent.History()
+		if len(history) != 1 {
+			t.Fatalf("%s: got %d samples, want 1", tt.name, len(history))
+		}
+		if age := before.Sub(history[0].Timestamp); age > tt.age || age < tt.age-time.Second {
+			t.Errorf("%s: sample is %s old, want %s", tt.name, age, tt.age)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+)
+
+// chatWebhook posts to a Slack, Discord or Matrix (hookshot) incoming webhook.
+// They all take a single text message, only the field name differs.
+type chatWebhook struct {
+	kind string
+	url  string
+}
+
+func (c *chatWebhook) Notify(ctx context.Context, n Notification) error {
+	var msg map[string]string
+	switch c.kind {
+	case "slack":
+		msg = map[string]string{"text": "*" + n.Title() + "*\n" + n.Message()}
+	case "discord":
+		msg = map[string]string{"content": "**" + n.Title() + "**\n" + n.Message()}
+	case "matrix":
+		msg = map[string]string{"text": n.Title() + "\n" + n.Message(), "username": "glimpse"}
+	}
+	return postJSON(ctx, c.url, nil, msg)
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+)
+
+const (
+	queueSize      = 100
+	sendTimeout    = 10 * time.Second
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+	rateWindow     = time.Minute
+	stopTimeout    = 10 * time.Second // for sending what is still queued on Stop
+)
+
+var ErrUnknownNotifier = errors.New("unknown notifier")
+
+// Dispatcher routes notifications to the notifiers of their rule. Every
+// notifier has its own queue and worker, so a slow or failing channel does not
+// hold up the others.
+type Dispatcher struct {
+	workers      map[string]*worker
+	routes       map[string][]string // rule name -> notifier names
+	defaults     []string
+	sendResolved bool
+	stop         chan struct{}
+	running      sync.WaitGroup
+}
+
+type worker struct {
+	name       string
+	notifier   Notifier
+	queue      chan Notification
+	maxRetries int
+	rateLimit  int
+
+	mu   sync.Mutex
+	sent []time.Time // send times within the last rateWindow
+}
+
+// NewDispatcher builds the configured notifiers and the per rule routes
+func NewDispatcher(cfg config.NotificationsConfig, rules []config.AlertRuleConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		workers:      make(map[string]*worker),
+		routes:       make(map[string][]string),
+		defaults:     cfg.Default,
+		sendResolved: cfg.SendResolved,
+		stop:         make(chan struct{}),
+	}
+	for _, nc := range cfg.Notifiers {
+		n, err := New(nc)
+		if err != nil {
+			return nil, err
+		}
+		d.workers[nc.Name] = &worker{
+			name:       nc.Name,
+			notifier:   n,
+			queue:      make(chan Notification, queueSize),
+			maxRetries: cfg.MaxRetries,
+			rateLimit:  cfg.RateLimit,
+		}
+	}
+	for _, r := range rules {
+		if len(r.Notify) > 0 {
+			d.routes[r.Name] = r.Notify
+		}
+	}
+	return d, nil
+}
+
+func (d *Dispatcher) Start() {
+	for _, w := range d.workers {
+		d.running.Add(1)
+		go func() {
+			defer d.running.Done()
+			w.run(d.stop)
+		}()
+	}
+	if len(d.workers) > 0 {
+		logger.Infof("Sending alert notifications to %d notifiers", len(d.workers))
+	}
+}
+
+// Stop waits for the workers to send what is still queued, one attempt per
+// notification, within stopTimeout. Dispatch must not be called after Stop.
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.running.Wait()
+}
+
+// Dispatch queues a notification for every notifier routed from its rule. It
+// never blocks: notifications over the rate limit or a full queue are dropped.
+func (d *Dispatcher) Dispatch(n Notification) {
+	if n.Resolved() && !d.sendResolved {
+		return
+	}
+
+	names, ok := d.routes[n.Rule]
+	if !ok {
+		names = d.defaults
+	}
+	for _, name := range names {
+		w, ok := d.workers[name]
+		if !ok {
+			continue
+		}
+		if !w.allow(time.Now()) {
+			logger.Warnf("Notifier %s is over its rate limit, dropping %s", name, n.Title())
+			continue
+		}
+		select {
+		case w.queue <- n:
+		default:
+			logger.Warnf("Notifier %s queue is full, dropping %s", name, n.Title())
+		}
+	}
+}
+
+// Test sends a sample notification through one notifier right away, without
+// retries or rate limiting, and returns the error from the channel if any
+func (d *Dispatcher) Test(ctx context.Context, name string) error {
+	w, ok := d.workers[name]
+	if !ok {
+		return ErrUnknownNotifier
+	}
+
+	now := time.Now()
+	n := Notification{
+		Rule:     "test",
+		Expr:     "cpu_usage > 90 for 5m",
+		Severity: "warning",
+		AgentID:  "00000000-0000-0000-0000-000000000000",
+		Hostname: "glimpse-test",
+		State:    "firing",
+		Value:    95,
+		Since:    now.Add(-5 * time.Minute),
+		Time:     now,
+		Test:     true,
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return w.notifier.Notify(ctx, n)
+}
+
+// allow records a send if the notifier is still under its rate limit
+func (w *worker) allow(now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cutoff := now.Add(-rateWindow)
+	i := 0
+	for i < len(w.sent) && w.sent[i].Before(cutoff) {
+		i++
+	}
+	w.sent = w.sent[i:]
+
+	if len(w.sent) >= w.rateLimit {
+		return false
+	}
+	w.sent = append(w.sent, now)
+	return true
+}
+
+func (w *worker) run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			w.drain()
+			return
+		case n := <-w.queue:
+			w.send(n, stop)
+		}
+	}
+}
+
+// drain tries each queued notification once, dropping the ones left when
+// stopTimeout runs out
+func (w *worker) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	for {
+		select {
+		case n := <-w.queue:
+			if ctx.Err() != nil {
+				logger.Warnf("Notifier %s is shutting down, dropping %d notifications", w.name, len(w.queue)+1)
+				return
+			}
+			sendCtx, cancelSend := context.WithTimeout(ctx, sendTimeout)
+			err := w.notifier.Notify(sendCtx, n)
+			cancelSend()
+			if err != nil {
+				logger.Errorf("Notifier %s failed to send %s while shutting down: %v", w.name, n.Title(), err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// send delivers one notification, retrying with exponential backoff
+func (w *worker) send(n Notification, stop <-chan struct{}) {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err := w.notifier.Notify(ctx, n)
+		cancel()
+		if err == nil {
+			logger.Debugf("Notifier %s sent %s", w.name, n.Title())
+			return
+		}
+		if attempt >= w.maxRetries {
+			logger.Errorf("Notifier %s failed to send %s, giving up after %d attempts: %v", w.name, n.Title(), attempt+1, err)
+			return
+		}
+		logger.Warnf("Notifier %s failed to send %s, retrying in %s: %v", w.name, n.Title(), backoff, err)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
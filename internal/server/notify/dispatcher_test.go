@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+)
+
+func newTestDispatcher(t *testing.T, url string, maxRetries, rateLimit int) *Dispatcher {
+	t.Helper()
+	d, err := NewDispatcher(config.NotificationsConfig{
+		Notifiers:    []config.NotifierConfig{{Name: "hook", Type: "webhook", URL: url}},
+		Default:      []string{"hook"},
+		SendResolved: true,
+		RateLimit:    rateLimit,
+		MaxRetries:   maxRetries,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestDispatcherRetriesServerErrors(t *testing.T) {
+	srv, requests := recorder(t, http.StatusServiceUnavailable)
+	d := newTestDispatcher(t, srv.URL, 1, 10)
+	d.Start()
+	defer d.Stop()
+
+	start := time.Now()
+	d.Dispatch(testNotification("firing", "warning"))
+	for i := 0; i < 2; i++ {
+		select {
+		case <-requests:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d attempts, want 2", i)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < initialBackoff {
+		t.Errorf("retried after %s, want a backoff of at least %s", elapsed, initialBackoff)
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxRetries(t *testing.T) {
+	srv, requests := recorder(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	d := newTestDispatcher(t, srv.URL, 0, 10)
+	d.Start()
+
+	d.Dispatch(testNotification("firing", "warning"))
+	<-requests
+	d.Stop()
+	if len(requests) != 0 {
+		t.Errorf("got %d more attempts, want none with max_retries 0", len(requests))
+	}
+}
+
+func TestDispatcherRateLimit(t *testing.T) {
+	d := newTestDispatcher(t, "http://127.0.0.1:0", 0, 2)
+	for i := 0; i < 5; i++ {
+		d.Dispatch(testNotification("firing", "warning"))
+	}
+	if got := len(d.workers["hook"].queue); got != 2 {
+		t.Errorf("queued %d notifications, want the rate limit of 2", got)
+	}
+}
+
+func TestWorkerAllowWindow(t *testing.T) {
+	w := &worker{rateLimit: 1}
+	now := time.Now()
+	if !w.allow(now) {
+		t.Fatal("first send was not allowed")
+	}
+	if w.allow(now.Add(rateWindow / 2)) {
+		t.Error("second send within the window was allowed")
+	}
+	if !w.allow(now.Add(rateWindow + time.Second)) {
+		t.Error("send after the window was not allowed")
+	}
+}
+
+func TestDispatcherRoutes(t *testing.T) {
+	d, err := NewDispatcher(config.NotificationsConfig{
+		Notifiers: []config.NotifierConfig{
+			{Name: "a", Type: "webhook", URL: "http://127.0.0.1:0"},
+			{Name: "b", Type: "webhook", URL: "http://127.0.0.1:0"},
+		},
+		Default:   []string{"a"},
+		RateLimit: 10,
+	}, []config.AlertRuleConfig{{Name: "disk", Notify: []string{"b"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := testNotification("firing", "warning")
+	d.Dispatch(n)
+	n.Rule = "disk"
+	d.Dispatch(n)
+	// resolved notifications are not sent unless send_resolved is set
+	d.Dispatch(testNotification("resolved", "warning"))
+
+	if got := len(d.workers["a"].queue); got != 1 {
+		t.Errorf("notifier a has %d queued, want 1", got)
+	}
+	if got := len(d.workers["b"].queue); got != 1 {
+		t.Errorf("notifier b has %d queued, want 1", got)
+	}
+}
+
+// blockingNotifier holds every Notify until release is closed
+type blockingNotifier struct {
+	release chan struct{}
+	mu      sync.Mutex
+	sent    int
+}
+
+func (b *blockingNotifier) Notify(ctx context.Context, n Notification) error {
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	b.mu.Lock()
+	b.sent++
+	b.mu.Unlock()
+	return nil
+}
+
+func TestDispatcherStopSendsQueued(t *testing.T) {
+	d := newTestDispatcher(t, "http://127.0.0.1:0", 0, 10)
+	b := &blockingNotifier{release: make(chan struct{})}
+	d.workers["hook"].notifier = b
+	d.Start()
+
+	// the first one keeps the worker busy while the rest queue up
+	for i := 0; i < 3; i++ {
+		d.Dispatch(testNotification("firing", "warning"))
+	}
+	stopped := make(chan struct{})
+	go func() {
+		d.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned with a notification still being sent")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(b.release)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+	if b.sent != 3 {
+		t.Errorf("sent %d notifications before Stop returned, want 3", b.sent)
+	}
+}
@@ -0,0 +1,118 @@
+// Package notify sends alert notifications to external channels: webhooks,
+// email, push services and chat webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+)
+
+// Notification is an alert changing state, as sent to notifiers
+type Notification struct {
+	Rule     string    `json:"rule"`
+	Expr     string    `json:"expr"`
+	Severity string    `json:"severity"`
+	AgentID  string    `json:"agent_id"`
+	Hostname string    `json:"hostname"`
+	State    string    `json:"state"` // firing or resolved
+	Value    float64   `json:"value"`
+	Since    time.Time `json:"since"` // when the condition started holding
+	Time     time.Time `json:"time"`  // when the alert fired or resolved
+	Test     bool      `json:"test,omitempty"`
+}
+
+func (n Notification) Resolved() bool {
+	return n.State == "resolved"
+}
+
+// Title is a one line summary, used as subject or push title
+func (n Notification) Title() string {
+	title := fmt.Sprintf("[%s] %s on %s", strings.ToUpper(n.State), n.Rule, n.Hostname)
+	if n.Test {
+		title = "[TEST] " + title
+	}
+	return title
+}
+
+// Message is the plain text body
+func (n Notification) Message() string {
+	if n.Resolved() {
+		return fmt.Sprintf("%s no longer holds (value %g), active since %s", n.Expr, n.Value, n.Since.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s (value %g) since %s", n.Expr, n.Value, n.Since.Format(time.RFC3339))
+}
+
+// Notifier delivers a notification to one channel. Notify should not retry,
+// the Dispatcher takes care of that.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// New builds the notifier for one configured channel
+func New(cfg config.NotifierConfig) (Notifier, error) {
+	switch cfg.Type {
+	case "webhook":
+		return &webhook{url: cfg.URL, headers: cfg.Headers}, nil
+	case "smtp":
+		return newSMTP(cfg.SMTP), nil
+	case "ntfy":
+		return &ntfy{url: cfg.URL, token: cfg.Token}, nil
+	case "gotify":
+		return &gotify{url: cfg.URL, token: cfg.Token}, nil
+	case "slack", "discord", "matrix":
+		return &chatWebhook{kind: cfg.Type, url: cfg.URL}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON posts v as JSON and treats any non 2xx response as an error
+func postJSON(ctx context.Context, url string, headers map[string]string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return do(req)
+}
+
+func do(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// webhook posts the notification as JSON to any URL
+type webhook struct {
+	url     string
+	headers map[string]string
+}
+
+func (w *webhook) Notify(ctx context.Context, n Notification) error {
+	return postJSON(ctx, w.url, w.headers, n)
+}
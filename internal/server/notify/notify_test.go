@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+)
+
+// request is what a fake server saw of one notification
+type request struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// recorder answers every request with the next status from statuses, 200
+// once they run out, and passes the requests on to requests
+func recorder(t *testing.T, statuses ...int) (*httptest.Server, <-chan request) {
+	t.Helper()
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{path: r.URL.Path, header: r.Header.Clone(), body: body}
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func testNotification(state, severity string) Notification {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return Notification{
+		Rule:     "high_cpu",
+		Expr:     "cpu_usage > 90 for 5m",
+		Severity: severity,
+		AgentID:  "agent-1",
+		Hostname: "web-1",
+		State:    state,
+		Value:    95.5,
+		Since:    since,
+		Time:     since.Add(5 * time.Minute),
+	}
+}
+
+func notify(t *testing.T, cfg config.NotifierConfig, n Notification) {
+	t.Helper()
+	notifier, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	srv, requests := recorder(t)
+	n := testNotification("firing", "warning")
+	notify(t, config.NotifierConfig{Type: "webhook", URL: srv.URL + "/hook", Headers: map[string]string{"X-Token": "secret"}}, n)
+
+	r := <-requests
+	if r.path != "/hook" {
+		t.Errorf("path = %q, want /hook", r.path)
+	}
+	if got := r.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := r.header.Get("X-Token"); got != "secret" {
+		t.Errorf("X-Token = %q, want secret", got)
+	}
+	var got Notification
+	if err := json.Unmarshal(r.body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != n {
+		t.Errorf("body = %+v, want %+v", got, n)
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	srv, _ := recorder(t, http.StatusBadGateway)
+	notifier, _ := New(config.NotifierConfig{Type: "webhook", URL: srv.URL})
+	err := notifier.Notify(context.Background(), testNotification("firing", "warning"))
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("Notify = %v, want a 502 error", err)
+	}
+}
+
+func TestNtfy(t *testing.T) {
+	tests := []struct {
+		state, severity string
+		priority, tags  string
+	}{
+		{"firing", "warning", "", "warning"},
+		{"firing", "critical", "urgent", "rotating_light"},
+		{"resolved", "critical", "low", "white_check_mark"},
+	}
+	for _, tt := range tests {
+		srv, requests := recorder(t)
+		n := testNotification(tt.state, tt.severity)
+		notify(t, config.NotifierConfig{Type: "ntfy", URL: srv.URL + "/alerts", Token: "tk"}, n)
+
+		r := <-requests
+		if r.path != "/alerts" {
+			t.Errorf("path = %q, want /alerts", r.path)
+		}
+		if got := r.header.Get("Title"); got != n.Title() {
+			t.Errorf("Title = %q, want %q", got, n.Title())
+		}
+		if got := r.header.Get("Priority"); got != tt.priority {
+			t.Errorf("%s %s: Priority = %q, want %q", tt.state, tt.severity, got, tt.priority)
+		}
+		if got := r.header.Get("Tags"); got != tt.tags {
+			t.Errorf("%s %s: Tags = %q, want %q", tt.state, tt.severity, got, tt.tags)
+		}
+		if got := r.header.Get("Authorization"); got != "Bearer tk" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if string(r.body) != n.Message() {
+			t.Errorf("body = %q, want %q", r.body, n.Message())
+		}
+	}
+}
+
+func TestGotify(t *testing.T) {
+	tests := []struct {
+		state, severity string
+		priority        int
+	}{
+		{"firing", "warning", 5},
+		{"firing", "critical", 8},
+		{"resolved", "critical", 2},
+	}
+	for _, tt := range tests {
+		srv, requests := recorder(t)
+		n := testNotification(tt.state, tt.severity)
+		// the trailing slash of the base URL is not doubled
+		notify(t, config.NotifierConfig{Type: "gotify", URL: srv.URL + "/", Token: "app"}, n)
+
+		r := <-requests
+		if r.path != "/message" {
+			t.Errorf("path = %q, want /message", r.path)
+		}
+		if got := r.header.Get("X-Gotify-Key"); got != "app" {
+			t.Errorf("X-Gotify-Key = %q", got)
+		}
+		var msg struct {
+			Title    string `json:"title"`
+			Message  string `json:"message"`
+			Priority int    `json:"priority"`
+		}
+		if err := json.Unmarshal(r.body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Title != n.Title() || msg.Message != n.Message() || msg.Priority != tt.priority {
+			t.Errorf("%s %s: got %+v, want priority %d", tt.state, tt.severity, msg, tt.priority)
+		}
+	}
+}
+
+func TestChatWebhooks(t *testing.T) {
+	n := testNotification("firing", "critical")
+	tests := []struct {
+		kind string
+		want map[string]string
+	}{
+		{"slack", map[string]string{"text": "*" + n.Title() + "*\n" + n.Message()}},
+		{"discord", map[string]string{"content": "**" + n.Title() + "**\n" + n.Message()}},
+		{"matrix", map[string]string{"text": n.Title() + "\n" + n.Message(), "username": "glimpse"}},
+	}
+	for _, tt := range tests {
+		srv, requests := recorder(t)
+		notify(t, config.NotifierConfig{Type: tt.kind, URL: srv.URL}, n)
+
+		var got map[string]string
+		if err := json.Unmarshal((<-requests).body, &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: body = %v, want %v", tt.kind, got, tt.want)
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %q, want %q", tt.kind, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestNewUnknownType(t *testing.T) {
+	if _, err := New(config.NotifierConfig{Type: "pager"}); err == nil {
+		t.Error("New accepted an unknown notifier type")
+	}
+}
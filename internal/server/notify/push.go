@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// ntfy publishes to a topic URL, e.g. https://ntfy.sh/my-alerts
+type ntfy struct {
+	url   string
+	token string
+}
+
+func (p *ntfy) Notify(ctx context.Context, n Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, strings.NewReader(n.Message()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", n.Title())
+
+	switch {
+	case n.Resolved():
+		req.Header.Set("Priority", "low")
+		req.Header.Set("Tags", "white_check_mark")
+	case n.Severity == "critical":
+		req.Header.Set("Priority", "urgent")
+		req.Header.Set("Tags", "rotating_light")
+	default:
+		req.Header.Set("Tags", "warning")
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	return do(req)
+}
+
+// gotify sends to a Gotify server with an application token. url is the server's base URL.
+type gotify struct {
+	url   string
+	token string
+}
+
+func (p *gotify) Notify(ctx context.Context, n Notification) error {
+	priority := 5
+	switch {
+	case n.Resolved():
+		priority = 2
+	case n.Severity == "critical":
+		priority = 8
+	}
+
+	msg := map[string]interface{}{
+		"title":    n.Title(),
+		"message":  n.Message(),
+		"priority": priority,
+	}
+	return postJSON(ctx, strings.TrimSuffix(p.url, "/")+"/message", map[string]string{"X-Gotify-Key": p.token}, msg)
+}
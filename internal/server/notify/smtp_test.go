@@ -0,0 +1,172 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	netmail "net/mail"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+)
+
+// mail is what the fake SMTP server received in one session
+type mail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts a single plain text session and hands over what it got
+func fakeSMTP(t *testing.T) (string, <-chan mail) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	mails := make(chan mail, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ESMTP")
+
+		var m mail
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				m.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				m.to = append(m.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				m.data = data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				mails <- m
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return lis.Addr().String(), mails
+}
+
+func TestSMTP(t *testing.T) {
+	addr, mails := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+
+	notifier, err := New(config.NotifierConfig{Type: "smtp", SMTP: config.SMTPConfig{
+		Host: host,
+		Port: p,
+		From: "glimpse@example.com",
+		To:   []string{"ops@example.com", "oncall@example.com"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := testNotification("firing", "critical")
+	if err := notifier.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	m := <-mails
+	if m.from != "glimpse@example.com" {
+		t.Errorf("MAIL FROM = %q", m.from)
+	}
+	if strings.Join(m.to, ",") != "ops@example.com,oncall@example.com" {
+		t.Errorf("RCPT TO = %v", m.to)
+	}
+	for _, want := range []string{
+		"Subject: " + n.Title() + "\r\n",
+		"To: ops@example.com, oncall@example.com\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		n.Message(),
+		"Agent: web-1 (agent-1)\r\n",
+		"Severity: critical\r\n",
+	} {
+		if !strings.Contains(m.data, want) {
+			t.Errorf("message is missing %q:\n%s", want, m.data)
+		}
+	}
+}
+
+func TestSMTPSubjectFromAgent(t *testing.T) {
+	tests := []struct {
+		hostname, subject string
+	}{
+		// a line break would otherwise add a Bcc header
+		{"web\r\nBcc: victim@example.com", "[FIRING] high_cpu on web Bcc: victim@example.com"},
+		{"web\n\nbody", "[FIRING] high_cpu on web body"},
+		{"wéb-ünïcode", "[FIRING] high_cpu on wéb-ünïcode"},
+	}
+	for _, tt := range tests {
+		n := testNotification("firing", "critical")
+		n.Hostname = tt.hostname
+		data := newSMTP(config.SMTPConfig{Host: "mail", From: "glimpse@example.com", To: []string{"ops@example.com"}}).message(n)
+
+		msg, err := netmail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.hostname, err)
+		}
+		if bcc := msg.Header.Get("Bcc"); bcc != "" {
+			t.Errorf("%q: injected Bcc header %q", tt.hostname, bcc)
+		}
+		raw := msg.Header.Get("Subject")
+		for _, r := range raw {
+			if r > 127 {
+				t.Errorf("%q: subject %q is not ASCII", tt.hostname, raw)
+				break
+			}
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(raw)
+		if err != nil || subject != tt.subject {
+			t.Errorf("%q: subject = %q (%v), want %q", tt.hostname, subject, err, tt.subject)
+		}
+	}
+}
+
+func TestSMTPDefaultPort(t *testing.T) {
+	if got := newSMTP(config.SMTPConfig{Host: "mail"}).addr; got != "mail:25" {
+		t.Errorf("addr = %q, want mail:25", got)
+	}
+	if got := newSMTP(config.SMTPConfig{Host: "mail", StartTLS: true}).addr; got != "mail:587" {
+		t.Errorf("addr with starttls = %q, want mail:587", got)
+	}
+}
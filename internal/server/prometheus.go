@@ -0,0 +1,280 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	"github.com/mansoormajeed/glimpse/internal/server/export"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+const (
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// metricFamily is one metric name with its samples. Counter names are kept
+// without the _total suffix, it is added when rendering.
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string // gauge or counter
+	samples []promSample
+}
+
+type promSample struct {
+	labels      [][2]string
+	value       float64
+	timestampMs int64 // 0 leaves the time to the scraper
+}
+
+// promAgent is what /metrics needs to know about an agent, copied out of the store
+type promAgent struct {
+	AgentID  string
+	Hostname string
+	OS       string
+	Status   AgentStatus
+	LastSeen float64 // unix seconds
+	Latest   *pb.AgentMetrics
+	Samples  []*pb.Sample
+}
+
+// storeStats are the server's own numbers
+type storeStats struct {
+	heartbeats uint64
+	samples    int // samples held in the ring buffers
+	agents     []promAgent
+}
+
+func (s *ServerStore) promSnapshot() storeStats {
+	s.Lock()
+	defer s.Unlock()
+
+	stats := storeStats{heartbeats: s.heartbeats}
+	for _, a := range s.agents {
+		stats.samples += a.metricsCount
+		stats.agents = append(stats.agents, promAgent{
+			AgentID:  a.AgentID,
+			Hostname: a.Hostname,
+			OS:       a.OS,
+			Status:   a.Status,
+			LastSeen: float64(a.LastSeen.UnixNano()) / 1e9,
+			Latest:   a.Latest(),
+			Samples:  a.LatestSamples(),
+		})
+	}
+	sort.Slice(stats.agents, func(i, j int) bool { return stats.agents[i].AgentID < stats.agents[j].AgentID })
+	return stats
+}
+
+// registerPrometheusHandler serves the latest metrics of every agent and the
+// server's own metrics at /metrics. OpenMetrics is used when the scraper asks for it.
+func registerPrometheusHandler(store *ServerStore, forwarder *export.Forwarder) {
+	http.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+		if openMetrics {
+			w.Header().Set("Content-Type", openMetricsContentType)
+		} else {
+			w.Header().Set("Content-Type", textContentType)
+		}
+
+		bw := bufio.NewWriter(w)
+		families := promFamilies(store.promSnapshot())
+		families = append(families, exportFamilies(forwarder.Stats())...)
+		writeFamilies(bw, families, openMetrics)
+		if err := bw.Flush(); err != nil {
+			logger.Debugf("Error writing /metrics response: %v", err)
+		}
+	})
+}
+
+func promFamilies(stats storeStats) []metricFamily {
+	up := metricFamily{name: "glimpse_agent_up", typ: "gauge", help: "1 if the agent is online or late, 0 if it is offline."}
+	lastSeen := metricFamily{name: "glimpse_agent_last_seen_timestamp_seconds", typ: "gauge", help: "Unix time of the agent's last heartbeat."}
+
+	// one family per AgentMetrics field, in declaration order
+	var fieldFamilies []*metricFamily
+	byField := make(map[string]*metricFamily)
+	for _, name := range metricFieldNames() {
+		f := &metricFamily{name: "glimpse_agent_" + name, typ: "gauge", help: "Latest " + name + " reported by the agent."}
+		fieldFamilies = append(fieldFamilies, f)
+		byField[name] = f
+	}
+	// families of the repeated messages and the generic samples, in the order
+	// they are first seen, by family name. Sample names cannot start with
+	// glimpse_, so they do not mix with the built-in series.
+	var seriesFamilies []*metricFamily
+	bySeries := make(map[string]*metricFamily)
+	sampleTypes := make(map[string]string) // family name -> type, of the generic samples
+
+	statusCounts := make(map[AgentStatus]int)
+	for _, a := range stats.agents {
+		statusCounts[a.Status]++
+		if a.Status == StatusRetired {
+			continue
+		}
+
+		labels := [][2]string{{"agent_id", a.AgentID}, {"hostname", a.Hostname}, {"os", a.OS}}
+		v := 0.0
+		if a.Status < StatusOffline {
+			v = 1
+		}
+		up.samples = append(up.samples, promSample{labels, v, 0})
+		lastSeen.samples = append(lastSeen.samples, promSample{labels, a.LastSeen, 0})
+
+		for _, s := range a.Samples {
+			name, typ := sampleFamily(s)
+			// cleanSamples keeps the families of one agent apart, agents may still disagree
+			if familyClash(sampleTypes, name, typ) {
+				logger.Debugf("Leaving sample %q of agent %s out of /metrics: its type clashes with another agent's", s.Name, a.AgentID)
+				continue
+			}
+			sampleTypes[name] = typ
+			f, ok := bySeries[name]
+			if !ok {
+				help := s.Help
+				switch {
+				case help != "":
+				case s.Unit != "":
+					help = "Reported by the agent, in " + s.Unit + "."
+				default:
+					help = "Reported by the agent."
+				}
+				f = &metricFamily{name: name, typ: typ, help: help}
+				seriesFamilies = append(seriesFamilies, f)
+				bySeries[name] = f
+			}
+			f.samples = append(f.samples, promSample{withSampleLabels(labels, s), s.Value, s.TimestampMs})
+		}
+
+		if a.Latest == nil {
+			continue
+		}
+		eachNumericField(a.Latest, func(name string, v float64) {
+			f := byField[name]
+			f.samples = append(f.samples, promSample{labels, v, 0})
+		})
+		eachLabeledSeries(a.Latest, func(name, help string, own [][2]string, v float64) {
+			name = "glimpse_agent_" + name
+			f, ok := bySeries[name]
+			if !ok {
+				f = &metricFamily{name: name, typ: "gauge", help: help}
+				seriesFamilies = append(seriesFamilies, f)
+				bySeries[name] = f
+			}
+			f.samples = append(f.samples, promSample{withLabels(labels, own), v, 0})
+		})
+	}
+
+	agents := metricFamily{name: "glimpse_agents", typ: "gauge", help: "Number of known agents by status."}
+	for _, st := range []AgentStatus{StatusOnline, StatusLate, StatusOffline, StatusRetired} {
+		agents.samples = append(agents.samples, promSample{[][2]string{{"status", st.String()}}, float64(statusCounts[st]), 0})
+	}
+
+	families := []metricFamily{up, lastSeen}
+	for _, f := range append(fieldFamilies, seriesFamilies...) {
+		families = append(families, *f)
+	}
+	return append(families,
+		agents,
+		metricFamily{
+			name: "glimpse_heartbeats_received", typ: "counter", help: "Heartbeats received from agents since the server started.",
+			samples: []promSample{{value: float64(stats.heartbeats)}},
+		},
+		metricFamily{
+			name: "glimpse_store_samples", typ: "gauge", help: "Samples held in the in-memory ring buffers.",
+			samples: []promSample{{value: float64(stats.samples)}},
+		},
+	)
+}
+
+// exportFamilies reports what happened to the samples forwarded to each export target
+func exportFamilies(stats []export.Stats) []metricFamily {
+	if len(stats) == 0 {
+		return nil
+	}
+	sent := metricFamily{name: "glimpse_export_samples_sent", typ: "counter", help: "Samples delivered to an export target."}
+	dropped := metricFamily{name: "glimpse_export_samples_dropped", typ: "counter", help: "Samples dropped before or after sending, by reason."}
+	queued := metricFamily{name: "glimpse_export_queue_length", typ: "gauge", help: "Samples waiting to be sent to an export target."}
+	for _, st := range stats {
+		labels := [][2]string{{"target", st.Name}, {"type", st.Type}}
+		sent.samples = append(sent.samples, promSample{labels, float64(st.Sent), 0})
+		dropped.samples = append(dropped.samples,
+			promSample{append(labels[:2:2], [2]string{"reason", "queue_full"}), float64(st.Dropped), 0},
+			promSample{append(labels[:2:2], [2]string{"reason", "failed"}), float64(st.Failed), 0},
+		)
+		queued.samples = append(queued.samples, promSample{labels, float64(st.Queued), 0})
+	}
+	return []metricFamily{sent, dropped, queued}
+}
+
+// writeFamilies renders families in the Prometheus text format, or in
+// OpenMetrics which differs in counter metadata and the closing # EOF
+func writeFamilies(w *bufio.Writer, families []metricFamily, openMetrics bool) {
+	for _, f := range families {
+		sampleName := f.name
+		if f.typ == "counter" {
+			sampleName += "_total"
+		}
+		metaName := sampleName
+		if openMetrics {
+			metaName = f.name
+		}
+
+		fmt.Fprintf(w, "# HELP %s %s\n", metaName, helpEscaper.Replace(f.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", metaName, f.typ)
+		for _, s := range f.samples {
+			w.WriteString(sampleName)
+			if len(s.labels) > 0 {
+				w.WriteByte('{')
+				for i, l := range s.labels {
+					if i > 0 {
+						w.WriteByte(',')
+					}
+					fmt.Fprintf(w, "%s=\"%s\"", l[0], escapeLabel(l[1]))
+				}
+				w.WriteByte('}')
+			}
+			w.WriteByte(' ')
+			w.WriteString(formatPromValue(s.value))
+			switch {
+			case s.timestampMs != 0 && openMetrics:
+				// OpenMetrics timestamps are in seconds
+				w.WriteString(" " + strconv.FormatFloat(float64(s.timestampMs)/1000, 'f', -1, 64))
+			case s.timestampMs != 0:
+				w.WriteString(" " + strconv.FormatInt(s.timestampMs, 10))
+			}
+			w.WriteByte('\n')
+		}
+	}
+	if openMetrics {
+		w.WriteString("# EOF\n")
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// helpEscaper escapes HELP texts, which can come from the agents
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatPromValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
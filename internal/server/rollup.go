@@ -0,0 +1,290 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Tier is one resolution history is kept at. The raw tier has Resolution 0.
+type Tier struct {
+	Name       string
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+func (t Tier) Raw() bool {
+	return t.Resolution == 0
+}
+
+// TiersFromConfig returns the raw tier followed by the configured rollup tiers,
+// finest first
+func TiersFromConfig(cfg config.ServerStorageConfig) []Tier {
+	tiers := []Tier{{Name: "raw", Retention: cfg.Retention}}
+	for _, r := range cfg.Rollups {
+		tiers = append(tiers, Tier{
+			Name:       tierName(r.Resolution),
+			Resolution: r.Resolution,
+			Retention:  r.Retention,
+		})
+	}
+	return tiers
+}
+
+// tierName turns a resolution into a short name like 1m or 15m, used for directories
+func tierName(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
+
+// FieldAgg is the min/max/sum of one metric over a bucket. Count is the
+// samples that had the metric, 0 in buckets written before it was tracked,
+// which had it in every sample.
+type FieldAgg struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Sum   float64 `json:"sum"`
+	Count int     `json:"count,omitempty"`
+}
+
+// samples is the number of samples the field was aggregated over
+func (a FieldAgg) samples(bucketCount int) int {
+	if a.Count == 0 {
+		return bucketCount
+	}
+	return a.Count
+}
+
+// Bucket aggregates every numeric AgentMetrics field and every sample series
+// over [Start, Start+resolution). A raw sample is a bucket of one.
+type Bucket struct {
+	Start  time.Time
+	Count  int
+	Fields map[string]FieldAgg // keyed by proto field name, e.g. cpu_usage, or sampleKey
+}
+
+func (b *Bucket) Avg(field string) (float64, bool) {
+	agg, ok := b.Fields[field]
+	n := agg.samples(b.Count)
+	if !ok || n == 0 {
+		return 0, false
+	}
+	return agg.Sum / float64(n), true
+}
+
+// add folds one entry into the bucket
+func (b *Bucket) add(e MetricEntry) {
+	first := b.Count == 0
+	b.Count++
+	each := func(name string, v float64) {
+		agg, ok := b.Fields[name]
+		if first || !ok {
+			// fields that were missing earlier in the bucket start fresh
+			agg = FieldAgg{Min: v, Max: v}
+		} else {
+			agg.Min = math.Min(agg.Min, v)
+			agg.Max = math.Max(agg.Max, v)
+		}
+		agg.Sum += v
+		agg.Count++
+		b.Fields[name] = agg
+	}
+	if e.Metrics != nil {
+		eachNumericField(e.Metrics, each)
+	}
+	for _, s := range e.Samples {
+		each(sampleKey(s), s.Value)
+	}
+}
+
+// merge folds another bucket into this one
+func (b *Bucket) merge(o Bucket) {
+	for name, agg := range o.Fields {
+		agg.Count = agg.samples(o.Count)
+		cur, ok := b.Fields[name]
+		if !ok {
+			b.Fields[name] = agg
+			continue
+		}
+		cur.Min = math.Min(cur.Min, agg.Min)
+		cur.Max = math.Max(cur.Max, agg.Max)
+		cur.Sum += agg.Sum
+		cur.Count = cur.samples(b.Count) + agg.Count
+		b.Fields[name] = cur
+	}
+	b.Count += o.Count
+}
+
+func bucketFromEntry(e MetricEntry) Bucket {
+	b := Bucket{Start: e.Timestamp, Fields: make(map[string]FieldAgg)}
+	if e.Metrics != nil || len(e.Samples) > 0 {
+		b.add(e)
+	}
+	return b
+}
+
+// eachNumericField calls fn for every singular numeric field of m, including
+// unset (zero) ones, so new AgentMetrics fields are rolled up without code changes.
+// Fields of collectors the agent reported unavailable are skipped, their zero
+// is not a value.
+func eachNumericField(m *pb.AgentMetrics, fn func(name string, v float64)) {
+	unavailable := unavailableFields(m)
+	msg := m.ProtoReflect()
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsList() || fd.IsMap() || unavailable[string(fd.Name())] {
+			continue
+		}
+		v := msg.Get(fd)
+		switch fd.Kind() {
+		case protoreflect.Int32Kind, protoreflect.Int64Kind,
+			protoreflect.Sint32Kind, protoreflect.Sint64Kind,
+			protoreflect.Sfixed32Kind, protoreflect.Sfixed64Kind:
+			fn(string(fd.Name()), float64(v.Int()))
+		case protoreflect.Uint32Kind, protoreflect.Uint64Kind,
+			protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+			fn(string(fd.Name()), float64(v.Uint()))
+		case protoreflect.FloatKind, protoreflect.DoubleKind:
+			fn(string(fd.Name()), v.Float())
+		}
+	}
+}
+
+func unavailableFields(m *pb.AgentMetrics) map[string]bool {
+	var fields map[string]bool
+	for _, u := range m.GetUnavailable() {
+		for _, f := range u.Fields {
+			if fields == nil {
+				fields = make(map[string]bool)
+			}
+			fields[f] = true
+		}
+	}
+	return fields
+}
+
+// tierBuffer holds the finished buckets of one rollup tier plus the one being filled
+type tierBuffer struct {
+	tier    Tier
+	current *Bucket
+	buckets []Bucket // finished, oldest first
+}
+
+// add folds a sample into the tier. It returns the bucket that was finished
+// or, for a late sample, changed by it, if any, so the caller can persist it.
+func (t *tierBuffer) add(e MetricEntry) *Bucket {
+	start := e.Timestamp.Truncate(t.tier.Resolution)
+
+	var finished *Bucket
+	if t.current != nil && !t.current.Start.Equal(start) {
+		if start.Before(t.current.Start) {
+			return t.addLate(start, e)
+		}
+		// late samples can still change a finished bucket, the caller gets a copy to persist
+		done := t.current.copy()
+		t.buckets = append(t.buckets, *t.current)
+		finished = &done
+		t.current = nil
+	}
+	if t.current == nil {
+		t.current = &Bucket{Start: start, Fields: make(map[string]FieldAgg)}
+	}
+	t.current.add(e)
+
+	t.trim(e.Timestamp)
+	return finished
+}
+
+// addLate folds a replayed sample into a bucket that is already finished,
+// creating it when the agent sent nothing else for that time. It returns the
+// bucket, which is persisted again and replaces the earlier record of it.
+func (t *tierBuffer) addLate(start time.Time, e MetricEntry) *Bucket {
+	if start.Before(t.current.Start.Add(-t.tier.Retention)) {
+		return nil
+	}
+	i := sort.Search(len(t.buckets), func(i int) bool { return !t.buckets[i].Start.Before(start) })
+	if i == len(t.buckets) || !t.buckets[i].Start.Equal(start) {
+		t.buckets = slices.Insert(t.buckets, i, Bucket{Start: start, Fields: make(map[string]FieldAgg)})
+	}
+	t.buckets[i].add(e)
+	b := t.buckets[i].copy()
+	return &b
+}
+
+// trim drops buckets that fell out of retention
+func (t *tierBuffer) trim(now time.Time) {
+	cutoff := now.Add(-t.tier.Retention)
+	i := 0
+	for i < len(t.buckets) && t.buckets[i].Start.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		t.buckets = append([]Bucket(nil), t.buckets[i:]...)
+	}
+}
+
+// query returns copies of the buckets overlapping [from, to], including the one in progress
+func (t *tierBuffer) query(from, to time.Time) []Bucket {
+	var out []Bucket
+	visit := func(b Bucket) {
+		end := b.Start.Add(t.tier.Resolution)
+		if end.After(from) && !b.Start.After(to) {
+			out = append(out, b.copy())
+		}
+	}
+	for _, b := range t.buckets {
+		visit(b)
+	}
+	if t.current != nil {
+		visit(*t.current)
+	}
+	return out
+}
+
+func (b Bucket) copy() Bucket {
+	fields := make(map[string]FieldAgg, len(b.Fields))
+	for k, v := range b.Fields {
+		fields[k] = v
+	}
+	b.Fields = fields
+	return b
+}
+
+// pickTier returns the finest tier that still reaches back to from. With a
+// step, the coarsest tier that is not coarser than the step is preferred,
+// since anything finer would be merged down again anyway.
+func pickTier(tiers []Tier, now, from time.Time, step time.Duration) Tier {
+	age := now.Sub(from)
+
+	var covering []Tier
+	for _, t := range tiers {
+		if t.Retention >= age {
+			covering = append(covering, t)
+		}
+	}
+	if len(covering) == 0 {
+		// nothing goes back that far, the longest kept tier is the best we have
+		return tiers[len(tiers)-1]
+	}
+
+	best := covering[0]
+	for _, t := range covering[1:] {
+		if step > 0 && t.Resolution <= step {
+			best = t
+		}
+	}
+	return best
+}
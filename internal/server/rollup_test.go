@@ -0,0 +1,254 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+func TestTiersFromConfig(t *testing.T) {
+	tiers := TiersFromConfig(config.ServerStorageConfig{
+		Retention: 10 * time.Minute,
+		Rollups: []config.RollupConfig{
+			{Resolution: 30 * time.Second, Retention: time.Hour},
+			{Resolution: 15 * time.Minute, Retention: 24 * time.Hour},
+			{Resolution: 2 * time.Hour, Retention: 90 * 24 * time.Hour},
+		},
+	})
+	want := []string{"raw", "30s", "15m", "2h"}
+	if len(tiers) != len(want) {
+		t.Fatalf("got %d tiers, want %d", len(tiers), len(want))
+	}
+	for i, name := range want {
+		if tiers[i].Name != name {
+			t.Errorf("tier %d = %s, want %s", i, tiers[i].Name, name)
+		}
+	}
+	if !tiers[0].Raw() || tiers[0].Retention != 10*time.Minute || tiers[1].Raw() {
+		t.Errorf("tiers = %+v", tiers)
+	}
+}
+
+func TestBucketAdd(t *testing.T) {
+	b := Bucket{Fields: make(map[string]FieldAgg)}
+	b.add(MetricEntry{Metrics: &pb.AgentMetrics{CpuUsage: 10}})
+	b.add(MetricEntry{Metrics: &pb.AgentMetrics{CpuUsage: 30}, Samples: []*pb.Sample{{Name: "ups_charge", Value: 90}}})
+	// a collector that was unavailable does not count as a zero
+	b.add(MetricEntry{Metrics: &pb.AgentMetrics{
+		CpuUsage:    20,
+		Unavailable: []*pb.UnavailableCollector{{Fields: []string{"cpu_usage"}}},
+	}})
+
+	if b.Count != 3 {
+		t.Errorf("Count = %d, want 3", b.Count)
+	}
+	if cpu := b.Fields["cpu_usage"]; cpu.Min != 10 || cpu.Max != 30 || cpu.Sum != 40 || cpu.Count != 2 {
+		t.Errorf("cpu_usage = %+v", cpu)
+	}
+	if avg, ok := b.Avg("cpu_usage"); !ok || avg != 20 {
+		t.Errorf("cpu_usage avg = %g, %v, want 20", avg, ok)
+	}
+	// averaged over the samples that had it, not the whole bucket
+	if avg, ok := b.Avg("ups_charge"); !ok || avg != 90 {
+		t.Errorf("ups_charge avg = %g, %v, want 90", avg, ok)
+	}
+	if _, ok := b.Avg("missing"); ok {
+		t.Error("Avg of a field that is not in the bucket is ok")
+	}
+}
+
+func TestBucketMerge(t *testing.T) {
+	b := Bucket{Count: 2, Fields: map[string]FieldAgg{"cpu_usage": {Min: 10, Max: 20, Sum: 30, Count: 2}}}
+	// written before fields were counted, so every sample had them
+	b.merge(Bucket{Count: 3, Fields: map[string]FieldAgg{
+		"cpu_usage": {Min: 5, Max: 15, Sum: 30},
+		"load1":     {Min: 1, Max: 1, Sum: 3},
+	}})
+
+	if b.Count != 5 {
+		t.Errorf("Count = %d, want 5", b.Count)
+	}
+	if cpu := b.Fields["cpu_usage"]; cpu.Min != 5 || cpu.Max != 20 || cpu.Sum != 60 || cpu.Count != 5 {
+		t.Errorf("cpu_usage = %+v", cpu)
+	}
+	if load := b.Fields["load1"]; load.Count != 3 {
+		t.Errorf("load1 = %+v, want it counted over its own 3 samples", load)
+	}
+}
+
+func cpuEntry(ts time.Time, cpu int64) MetricEntry {
+	return MetricEntry{Timestamp: ts, Metrics: &pb.AgentMetrics{CpuUsage: cpu}}
+}
+
+func TestTierBufferClosesBuckets(t *testing.T) {
+	tb := &tierBuffer{tier: Tier{Name: "1m", Resolution: time.Minute, Retention: 10 * time.Minute}}
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, off := range []time.Duration{5 * time.Second, 20 * time.Second, 59 * time.Second} {
+		if b := tb.add(cpuEntry(t0.Add(off), 10)); b != nil {
+			t.Fatalf("bucket finished at %s", off)
+		}
+	}
+	finished := tb.add(cpuEntry(t0.Add(70*time.Second), 40))
+	if finished == nil {
+		t.Fatal("the first sample of the next minute did not finish the bucket")
+	}
+	if !finished.Start.Equal(t0) || finished.Count != 3 {
+		t.Errorf("finished = %+v, want the bucket at %s with 3 samples", finished, t0)
+	}
+	if !tb.current.Start.Equal(t0.Add(time.Minute)) || tb.current.Count != 1 {
+		t.Errorf("current = %+v", tb.current)
+	}
+
+	// the caller gets a copy, so a late sample does not change what it persists
+	tb.add(cpuEntry(t0.Add(30*time.Second), 100))
+	if finished.Count != 3 || finished.Fields["cpu_usage"].Max != 10 {
+		t.Errorf("late sample changed the finished copy: %+v", finished)
+	}
+
+	// skipping minutes leaves no empty buckets behind
+	tb.add(cpuEntry(t0.Add(5*time.Minute), 1))
+	if len(tb.buckets) != 2 || !tb.buckets[1].Start.Equal(t0.Add(time.Minute)) {
+		t.Errorf("buckets = %+v", tb.buckets)
+	}
+
+	// buckets out of retention are trimmed
+	tb.add(cpuEntry(t0.Add(11*time.Minute), 1))
+	if len(tb.buckets) != 2 || !tb.buckets[0].Start.Equal(t0.Add(time.Minute)) {
+		t.Errorf("after trimming buckets start at %s, want %s", tb.buckets[0].Start, t0.Add(time.Minute))
+	}
+	tb.add(cpuEntry(t0.Add(12*time.Minute), 1))
+	if tb.buckets[0].Start.Equal(t0.Add(time.Minute)) {
+		t.Error("bucket older than the retention was kept")
+	}
+}
+
+func TestTierBufferAddLate(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		late    time.Duration // from t0
+		changed bool          // a bucket is returned to persist
+		start   time.Duration // of that bucket, from t0
+		count   int
+		buckets int
+	}{
+		{"finished bucket", 30 * time.Second, true, 0, 2, 2},
+		// the agent sent nothing for that minute, the bucket is created in order
+		{"gap", 90 * time.Second, true, time.Minute, 1, 3},
+		{"before every bucket", -5 * time.Minute, true, -5 * time.Minute, 1, 3},
+		{"past retention", -20 * time.Minute, false, 0, 0, 2},
+	}
+	for _, tt := range tests {
+		tb := &tierBuffer{tier: Tier{Name: "1m", Resolution: time.Minute, Retention: 10 * time.Minute}}
+		tb.add(cpuEntry(t0, 10))
+		tb.add(cpuEntry(t0.Add(2*time.Minute), 10))
+		tb.add(cpuEntry(t0.Add(3*time.Minute), 10))
+
+		b := tb.add(cpuEntry(t0.Add(tt.late), 50))
+		switch {
+		case !tt.changed && b != nil:
+			t.Errorf("%s: got bucket at %s, want none", tt.name, b.Start.Sub(t0))
+		case tt.changed && b == nil:
+			t.Errorf("%s: got no bucket", tt.name)
+		case b != nil && (!b.Start.Equal(t0.Add(tt.start)) || b.Count != tt.count):
+			t.Errorf("%s: bucket at %s with %d samples, want %s with %d", tt.name, b.Start.Sub(t0), b.Count, tt.start, tt.count)
+		}
+		if len(tb.buckets) != tt.buckets {
+			t.Errorf("%s: %d finished buckets, want %d", tt.name, len(tb.buckets), tt.buckets)
+		}
+		for i := 1; i < len(tb.buckets); i++ {
+			if !tb.buckets[i-1].Start.Before(tb.buckets[i].Start) {
+				t.Errorf("%s: buckets out of order: %s then %s", tt.name, tb.buckets[i-1].Start, tb.buckets[i].Start)
+			}
+		}
+		if !tb.current.Start.Equal(t0.Add(3 * time.Minute)) {
+			t.Errorf("%s: late sample moved the current bucket to %s", tt.name, tb.current.Start)
+		}
+	}
+}
+
+func TestTierBufferQuery(t *testing.T) {
+	tb := &tierBuffer{tier: Tier{Name: "1m", Resolution: time.Minute, Retention: time.Hour}}
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		tb.add(cpuEntry(t0.Add(time.Duration(i)*time.Minute), int64(i)))
+	}
+	// the bucket starting before from still overlaps it, the one in progress is included
+	got := tb.query(t0.Add(90*time.Second), t0.Add(10*time.Minute))
+	if len(got) != 4 || !got[0].Start.Equal(t0.Add(time.Minute)) || !got[3].Start.Equal(t0.Add(4*time.Minute)) {
+		t.Errorf("query = %v", got)
+	}
+	got[0].Fields["cpu_usage"] = FieldAgg{}
+	if tb.buckets[1].Fields["cpu_usage"].Max != 1 {
+		t.Error("query returned a bucket that shares its fields with the buffer")
+	}
+}
+
+func TestPickTier(t *testing.T) {
+	tiers := []Tier{
+		{Name: "raw", Retention: 10 * time.Minute},
+		{Name: "1m", Resolution: time.Minute, Retention: 24 * time.Hour},
+		{Name: "15m", Resolution: 15 * time.Minute, Retention: 30 * 24 * time.Hour},
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		age  time.Duration
+		step time.Duration
+		want string
+	}{
+		{5 * time.Minute, 0, "raw"},
+		{10 * time.Minute, 0, "raw"},
+		{time.Hour, 0, "1m"},
+		{7 * 24 * time.Hour, 0, "15m"},
+		// nothing reaches back that far
+		{365 * 24 * time.Hour, 0, "15m"},
+		// a step takes the coarsest tier that is still as fine as the step
+		{5 * time.Minute, 5 * time.Minute, "1m"},
+		{time.Hour, time.Hour, "15m"},
+		{time.Hour, 30 * time.Second, "1m"},
+		{7 * 24 * time.Hour, 30 * time.Second, "15m"},
+	}
+	for _, tt := range tests {
+		if got := pickTier(tiers, now, now.Add(-tt.age), tt.step); got.Name != tt.want {
+			t.Errorf("pickTier(age %s, step %s) = %s, want %s", tt.age, tt.step, got.Name, tt.want)
+		}
+	}
+}
+
+func TestQueryRollupsDedup(t *testing.T) {
+	s := newTestStorage(t, t.TempDir())
+	defer s.Close()
+
+	tier := testTiers[1]
+	base := time.Now().Add(-10 * time.Minute).Truncate(time.Minute)
+	write := func(start time.Time, count int) {
+		t.Helper()
+		b := Bucket{Start: start, Count: count, Fields: map[string]FieldAgg{"cpu_usage": {Min: 1, Max: 2, Sum: 3}}}
+		if err := s.AppendRollup(testAgent, tier, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(base, 2)
+	write(base.Add(time.Minute), 5)
+	// a late sample changed the first bucket, it is written again
+	write(base, 3)
+
+	buckets, err := s.QueryRollups(testAgent.AgentID, tier, base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("got %d buckets, want 2: %v", len(buckets), buckets)
+	}
+	if !buckets[0].Start.Equal(base) || buckets[0].Count != 3 || buckets[1].Count != 5 {
+		t.Errorf("buckets = %v, want the last record of each", buckets)
+	}
+	// a bucket that started before from but reaches into it is returned
+	buckets, _ = s.QueryRollups(testAgent.AgentID, tier, base.Add(90*time.Second), base.Add(time.Hour))
+	if len(buckets) != 1 || !buckets[0].Start.Equal(base.Add(time.Minute)) {
+		t.Errorf("buckets = %v, want the one covering from", buckets)
+	}
+}
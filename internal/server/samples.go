@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// cleanSamples drops the samples the server cannot store or expose: invalid
+// names, values that are not finite, names taken by AgentMetrics fields, which
+// would mix with their history, names of the built-in labeled series, the
+// server's own glimpse_ names, and samples whose type clashes with an earlier
+// sample's family. When a series comes twice the last value wins, a counter
+// named with and without _total is the same series.
+func cleanSamples(agentID string, samples []*pb.Sample) []*pb.Sample {
+	reserved := make(map[string]string)
+	for _, name := range metricFieldNames() {
+		reserved[name] = "name is an AgentMetrics field"
+	}
+	for _, name := range labeledSeriesNames() {
+		reserved[name] = "name is a built-in series"
+	}
+
+	kept := samples[:0]
+	index := make(map[string]int)
+	types := make(map[string]string) // family name -> type
+	for _, s := range samples {
+		reason := invalidSample(s, reserved)
+		var name, typ string
+		if reason == "" {
+			name, typ = sampleFamily(s)
+			if familyClash(types, name, typ) {
+				reason = "type clashes with another sample of the family"
+			}
+		}
+		if reason != "" {
+			logger.Debugf("Dropping sample %q from agent %s: %s", s.GetName(), agentID, reason)
+			continue
+		}
+		types[name] = typ
+		key := sampleKey(&pb.Sample{Name: name, Labels: s.Labels})
+		if i, ok := index[key]; ok {
+			kept[i] = s
+			continue
+		}
+		index[key] = len(kept)
+		kept = append(kept, s)
+	}
+	return kept
+}
+
+func invalidSample(s *pb.Sample, reserved map[string]string) string {
+	switch {
+	case s == nil:
+		return "empty"
+	case !metricNameRE.MatchString(s.Name):
+		return "invalid name"
+	case reserved[s.Name] != "":
+		return reserved[s.Name]
+	case strings.HasPrefix(s.Name, "glimpse_"):
+		return "glimpse_ names are reserved"
+	case math.IsNaN(s.Value) || math.IsInf(s.Value, 0):
+		return "value is not a finite number"
+	}
+	for name := range s.Labels {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return "invalid label name " + strconv.Quote(name)
+		}
+	}
+	return ""
+}
+
+// sampleLabels returns the labels of a sample sorted by name
+func sampleLabels(s *pb.Sample) [][2]string {
+	labels := make([][2]string, 0, len(s.Labels))
+	for k, v := range s.Labels {
+		labels = append(labels, [2]string{k, v})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+	return labels
+}
+
+// sampleKey names the series of a sample in history: the name, followed by
+// the labels in braces when it has any, e.g. ups_charge{ups="main"}
+func sampleKey(s *pb.Sample) string {
+	if len(s.Labels) == 0 {
+		return s.Name
+	}
+	var b strings.Builder
+	b.WriteString(s.Name)
+	b.WriteByte('{')
+	for i, l := range sampleLabels(s) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l[0])
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l[1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withSampleLabels adds the labels of a sample to the agent's. A sample label
+// that clashes with an agent label is renamed to exported_<name>, like
+// Prometheus does with scraped labels.
+func withSampleLabels(agent [][2]string, s *pb.Sample) [][2]string {
+	labels := agent[:len(agent):len(agent)]
+	for _, l := range sampleLabels(s) {
+		for _, a := range agent {
+			if a[0] == l[0] {
+				l[0] = "exported_" + l[0]
+				break
+			}
+		}
+		labels = append(labels, l)
+	}
+	return labels
+}
+
+// sampleFamily returns the Prometheus family name and type of a sample.
+// Counter names lose their _total suffix, writeFamilies adds it back.
+func sampleFamily(s *pb.Sample) (name, typ string) {
+	if s.Type == pb.SampleType_SAMPLE_COUNTER {
+		return strings.TrimSuffix(s.Name, "_total"), "counter"
+	}
+	return s.Name, "gauge"
+}
+
+// familyClash reports whether a family named name of type typ cannot be
+// exposed next to the families in types: one of the same name has another
+// type, or a gauge is named like the _total samples of a counter
+func familyClash(types map[string]string, name, typ string) bool {
+	if t, ok := types[name]; ok {
+		return t != typ
+	}
+	if typ == "counter" {
+		return types[name+"_total"] == "gauge"
+	}
+	base, ok := strings.CutSuffix(name, "_total")
+	return ok && types[base] == "counter"
+}
+
+// parseSampleKey reads a series key in the form sampleKey writes, with the
+// labels in any order, and returns it in that form
+func parseSampleKey(key string) (string, error) {
+	name, rest, hasLabels := strings.Cut(key, "{")
+	if !metricNameRE.MatchString(name) {
+		return "", fmt.Errorf("invalid metric name %q", name)
+	}
+	s := &pb.Sample{Name: name}
+	if !hasLabels {
+		return sampleKey(s), nil
+	}
+
+	s.Labels = make(map[string]string)
+	for rest != "}" {
+		label, value, ok := strings.Cut(rest, "=")
+		if !ok || !labelNameRE.MatchString(label) {
+			return "", fmt.Errorf("invalid labels in %q", key)
+		}
+		quoted, err := strconv.QuotedPrefix(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid label value in %q", key)
+		}
+		s.Labels[label], _ = strconv.Unquote(quoted)
+		rest = value[len(quoted):]
+		if strings.HasPrefix(rest, ",") {
+			rest = rest[1:]
+		} else if rest != "}" {
+			return "", fmt.Errorf("invalid labels in %q", key)
+		}
+	}
+	return sampleKey(s), nil
+}
+
+// splitKeys splits a comma separated list of series keys, leaving the commas
+// between the labels of a key alone
+func splitKeys(s string) []string {
+	var keys []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			keys = append(keys, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(keys, s[start:])
+}
@@ -0,0 +1,572 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// SegmentStorage keeps history in append-only segment files, one directory per
+// agent and one subdirectory per rollup tier:
+//
+//	<dir>/<agent id>/<segment start unix seconds>.jsonl        raw samples
+//	<dir>/<agent id>/<tier name>/<segment start>.jsonl         finished rollup buckets
+//
+// Each line is one record. Every stream starts a new segment once the current
+// one covers a tenth of its retention, and whole segments are deleted once
+// they are older than the retention. A torn last line after a crash is skipped
+// when reading, and so is the unfinished last line of a segment being written.
+type SegmentStorage struct {
+	mu      sync.Mutex
+	dir     string
+	streams map[string]Tier          // stream name ("" for raw) -> tier
+	open    map[string]*openSegment  // <agent id>/<stream> -> segment being written
+	lists   map[string][]segmentFile // <agent id>/<stream> -> segment files, dropped when files come or go
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type openSegment struct {
+	start time.Time
+	file  *os.File
+}
+
+// segmentRecord is the on-disk form of one raw sample
+type segmentRecord struct {
+	Timestamp int64             `json:"ts"` // unix milliseconds
+	Hostname  string            `json:"hostname"`
+	OS        string            `json:"os"`
+	Metrics   json.RawMessage   `json:"metrics,omitempty"` // missing for a heartbeat with samples only
+	Samples   []json.RawMessage `json:"samples,omitempty"`
+}
+
+// rollupRecord is the on-disk form of one finished bucket
+type rollupRecord struct {
+	Timestamp int64               `json:"ts"` // bucket start, unix milliseconds
+	Hostname  string              `json:"hostname"`
+	OS        string              `json:"os"`
+	Count     int                 `json:"count"`
+	Fields    map[string]FieldAgg `json:"fields"`
+}
+
+const segmentExt = ".jsonl"
+
+// how often segments that fell out of retention are deleted
+const pruneInterval = time.Minute
+
+// samples written by a newer server may carry fields we do not know yet
+var unmarshalOpts = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+// NewSegmentStorage opens the storage in dir. tiers[0] must be the raw tier,
+// the rest are the rollup tiers.
+func NewSegmentStorage(dir string, tiers []Tier) (*SegmentStorage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating history directory: %w", err)
+	}
+	s := &SegmentStorage{
+		dir:     dir,
+		streams: make(map[string]Tier),
+		open:    make(map[string]*openSegment),
+		lists:   make(map[string][]segmentFile),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, t := range tiers {
+		s.streams[streamName(t)] = t
+	}
+	s.pruneNow()
+	go s.pruneLoop()
+	return s, nil
+}
+
+func (s *SegmentStorage) pruneLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.pruneNow()
+		}
+	}
+}
+
+func (s *SegmentStorage) pruneNow() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.prune(time.Now()); err != nil {
+		logger.Errorf("Error pruning old history: %v", err)
+	}
+}
+
+func streamName(t Tier) string {
+	if t.Raw() {
+		return ""
+	}
+	return t.Name
+}
+
+func segmentDuration(t Tier) time.Duration {
+	return max(t.Retention/10, time.Minute)
+}
+
+func (s *SegmentStorage) Append(info AgentInfo, entry MetricEntry) error {
+	var metrics json.RawMessage
+	if entry.Metrics != nil {
+		var err error
+		if metrics, err = protojson.Marshal(entry.Metrics); err != nil {
+			return err
+		}
+	}
+	samples := make([]json.RawMessage, 0, len(entry.Samples))
+	for _, smp := range entry.Samples {
+		b, err := protojson.Marshal(smp)
+		if err != nil {
+			return err
+		}
+		samples = append(samples, b)
+	}
+	line, err := json.Marshal(segmentRecord{
+		Timestamp: entry.Timestamp.UnixMilli(),
+		Hostname:  info.Hostname,
+		OS:        info.OS,
+		Metrics:   metrics,
+		Samples:   samples,
+	})
+	if err != nil {
+		return err
+	}
+	return s.appendLine(info.AgentID, "", entry.Timestamp, line)
+}
+
+func (s *SegmentStorage) AppendRollup(info AgentInfo, tier Tier, b Bucket) error {
+	line, err := json.Marshal(rollupRecord{
+		Timestamp: b.Start.UnixMilli(),
+		Hostname:  info.Hostname,
+		OS:        info.OS,
+		Count:     b.Count,
+		Fields:    b.Fields,
+	})
+	if err != nil {
+		return err
+	}
+	return s.appendLine(info.AgentID, streamName(tier), b.Start, line)
+}
+
+func (s *SegmentStorage) appendLine(agentID, stream string, ts time.Time, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seg, ok := s.open[agentID+"/"+stream]; !ok || ts.Before(seg.start) {
+		segments, err := s.segments(agentID, stream)
+		if err != nil {
+			return err
+		}
+		if len(segments) > 0 && ts.Before(segments[len(segments)-1].start) {
+			created, err := appendBackdated(segments, ts, line)
+			if created {
+				delete(s.lists, agentID+"/"+stream)
+			}
+			return err
+		}
+	}
+
+	seg, err := s.segmentFor(agentID, stream, ts)
+	if err != nil {
+		return err
+	}
+	_, err = seg.file.Write(append(line, '\n'))
+	return err
+}
+
+// appendBackdated writes a record that is older than the newest segment, as
+// replayed heartbeats are, into the segment covering its time. Scans pick
+// segments by their start, so a record must never be in one that starts after
+// it. A record older than every segment gets a segment of its own, created
+// reports that.
+func appendBackdated(segments []segmentFile, ts time.Time, line []byte) (created bool, err error) {
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].start.After(ts) }) - 1
+	path := filepath.Join(filepath.Dir(segments[0].path), strconv.FormatInt(ts.Unix(), 10)+segmentExt)
+	if i >= 0 {
+		path = segments[i].path
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return false, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return i < 0, err
+	}
+	return i < 0, f.Close()
+}
+
+// segmentFor returns the open segment of a stream, starting a new one when the
+// current one is full. Caller must hold mu.
+func (s *SegmentStorage) segmentFor(agentID, stream string, ts time.Time) (*openSegment, error) {
+	tier, ok := s.streams[stream]
+	if !ok {
+		return nil, fmt.Errorf("unknown history tier %q", stream)
+	}
+	key := agentID + "/" + stream
+	seg, ok := s.open[key]
+	if ok && ts.Sub(seg.start) < segmentDuration(tier) {
+		return seg, nil
+	}
+
+	if ok {
+		seg.file.Close()
+		delete(s.open, key)
+	}
+
+	dir, err := s.streamDir(agentID, stream)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	start := ts.Truncate(time.Second)
+	name := filepath.Join(dir, strconv.FormatInt(start.Unix(), 10)+segmentExt)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	seg = &openSegment{start: start, file: f}
+	s.open[key] = seg
+	delete(s.lists, key)
+	return seg, nil
+}
+
+func (s *SegmentStorage) Query(agentID string, from, to time.Time) ([]MetricEntry, error) {
+	var entries []MetricEntry
+	err := s.scan(agentID, "", from, to, func(line []byte) {
+		rec, m, ok := parseRaw(line)
+		if !ok {
+			return
+		}
+		ts := time.UnixMilli(rec.Timestamp)
+		if ts.Before(from) || ts.After(to) {
+			return
+		}
+		entries = append(entries, MetricEntry{Timestamp: ts, Metrics: m, Samples: parseSamples(rec)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	// segments are in order, but samples inside one are in arrival order
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	return entries, nil
+}
+
+func (s *SegmentStorage) QueryRollups(agentID string, tier Tier, from, to time.Time) ([]Bucket, error) {
+	var buckets []Bucket
+	err := s.scan(agentID, streamName(tier), from.Add(-tier.Resolution), to, func(line []byte) {
+		var rec rollupRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return
+		}
+		b := Bucket{Start: time.UnixMilli(rec.Timestamp), Count: rec.Count, Fields: rec.Fields}
+		if b.Fields == nil {
+			b.Fields = make(map[string]FieldAgg)
+		}
+		if b.Start.Add(tier.Resolution).After(from) && !b.Start.After(to) {
+			buckets = append(buckets, b)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+	// a bucket changed by a late sample is written again, the last record has it all
+	deduped := buckets[:0]
+	for _, b := range buckets {
+		if n := len(deduped); n > 0 && deduped[n-1].Start.Equal(b.Start) {
+			deduped[n-1] = b
+			continue
+		}
+		deduped = append(deduped, b)
+	}
+	buckets = deduped
+	return buckets, nil
+}
+
+// scan calls fn for every line of the stream's segments that may hold records
+// between from and to. Only picking the segments holds mu, reading them does
+// not, so a query does not hold up the writes of every agent. A segment
+// pruned in between is skipped.
+func (s *SegmentStorage) scan(agentID, stream string, from, to time.Time, fn func(line []byte)) error {
+	var paths []string
+	s.mu.Lock()
+	segments, err := s.segments(agentID, stream)
+	for i, seg := range segments {
+		// a segment ends where the next one starts
+		if seg.start.After(to) {
+			break
+		}
+		if i+1 < len(segments) && segments[i+1].start.Before(from) {
+			continue
+		}
+		paths = append(paths, seg.path)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := readLines(path, fn); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SegmentStorage) Agents() ([]AgentInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirs, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var agents []AgentInfo
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+
+		// identity comes from the newest record. Raw samples are only kept
+		// briefly, so the newest rollup bucket counts too.
+		info := AgentInfo{AgentID: d.Name()}
+		for stream := range s.streams {
+			segments, err := s.segments(d.Name(), stream)
+			if err != nil || len(segments) == 0 {
+				continue
+			}
+			readLines(segments[len(segments)-1].path, func(line []byte) {
+				var rec struct {
+					Timestamp int64  `json:"ts"`
+					Hostname  string `json:"hostname"`
+					OS        string `json:"os"`
+				}
+				if json.Unmarshal(line, &rec) != nil {
+					return
+				}
+				if ts := time.UnixMilli(rec.Timestamp); ts.After(info.LastSeen) {
+					info.Hostname = rec.Hostname
+					info.OS = rec.OS
+					info.LastSeen = ts
+				}
+			})
+		}
+		if !info.LastSeen.IsZero() {
+			agents = append(agents, info)
+		}
+	}
+	return agents, nil
+}
+
+func (s *SegmentStorage) Delete(agentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for stream := range s.streams {
+		key := agentID + "/" + stream
+		if seg, ok := s.open[key]; ok {
+			seg.file.Close()
+			delete(s.open, key)
+		}
+	}
+	agentDir, err := s.streamDir(agentID, "")
+	if err != nil {
+		return err
+	}
+	for stream := range s.streams {
+		delete(s.lists, agentID+"/"+stream)
+	}
+	return os.RemoveAll(agentDir)
+}
+
+func (s *SegmentStorage) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for key, seg := range s.open {
+		errs = append(errs, seg.file.Close())
+		delete(s.open, key)
+	}
+	return errors.Join(errs...)
+}
+
+// streamDir maps an agent ID and stream to a directory. Agent IDs come off the
+// wire, so anything that could escape the history directory is refused.
+func (s *SegmentStorage) streamDir(agentID, stream string) (string, error) {
+	if agentID == "" || agentID == "." || agentID == ".." || strings.ContainsAny(agentID, `/\`) {
+		return "", fmt.Errorf("invalid agent id %q", agentID)
+	}
+	return filepath.Join(s.dir, agentID, stream), nil
+}
+
+type segmentFile struct {
+	start time.Time
+	path  string
+}
+
+// segments returns the segment files of a stream, oldest first, listing the
+// directory only when the last listing is out of date. The slice must not be
+// modified. Caller must hold mu.
+func (s *SegmentStorage) segments(agentID, stream string) ([]segmentFile, error) {
+	key := agentID + "/" + stream
+	if list, ok := s.lists[key]; ok {
+		return list, nil
+	}
+	list, err := s.listSegments(agentID, stream)
+	if err == nil {
+		s.lists[key] = list
+	}
+	return list, err
+}
+
+func (s *SegmentStorage) listSegments(agentID, stream string) ([]segmentFile, error) {
+	dir, err := s.streamDir(agentID, stream)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segmentFile
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), segmentExt)
+		if !ok || f.IsDir() {
+			continue
+		}
+		sec, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segmentFile{
+			start: time.Unix(sec, 0),
+			path:  filepath.Join(dir, f.Name()),
+		})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start.Before(segments[j].start) })
+	return segments, nil
+}
+
+// prune deletes segments that ended before now - retention of their stream and
+// removes agents that have nothing left. Caller must hold mu.
+func (s *SegmentStorage) prune(now time.Time) error {
+	// other files may have come or gone too, listings are made again
+	defer clear(s.lists)
+
+	dirs, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		remaining := 0
+		for stream, tier := range s.streams {
+			segments, err := s.segments(d.Name(), stream)
+			if err != nil {
+				return err
+			}
+			cutoff := now.Add(-tier.Retention)
+			_, open := s.open[d.Name()+"/"+stream]
+			for i, seg := range segments {
+				end := seg.start.Add(segmentDuration(tier))
+				if i+1 < len(segments) {
+					end = segments[i+1].start
+				} else if open {
+					// still being written to
+					remaining++
+					break
+				}
+				if end.After(cutoff) {
+					remaining += len(segments) - i
+					break
+				}
+				if err := os.Remove(seg.path); err != nil {
+					return err
+				}
+				logger.Debugf("Pruned history segment %s", seg.path)
+			}
+		}
+		if remaining == 0 {
+			logger.Debugf("Removing history of agent %s, nothing left in retention", d.Name())
+			os.RemoveAll(filepath.Join(s.dir, d.Name()))
+		}
+	}
+	return nil
+}
+
+func parseRaw(line []byte) (segmentRecord, *pb.AgentMetrics, bool) {
+	var rec segmentRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return rec, nil, false
+	}
+	if len(rec.Metrics) == 0 {
+		return rec, nil, true
+	}
+	m := &pb.AgentMetrics{}
+	if err := unmarshalOpts.Unmarshal(rec.Metrics, m); err != nil {
+		return rec, nil, false
+	}
+	return rec, m, true
+}
+
+// parseSamples decodes the samples of a raw record, skipping any that do not parse
+func parseSamples(rec segmentRecord) []*pb.Sample {
+	var samples []*pb.Sample
+	for _, raw := range rec.Samples {
+		s := &pb.Sample{}
+		if err := unmarshalOpts.Unmarshal(raw, s); err == nil {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+func readLines(path string, fn func(line []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		// unreadable lines (a torn write before a crash) are skipped by the callers
+		fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
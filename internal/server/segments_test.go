@@ -0,0 +1,251 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+// raw samples get one minute segments, the 1m rollups six minute ones
+var testTiers = []Tier{
+	{Name: "raw", Retention: 10 * time.Minute},
+	{Name: "1m", Resolution: time.Minute, Retention: time.Hour},
+}
+
+var testAgent = AgentInfo{AgentID: "agent-1", Hostname: "web", OS: "linux"}
+
+func newTestStorage(t *testing.T, dir string) *SegmentStorage {
+	t.Helper()
+	s, err := NewSegmentStorage(dir, testTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testEntry is a sample told apart by its cpu_usage
+func testEntry(ts time.Time, cpu int64) MetricEntry {
+	return MetricEntry{Timestamp: ts, Metrics: &pb.AgentMetrics{CpuUsage: cpu}}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range files {
+		files[i] = filepath.Base(f)
+	}
+	slices.Sort(files)
+	return files
+}
+
+func segmentFileName(ts time.Time) string {
+	return strconv.FormatInt(ts.Unix(), 10) + segmentExt
+}
+
+func TestSegmentAppend(t *testing.T) {
+	// a recent start, so pruning at startup leaves the segments alone
+	base := time.Now().Add(-5 * time.Minute).Truncate(time.Minute)
+
+	tests := []struct {
+		name    string
+		offsets []time.Duration // of each sample from base, in the order they arrive
+		files   []time.Duration // starts of the segment files, from base
+	}{
+		{"in order", []time.Duration{0, 20 * time.Second, 40 * time.Second}, []time.Duration{0}},
+		{"rolls over", []time.Duration{0, 30 * time.Second, time.Minute, 90 * time.Second, 2 * time.Minute}, []time.Duration{0, time.Minute, 2 * time.Minute}},
+		// a replayed sample goes into the older segment that covers its time
+		{"back-dated", []time.Duration{0, time.Minute, 2 * time.Minute, 30 * time.Second, 70 * time.Second}, []time.Duration{0, time.Minute, 2 * time.Minute}},
+		// older than every segment, so it gets one of its own
+		{"before the first segment", []time.Duration{0, time.Minute, -2 * time.Minute, -90 * time.Second}, []time.Duration{-2 * time.Minute, 0, time.Minute}},
+		// older than the open segment but still in it
+		{"out of order in the open segment", []time.Duration{0, time.Minute, 80 * time.Second, 70 * time.Second}, []time.Duration{0, time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := newTestStorage(t, dir)
+			defer s.Close()
+
+			var want []time.Time
+			for i, off := range tt.offsets {
+				ts := base.Add(off)
+				if err := s.Append(testAgent, testEntry(ts, int64(i))); err != nil {
+					t.Fatalf("Append at %s: %v", off, err)
+				}
+				want = append(want, ts)
+
+				// the listing cache must not hide a segment that was just created
+				entries, err := s.Query(testAgent.AgentID, base.Add(-time.Hour), base.Add(time.Hour))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(entries) != i+1 {
+					t.Fatalf("after %d appends Query returned %d samples", i+1, len(entries))
+				}
+			}
+
+			var wantFiles []string
+			for _, off := range tt.files {
+				wantFiles = append(wantFiles, segmentFileName(base.Add(off)))
+			}
+			slices.Sort(wantFiles)
+			if got := segmentFiles(t, filepath.Join(dir, testAgent.AgentID)); !slices.Equal(got, wantFiles) {
+				t.Errorf("segments = %v, want %v", got, wantFiles)
+			}
+
+			entries, _ := s.Query(testAgent.AgentID, base.Add(-time.Hour), base.Add(time.Hour))
+			slices.SortFunc(want, func(a, b time.Time) int { return a.Compare(b) })
+			for i, e := range entries {
+				if !e.Timestamp.Equal(want[i]) {
+					t.Errorf("sample %d at %s, want %s", i, e.Timestamp.Sub(base), want[i].Sub(base))
+				}
+			}
+
+			// a range query only returns what is inside it
+			from, to := base.Add(30*time.Second), base.Add(70*time.Second)
+			entries, _ = s.Query(testAgent.AgentID, from, to)
+			for _, e := range entries {
+				if e.Timestamp.Before(from) || e.Timestamp.After(to) {
+					t.Errorf("Query(%s, %s) returned a sample at %s", from.Sub(base), to.Sub(base), e.Timestamp.Sub(base))
+				}
+			}
+		})
+	}
+}
+
+func TestSegmentSamplesOnly(t *testing.T) {
+	s := newTestStorage(t, t.TempDir())
+	defer s.Close()
+
+	ts := time.Now().Truncate(time.Millisecond)
+	entry := MetricEntry{Timestamp: ts, Samples: []*pb.Sample{{Name: "ups_charge", Value: 97}}}
+	if err := s.Append(testAgent, entry); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := s.Query(testAgent.AgentID, ts, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d samples, want 1", len(entries))
+	}
+	if e := entries[0]; e.Metrics != nil || len(e.Samples) != 1 || e.Samples[0].Name != "ups_charge" || e.Samples[0].Value != 97 {
+		t.Errorf("entry = %+v, want the sample without metrics", e)
+	}
+}
+
+func TestSegmentPrune(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStorage(t, dir)
+	base := time.Now().Add(-5 * time.Minute).Truncate(time.Minute)
+	for i := range 3 {
+		s.Append(testAgent, testEntry(base.Add(time.Duration(i)*time.Minute), int64(i)))
+	}
+	s.AppendRollup(testAgent, testTiers[1], Bucket{Start: base, Count: 1, Fields: map[string]FieldAgg{}})
+	// an agent with raw samples only
+	other := AgentInfo{AgentID: "agent-2", Hostname: "db"}
+	s.Append(other, testEntry(base, 1))
+	agentDir := filepath.Join(dir, testAgent.AgentID)
+
+	tests := []struct {
+		name    string
+		now     time.Duration // from base
+		closed  bool          // the segments being written were closed first
+		files   []string      // raw segments of agent-1 left
+		rollups int           // rollup segments of agent-1 left
+	}{
+		{"nothing old enough", 10 * time.Minute, false, []string{segmentFileName(base), segmentFileName(base.Add(time.Minute)), segmentFileName(base.Add(2 * time.Minute))}, 1},
+		// segments end where the next one starts, the open one is never pruned
+		{"raw retention", 12*time.Minute + 30*time.Second, false, []string{segmentFileName(base.Add(2 * time.Minute))}, 1},
+		{"closed segments", 14 * time.Minute, true, nil, 1},
+		{"rollup retention", 2 * time.Hour, true, nil, 0},
+	}
+	for _, tt := range tests {
+		if tt.closed {
+			s.Close()
+			s = newTestStorage(t, dir)
+		}
+		s.mu.Lock()
+		err := s.prune(base.Add(tt.now))
+		s.mu.Unlock()
+		if err != nil {
+			t.Fatalf("%s: prune: %v", tt.name, err)
+		}
+		if got := segmentFiles(t, agentDir); !slices.Equal(got, tt.files) {
+			t.Errorf("%s: raw segments = %v, want %v", tt.name, got, tt.files)
+		}
+		if got := segmentFiles(t, filepath.Join(agentDir, "1m")); len(got) != tt.rollups {
+			t.Errorf("%s: rollup segments = %v, want %d", tt.name, got, tt.rollups)
+		}
+		// agent-2 had only raw samples, its directory goes with them
+		if _, err := os.Stat(filepath.Join(dir, other.AgentID)); tt.closed && !os.IsNotExist(err) {
+			t.Errorf("%s: agent-2 still has a directory", tt.name)
+		}
+	}
+	if _, err := os.Stat(agentDir); !os.IsNotExist(err) {
+		t.Error("agent-1 still has a directory with nothing left in retention")
+	}
+	s.Close()
+}
+
+func TestSegmentRejectsUnsafeAgentID(t *testing.T) {
+	s := newTestStorage(t, t.TempDir())
+	defer s.Close()
+	for _, id := range []string{"", ".", "..", "../escape", `a\b`} {
+		if err := s.Append(AgentInfo{AgentID: id}, testEntry(time.Now(), 1)); err == nil {
+			t.Errorf("Append accepted agent id %q", id)
+		}
+	}
+}
+
+func TestStoreReloadsFromStorage(t *testing.T) {
+	dir := t.TempDir()
+	tiers := []Tier{testTiers[0], {Name: "1m", Resolution: time.Minute, Retention: time.Hour}}
+	store := NewServerStore(10, newTestStorage(t, dir), tiers)
+	for i := range 5 {
+		store.AddOrUpdateAgent(&pb.HeartbeatRequest{AgentId: "agent-1", Hostname: "web", Os: "linux", Metrics: &pb.AgentMetrics{CpuUsage: int64(i)}})
+	}
+	// a heartbeat with samples only is kept too
+	store.AddOrUpdateAgent(&pb.HeartbeatRequest{AgentId: "agent-1", Hostname: "web", Os: "linux", Samples: []*pb.Sample{{Name: "ups_charge", Value: 97}}})
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = NewServerStore(10, newTestStorage(t, dir), tiers)
+	defer store.Close()
+	agent, ok := store.GetAgentData("agent-1")
+	if !ok {
+		t.Fatal("agent was not reloaded")
+	}
+	if agent.Hostname != "web" || agent.OS != "linux" || agent.Status != StatusOffline {
+		t.Errorf("agent = %s %s %s, want web linux offline until it sends a heartbeat", agent.Hostname, agent.OS, agent.Status)
+	}
+	history := agent.History()
+	if len(history) != 6 {
+		t.Fatalf("reloaded %d samples, want 6", len(history))
+	}
+	if last := history[5]; last.Metrics != nil || len(last.Samples) != 1 {
+		t.Errorf("last sample = %+v, want the samples-only heartbeat", last)
+	}
+
+	// the bucket in progress at shutdown was written on Close and is filled on
+	rollups := agent.rollups[0]
+	count := 0
+	for _, b := range rollups.query(time.Now().Add(-time.Hour), time.Now()) {
+		count += b.Count
+	}
+	if count != 6 {
+		t.Errorf("reloaded rollups count %d samples, want 6", count)
+	}
+	if rollups.current == nil {
+		t.Error("the bucket in progress was not reloaded as the current one")
+	}
+}
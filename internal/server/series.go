@@ -0,0 +1,106 @@
+package server
+
+import (
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+// eachLabeledSeries calls fn for every value of the repeated messages in m,
+// which eachNumericField skips. Each value gets the labels that tell its
+// siblings apart, the caller adds the agent's own labels.
+func eachLabeledSeries(m *pb.AgentMetrics, fn func(name, help string, labels [][2]string, v float64)) {
+	for _, fs := range m.GetFilesystems() {
+		labels := [][2]string{{"mountpoint", fs.Mountpoint}, {"device", fs.Device}, {"fstype", fs.Fstype}}
+		fn("filesystem_size_bytes", "Size of the filesystem.", labels, float64(fs.TotalBytes))
+		fn("filesystem_used_bytes", "Bytes used on the filesystem.", labels, float64(fs.UsedBytes))
+		fn("filesystem_free_bytes", "Bytes available to unprivileged users on the filesystem.", labels, float64(fs.FreeBytes))
+		fn("filesystem_inodes", "Inodes on the filesystem.", labels, float64(fs.InodesTotal))
+		fn("filesystem_inodes_used", "Inodes used on the filesystem.", labels, float64(fs.InodesUsed))
+		fn("filesystem_inodes_free", "Inodes free on the filesystem.", labels, float64(fs.InodesFree))
+	}
+	for _, nic := range m.GetNetworkInterfaces() {
+		labels := [][2]string{{"interface", nic.Name}}
+		fn("network_receive_bytes_per_second", "Bytes received per second on the interface.", labels, nic.RxBytes)
+		fn("network_transmit_bytes_per_second", "Bytes sent per second on the interface.", labels, nic.TxBytes)
+		fn("network_receive_packets_per_second", "Packets received per second on the interface.", labels, nic.RxPackets)
+		fn("network_transmit_packets_per_second", "Packets sent per second on the interface.", labels, nic.TxPackets)
+		fn("network_receive_errors_per_second", "Receive errors per second on the interface.", labels, nic.RxErrors)
+		fn("network_transmit_errors_per_second", "Transmit errors per second on the interface.", labels, nic.TxErrors)
+		fn("network_receive_drops_per_second", "Received packets dropped per second on the interface.", labels, nic.RxDropped)
+		fn("network_transmit_drops_per_second", "Outgoing packets dropped per second on the interface.", labels, nic.TxDropped)
+	}
+	for _, s := range m.GetTemperatures() {
+		fn("temperature_celsius", "Temperature of the sensor.", [][2]string{{"sensor", s.Key}, {"name", s.Name}}, s.Celsius)
+	}
+	for _, f := range m.GetFans() {
+		fn("fan_rpm", "Speed of the fan.", [][2]string{{"fan", f.Key}, {"name", f.Name}}, f.Rpm)
+	}
+	for _, c := range m.GetContainers() {
+		labels := [][2]string{{"container", c.Name}, {"image", c.Image}}
+		running := 0.0
+		if c.State == "running" {
+			running = 1
+		}
+		fn("container_running", "1 if the container is running.", labels, running)
+		fn("container_restarts", "Times the engine restarted the container.", labels, float64(c.RestartCount))
+		fn("container_cpu_percent", "CPU used by the container, in percent of one core.", labels, c.CpuPercent)
+		fn("container_memory_bytes", "Memory used by the container, page cache excluded.", labels, float64(c.MemoryBytes))
+		fn("container_memory_limit_bytes", "Memory limit of the container.", labels, float64(c.MemoryLimit))
+		fn("container_network_receive_bytes_per_second", "Bytes received per second by the container.", labels, c.NetRxBytes)
+		fn("container_network_transmit_bytes_per_second", "Bytes sent per second by the container.", labels, c.NetTxBytes)
+		fn("container_block_read_bytes_per_second", "Bytes read per second from block devices by the container.", labels, c.BlockReadBytes)
+		fn("container_block_written_bytes_per_second", "Bytes written per second to block devices by the container.", labels, c.BlockWriteBytes)
+	}
+	if m.GetSystemdState() != "" {
+		fn("systemd_failed_units", "Units systemd reports as failed.", nil, float64(len(m.GetFailedUnits())))
+	}
+	for _, u := range m.GetWatchedUnits() {
+		active, failed := 0.0, 0.0
+		switch u.ActiveState {
+		case "active":
+			active = 1
+		case "failed":
+			failed = 1
+		}
+		fn("systemd_unit_active", "1 if the watched unit is active.", [][2]string{{"unit", u.Name}}, active)
+		fn("systemd_unit_failed", "1 if the watched unit has failed.", [][2]string{{"unit", u.Name}}, failed)
+	}
+	for _, c := range m.GetChecks() {
+		labels := [][2]string{{"check", c.Name}}
+		fn("check_exit_code", "Exit code of the last run of the exec command, -1 when it was killed.", labels, float64(c.ExitCode))
+		fn("check_duration_seconds", "How long the last run of the exec command took.", labels, c.DurationSeconds)
+	}
+	for _, d := range m.GetDiskDevices() {
+		labels := [][2]string{{"device", d.Name}}
+		fn("disk_read_bytes_per_second", "Bytes read per second from the device.", labels, d.ReadBytes)
+		fn("disk_written_bytes_per_second", "Bytes written per second to the device.", labels, d.WriteBytes)
+		fn("disk_reads_per_second", "Read operations completed per second on the device.", labels, d.Reads)
+		fn("disk_writes_per_second", "Write operations completed per second on the device.", labels, d.Writes)
+		fn("disk_busy_percent", "Percent of the time the device had I/O in flight.", labels, d.Busy)
+	}
+}
+
+// labeledSeriesNames lists the names eachLabeledSeries reports, found by
+// giving it metrics with one of every repeated message
+func labeledSeriesNames() []string {
+	m := &pb.AgentMetrics{
+		Filesystems:       []*pb.Filesystem{{}},
+		NetworkInterfaces: []*pb.NetworkInterface{{}},
+		Temperatures:      []*pb.Sensor{{}},
+		Fans:              []*pb.Fan{{}},
+		Containers:        []*pb.Container{{}},
+		SystemdState:      "running",
+		WatchedUnits:      []*pb.SystemdUnit{{}},
+		Checks:            []*pb.CheckResult{{}},
+		DiskDevices:       []*pb.DiskDevice{{}},
+	}
+	var names []string
+	eachLabeledSeries(m, func(name, _ string, _ [][2]string, _ float64) {
+		names = append(names, name)
+	})
+	return names
+}
+
+// withLabels returns the agent labels followed by the series' own
+func withLabels(agent, own [][2]string) [][2]string {
+	return append(agent[:len(agent):len(agent)], own...)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	"github.com/mansoormajeed/glimpse/internal/common/logger"
+)
+
+// AgentStatus is where an agent is in its lifecycle. Statuses are ordered:
+// without heartbeats an agent only moves forward, a heartbeat brings it back online.
+type AgentStatus int
+
+const (
+	StatusOnline AgentStatus = iota
+	StatusLate
+	StatusOffline
+	StatusRetired
+)
+
+func (st AgentStatus) String() string {
+	switch st {
+	case StatusOnline:
+		return "online"
+	case StatusLate:
+		return "late"
+	case StatusOffline:
+		return "offline"
+	case StatusRetired:
+		return "retired"
+	default:
+		return "unknown"
+	}
+}
+
+func (st AgentStatus) MarshalText() ([]byte, error) {
+	return []byte(st.String()), nil
+}
+
+// interval assumed for agents we have not seen two heartbeats from yet, same as the agent default
+const defaultHeartbeatInterval = time.Second
+
+// observeInterval folds the gap between two heartbeats into the agent's
+// estimated heartbeat interval. A moving average keeps one slow heartbeat from
+// moving the thresholds much.
+func (a *AgentData) observeInterval(gap time.Duration) {
+	if a.Interval == 0 {
+		a.Interval = gap
+		return
+	}
+	a.Interval = (3*a.Interval + gap) / 4
+}
+
+// AgentConnected tells the store an agent opened a new stream. The gap to its
+// next heartbeat is how long it was away, not its interval.
+func (s *ServerStore) AgentConnected(agentID string) {
+	s.Lock()
+	defer s.Unlock()
+	if agent, ok := s.agents[agentID]; ok {
+		agent.skipGap = true
+	}
+}
+
+// StartStatusSweeper re-evaluates the status of every agent in the background
+// until the store is closed. agentInterval is the interval pushed to agents, if
+// any, and is used for agents whose own interval is not known yet.
+func (s *ServerStore) StartStatusSweeper(cfg config.ServerStatusConfig, agentInterval time.Duration) {
+	s.Lock()
+	s.statusCfg = cfg
+	s.agentInterval = agentInterval
+	s.stop = make(chan struct{})
+	stop := s.stop
+	s.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(cfg.SweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				s.sweep(now)
+			}
+		}
+	}()
+}
+
+func (s *ServerStore) sweep(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, agent := range s.agents {
+		st := s.statusAt(agent, now)
+		if st <= agent.Status {
+			// only a heartbeat brings an agent back
+			continue
+		}
+		logger.Infof("Agent %s (%s) is now %s, last seen %s", agent.Hostname, agent.AgentID, st, formatRelative(agent.LastSeen))
+		agent.Status = st
+		agent.StatusSince = now
+	}
+}
+
+// statusAt works out the status an agent should have at now from how long ago it was last seen
+func (s *ServerStore) statusAt(agent *AgentData, now time.Time) AgentStatus {
+	interval := agent.Interval
+	if interval == 0 {
+		interval = s.agentInterval
+	}
+	if interval == 0 {
+		interval = defaultHeartbeatInterval
+	}
+
+	silent := now.Sub(agent.LastSeen)
+	switch {
+	case s.statusCfg.RetireAfter > 0 && silent >= s.statusCfg.RetireAfter:
+		return StatusRetired
+	case silent >= time.Duration(s.statusCfg.OfflineAfter)*interval:
+		return StatusOffline
+	case silent >= time.Duration(s.statusCfg.LateAfter)*interval:
+		return StatusLate
+	default:
+		return StatusOnline
+	}
+}
+
+// Forget drops an agent from memory and storage. An agent that is still
+// running shows up again with its next heartbeat.
+func (s *ServerStore) Forget(agentID string) (bool, error) {
+	s.Lock()
+	_, ok := s.agents[agentID]
+	delete(s.agents, agentID)
+	s.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	if s.storage != nil {
+		if err := s.storage.Delete(agentID); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mansoormajeed/glimpse/internal/common/config"
+	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
+)
+
+var testStatusConfig = config.ServerStatusConfig{
+	LateAfter:     3,
+	OfflineAfter:  10,
+	RetireAfter:   time.Hour,
+	SweepInterval: time.Second,
+}
+
+// statusStore is a store without a running sweeper whose clock only moves
+// when the test says so
+type statusStore struct {
+	*ServerStore
+	clock time.Time
+}
+
+func newStatusStore(cfg config.ServerStatusConfig, agentInterval time.Duration) *statusStore {
+	s := &statusStore{ServerStore: NewServerStore(10, nil, nil), clock: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s.now = func() time.Time { return s.clock }
+	s.statusCfg = cfg
+	s.agentInterval = agentInterval
+	return s
+}
+
+func (s *statusStore) heartbeat(delay time.Duration) {
+	s.AddOrUpdateAgent(&pb.HeartbeatRequest{AgentId: "agent-1", Hostname: "web", DelayMs: delay.Milliseconds()})
+}
+
+// advance moves the clock forward by d, sweeping every second on the way like the sweeper does
+func (s *statusStore) advance(d time.Duration) {
+	end := s.clock.Add(d)
+	for s.clock.Before(end) {
+		s.clock = s.clock.Add(min(time.Second, end.Sub(s.clock)))
+		s.sweep(s.clock)
+	}
+}
+
+func (s *statusStore) agent() *AgentData {
+	a, _ := s.GetAgentData("agent-1")
+	return a
+}
+
+func TestStatusTransitions(t *testing.T) {
+	s := newStatusStore(testStatusConfig, 0)
+	s.heartbeat(0)
+	s.advance(10 * time.Second)
+	s.heartbeat(0)
+	if s.agent().Interval != 10*time.Second {
+		t.Fatalf("interval = %s, want 10s", s.agent().Interval)
+	}
+
+	// silent for, from the last heartbeat
+	steps := []struct {
+		at   time.Duration
+		want AgentStatus
+	}{
+		{29 * time.Second, StatusOnline},
+		{30 * time.Second, StatusLate},
+		{99 * time.Second, StatusLate},
+		{100 * time.Second, StatusOffline},
+		{time.Hour - time.Second, StatusOffline},
+		{time.Hour, StatusRetired},
+	}
+	last := s.clock
+	for _, st := range steps {
+		s.advance(last.Add(st.at).Sub(s.clock))
+		a := s.agent()
+		if a.Status != st.want {
+			t.Errorf("after %s status = %s, want %s", st.at, a.Status, st.want)
+		}
+	}
+	if since := s.agent().StatusSince; !since.Equal(last.Add(time.Hour)) {
+		t.Errorf("retired since %s, want when it changed", since.Sub(last))
+	}
+
+	// a sweep from the past does not move the agent back
+	s.sweep(last)
+	if st := s.agent().Status; st != StatusRetired {
+		t.Errorf("status = %s after an earlier sweep, want retired", st)
+	}
+
+	s.heartbeat(0)
+	if a := s.agent(); a.Status != StatusOnline || !a.StatusSince.Equal(s.clock) {
+		t.Errorf("status = %s since %s, want online now", a.Status, a.StatusSince)
+	}
+}
+
+func TestStatusIntervalFallbacks(t *testing.T) {
+	tests := []struct {
+		name          string
+		interval      time.Duration // learned from the agent, 0 for none yet
+		agentInterval time.Duration // pushed to agents by the server
+		late          time.Duration // first silence that makes it late
+	}{
+		{"learned", 10 * time.Second, 5 * time.Second, 30 * time.Second},
+		{"pushed", 0, 5 * time.Second, 15 * time.Second},
+		{"default", 0, 0, 3 * defaultHeartbeatInterval},
+	}
+	for _, tt := range tests {
+		s := newStatusStore(testStatusConfig, tt.agentInterval)
+		s.heartbeat(0)
+		s.agents["agent-1"].Interval = tt.interval
+		seen := s.agent().LastSeen
+
+		if st := s.statusAt(s.agents["agent-1"], seen.Add(tt.late-time.Millisecond)); st != StatusOnline {
+			t.Errorf("%s: status = %s just before %s, want online", tt.name, st, tt.late)
+		}
+		if st := s.statusAt(s.agents["agent-1"], seen.Add(tt.late)); st != StatusLate {
+			t.Errorf("%s: status = %s after %s, want late", tt.name, st, tt.late)
+		}
+	}
+}
+
+func TestStatusNeverRetiresWithoutRetireAfter(t *testing.T) {
+	cfg := testStatusConfig
+	cfg.RetireAfter = 0
+	s := newStatusStore(cfg, 0)
+	s.heartbeat(0)
+	s.sweep(s.clock.Add(365 * 24 * time.Hour))
+	if st := s.agent().Status; st != StatusOffline {
+		t.Errorf("status = %s, want offline", st)
+	}
+}
+
+// An agent sending every minute is offline before its second heartbeat under
+// the 1s default. The gaps it sends while offline still teach the server its
+// interval, after which it stays online.
+func TestStatusLearnsLongInterval(t *testing.T) {
+	s := newStatusStore(testStatusConfig, 0)
+	s.heartbeat(0)
+
+	comebacks := 0
+	for i := 0; i < 5; i++ {
+		s.advance(time.Minute)
+		if s.agent().Status != StatusOnline {
+			comebacks++
+		}
+		s.heartbeat(0)
+	}
+	if a := s.agent(); a.Interval != time.Minute {
+		t.Errorf("interval = %s, want 1m", a.Interval)
+	}
+	if comebacks != 1 {
+		t.Errorf("agent came back online %d times, want only after its first gap", comebacks)
+	}
+}
+
+func TestStatusGapsThatAreNotIntervals(t *testing.T) {
+	s := newStatusStore(testStatusConfig, 0)
+	s.heartbeat(0)
+	s.advance(2 * time.Second)
+	s.heartbeat(0)
+
+	// the gap across a reconnect is how long the agent was away
+	s.advance(time.Hour)
+	s.AgentConnected("agent-1")
+	s.heartbeat(0)
+	if i := s.agent().Interval; i != 2*time.Second {
+		t.Errorf("interval = %s after a reconnect, want 2s", i)
+	}
+
+	// the heartbeats buffered while away are replayed first, neither they nor
+	// the gap after the last of them count
+	s.advance(100 * time.Millisecond)
+	s.heartbeat(time.Minute)
+	s.advance(100 * time.Millisecond)
+	s.heartbeat(30 * time.Second)
+	s.advance(100 * time.Millisecond)
+	s.heartbeat(0)
+	if i := s.agent().Interval; i != 2*time.Second {
+		t.Errorf("interval = %s after replayed heartbeats, want 2s", i)
+	}
+
+	// live gaps count again, averaged with what was known
+	s.advance(6 * time.Second)
+	s.heartbeat(0)
+	if i := s.agent().Interval; i != 3*time.Second {
+		t.Errorf("interval = %s, want 3s", i)
+	}
+}
+
+func TestStatusSkipsGapAfterReload(t *testing.T) {
+	dir := t.TempDir()
+	store := NewServerStore(10, newTestStorage(t, dir), testTiers)
+	store.AddOrUpdateAgent(&pb.HeartbeatRequest{AgentId: "agent-1", Hostname: "web", Metrics: &pb.AgentMetrics{}})
+	store.Close()
+
+	store = NewServerStore(10, newTestStorage(t, dir), testTiers)
+	defer store.Close()
+	store.now = func() time.Time { return time.Now().Add(time.Hour) }
+	store.AddOrUpdateAgent(&pb.HeartbeatRequest{AgentId: "agent-1", Hostname: "web", Metrics: &pb.AgentMetrics{}})
+	if a, _ := store.GetAgentData("agent-1"); a.Interval != 0 || a.Status != StatusOnline {
+		t.Errorf("interval = %s, status %s, want no interval from the gap across the restart", a.Interval, a.Status)
+	}
+}
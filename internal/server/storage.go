@@ -0,0 +1,33 @@
+package server
+
+import (
+	"time"
+)
+
+// AgentInfo is the identity of an agent as last seen in storage
+type AgentInfo struct {
+	AgentID  string
+	Hostname string
+	OS       string
+	LastSeen time.Time
+}
+
+// Storage persists metric samples so history survives a restart. ServerStore
+// keeps its in-memory ring buffer either way; storage is written through on
+// every heartbeat and read back at startup and for ranges the ring buffer no
+// longer covers.
+type Storage interface {
+	// Append persists one sample for an agent
+	Append(info AgentInfo, entry MetricEntry) error
+	// Query returns the samples of agentID with from <= Timestamp <= to, oldest first
+	Query(agentID string, from, to time.Time) ([]MetricEntry, error)
+	// AppendRollup persists a finished bucket of a rollup tier
+	AppendRollup(info AgentInfo, tier Tier, b Bucket) error
+	// QueryRollups returns the buckets of a rollup tier overlapping [from, to], oldest first
+	QueryRollups(agentID string, tier Tier, from, to time.Time) ([]Bucket, error)
+	// Agents returns every agent that still has samples in storage
+	Agents() ([]AgentInfo, error)
+	// Delete drops everything stored for an agent
+	Delete(agentID string) error
+	Close() error
+}
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/mansoormajeed/glimpse/internal/common/config"
 	"github.com/mansoormajeed/glimpse/internal/common/logger"
 	pb "github.com/mansoormajeed/glimpse/pkg/pb/proto"
 )
@@ -11,6 +14,7 @@ import (
 type MetricEntry struct {
 	Timestamp time.Time
 	Metrics   *pb.AgentMetrics
+	Samples   []*pb.Sample
 }
 
 type AgentData struct {
@@ -19,56 +23,197 @@ type AgentData struct {
 	OS           string
 	LastSeen     time.Time
 	ConnectedFor time.Duration
+	Status       AgentStatus
+	StatusSince  time.Time
+	Interval     time.Duration // heartbeat interval estimated from the gaps between heartbeats
+	skipGap      bool          // the last heartbeat was replayed or the agent just connected, so the next gap says nothing about the interval
 
 	MetricsHistory []MetricEntry
 	metricsIndex   int // points to the next write position.
 	metricsCount   int // tracks how many valid entries exist.
+
+	rollups []*tierBuffer // one per rollup tier, finest first
 }
 
 type ServerStore struct {
 	sync.Mutex
 	agents     map[string]*AgentData
 	bufferSize int
+	storage    Storage // nil keeps history in memory only
+	tiers      []Tier  // raw tier first, then the rollup tiers
+	heartbeats uint64  // received since startup
+	now        func() time.Time
+
+	// set by StartStatusSweeper
+	statusCfg     config.ServerStatusConfig
+	agentInterval time.Duration
+	stop          chan struct{}
 }
 
-func NewServerStore(bufferSize int) *ServerStore {
-	return &ServerStore{
+// how far back to look in storage when refilling the ring buffers at startup
+const reloadWindow = time.Hour
+
+// NewServerStore creates the store. With a storage backend the ring buffers and
+// rollups are refilled from what was stored before the restart.
+func NewServerStore(bufferSize int, storage Storage, tiers []Tier) *ServerStore {
+	s := &ServerStore{
 		agents:     make(map[string]*AgentData),
 		bufferSize: bufferSize,
+		storage:    storage,
+		tiers:      tiers,
+		now:        time.Now,
+	}
+	if storage != nil {
+		s.reload()
 	}
+	return s
+}
+
+func (s *ServerStore) reload() {
+	infos, err := s.storage.Agents()
+	if err != nil {
+		logger.Errorf("Error loading agents from storage: %v", err)
+		return
+	}
+
+	for _, info := range infos {
+		entries, err := s.storage.Query(info.AgentID, info.LastSeen.Add(-reloadWindow), info.LastSeen)
+		if err != nil {
+			logger.Errorf("Error loading history for agent %s: %v", info.AgentID, err)
+			continue
+		}
+		if len(entries) > s.bufferSize {
+			entries = entries[len(entries)-s.bufferSize:]
+		}
+
+		agent := s.newAgent(info.AgentID, info.Hostname, info.OS)
+		agent.LastSeen = info.LastSeen
+		// nothing is known about it until it sends a heartbeat to this server
+		agent.Status = StatusOffline
+		agent.StatusSince = time.Now()
+		agent.skipGap = true
+		for _, e := range entries {
+			agent.push(e)
+		}
+
+		for _, tb := range agent.rollups {
+			buckets, err := s.storage.QueryRollups(info.AgentID, tb.tier, time.Now().Add(-tb.tier.Retention), time.Now())
+			if err != nil {
+				logger.Errorf("Error loading %s rollups for agent %s: %v", tb.tier.Name, info.AgentID, err)
+				continue
+			}
+			// the bucket that was in progress at shutdown is filled on
+			if n := len(buckets); n > 0 && buckets[n-1].Start.Add(tb.tier.Resolution).After(time.Now()) {
+				tb.current = &buckets[n-1]
+				buckets = buckets[:n-1]
+			}
+			tb.buckets = buckets
+		}
+		s.agents[info.AgentID] = agent
+	}
+	logger.Infof("Loaded history for %d agents from storage", len(infos))
 }
 
 func (s *ServerStore) AddOrUpdateAgent(req *pb.HeartbeatRequest) {
 	s.Lock()
-	defer s.Unlock()
+	s.heartbeats++
 	logger.Debugf("Adding/updating agent: %s", req.AgentId)
 	agent, exists := s.agents[req.AgentId]
 	if !exists {
 		logger.Debugf("Creating new agent entry for: %s", req.Hostname)
-		agent = &AgentData{
-			AgentID:        req.AgentId,
-			Hostname:       req.Hostname,
-			OS:             req.Os,
-			MetricsHistory: make([]MetricEntry, s.bufferSize),
-		}
+		agent = s.newAgent(req.AgentId, req.Hostname, req.Os)
 		s.agents[req.AgentId] = agent
 	}
 
-	agent.LastSeen = time.Now()
+	now := s.now()
+	// a replayed heartbeat goes where it belongs in history, but it still shows the agent is up now
+	replayed := req.DelayMs > 0
+	if !exists {
+		agent.StatusSince = now
+	}
+	// late or offline agents are measured too, an interval longer than the
+	// thresholds allow for would otherwise never be learned
+	if exists && !replayed && !agent.skipGap {
+		agent.observeInterval(now.Sub(agent.LastSeen))
+	}
+	agent.skipGap = replayed
+	if agent.Status != StatusOnline {
+		logger.Infof("Agent %s (%s) is back online", req.Hostname, req.AgentId)
+		agent.Status = StatusOnline
+		agent.StatusSince = now
+	}
+
+	agent.Hostname = req.Hostname
+	agent.OS = req.Os
+	agent.LastSeen = now
 	agent.ConnectedFor = time.Duration(req.ConnectedFor) * time.Second
 
 	entry := MetricEntry{
-		Timestamp: time.Now(),
+		Timestamp: now.Add(-time.Duration(req.DelayMs) * time.Millisecond),
 		Metrics:   req.Metrics,
+		Samples:   req.Samples,
 	}
-	agent.MetricsHistory[agent.metricsIndex] = entry
+	agent.insert(entry)
+	logger.Debugf("added to the index: %d", agent.metricsIndex)
 
-	agent.metricsIndex = (agent.metricsIndex + 1) % s.bufferSize
-	if agent.metricsCount < s.bufferSize {
-		agent.metricsCount++
+	// roll the sample up into every tier, remembering buckets it closed or changed
+	var finished []finishedBucket
+	if entry.Metrics != nil || len(entry.Samples) > 0 {
+		for _, tb := range agent.rollups {
+			if b := tb.add(entry); b != nil {
+				finished = append(finished, finishedBucket{tier: tb.tier, bucket: *b})
+			}
+		}
 	}
 
-	logger.Debugf("added to the index: %d", agent.metricsIndex)
+	info := AgentInfo{
+		AgentID:  agent.AgentID,
+		Hostname: agent.Hostname,
+		OS:       agent.OS,
+		LastSeen: agent.LastSeen,
+	}
+	s.Unlock()
+
+	// disk I/O happens outside the store lock
+	if s.storage != nil && (entry.Metrics != nil || len(entry.Samples) > 0) {
+		if err := s.storage.Append(info, entry); err != nil {
+			logger.Errorf("Error persisting metrics for agent %s: %v", info.AgentID, err)
+		}
+		for _, f := range finished {
+			if err := s.storage.AppendRollup(info, f.tier, f.bucket); err != nil {
+				logger.Errorf("Error persisting %s rollup for agent %s: %v", f.tier.Name, info.AgentID, err)
+			}
+		}
+	}
+}
+
+// maxRetention is how far back the longest kept tier reaches
+func (s *ServerStore) maxRetention() time.Duration {
+	var longest time.Duration
+	for _, t := range s.tiers {
+		longest = max(longest, t.Retention)
+	}
+	return longest
+}
+
+type finishedBucket struct {
+	tier   Tier
+	bucket Bucket
+}
+
+func (s *ServerStore) newAgent(agentID, hostname, os string) *AgentData {
+	agent := &AgentData{
+		AgentID:        agentID,
+		Hostname:       hostname,
+		OS:             os,
+		MetricsHistory: make([]MetricEntry, s.bufferSize),
+	}
+	for _, t := range s.tiers {
+		if !t.Raw() {
+			agent.rollups = append(agent.rollups, &tierBuffer{tier: t})
+		}
+	}
+	return agent
 }
 
 func (s *ServerStore) GetAllAgents() []*AgentData {
@@ -98,6 +243,156 @@ func (s *ServerStore) GetAgentData(agentId string) (*AgentData, bool) {
 	return &agentCopy, true
 }
 
+// History returns the samples of an agent between from and to, oldest first.
+// Storage is used when there is one, otherwise only the ring buffer is searched.
+func (s *ServerStore) History(agentID string, from, to time.Time) ([]MetricEntry, error) {
+	if s.storage != nil {
+		return s.storage.Query(agentID, from, to)
+	}
+
+	agent, ok := s.GetAgentData(agentID)
+	if !ok {
+		return nil, nil
+	}
+	var entries []MetricEntry
+	for _, e := range agent.History() {
+		if !e.Timestamp.Before(from) && !e.Timestamp.After(to) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// QueryHistory returns an agent's history between from and to from the finest
+// tier that still covers from (see pickTier), along with the tier it used.
+func (s *ServerStore) QueryHistory(agentID string, from, to time.Time, step time.Duration) (Tier, []Bucket, error) {
+	now := time.Now()
+	s.Lock()
+	tiers := s.tiers
+	if agent, ok := s.agents[agentID]; ok {
+		tiers = s.agentTiers(agent, now)
+	}
+	s.Unlock()
+	tier := pickTier(tiers, now, from, step)
+
+	if tier.Raw() {
+		entries, err := s.History(agentID, from, to)
+		if err != nil {
+			return tier, nil, err
+		}
+		buckets := make([]Bucket, 0, len(entries))
+		for _, e := range entries {
+			buckets = append(buckets, bucketFromEntry(e))
+		}
+		return tier, buckets, nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	agent, ok := s.agents[agentID]
+	if !ok {
+		return tier, nil, nil
+	}
+	for _, tb := range agent.rollups {
+		if tb.tier == tier {
+			return tier, tb.query(from, to), nil
+		}
+	}
+	return tier, nil, nil
+}
+
+// agentTiers returns the tiers as far as they reach back for one agent.
+// Without storage raw samples only live in the ring buffer, so once that is
+// full the raw tier ends at its oldest entry rather than storage.retention and
+// older ranges go to the rollups. Caller must hold the lock.
+func (s *ServerStore) agentTiers(agent *AgentData, now time.Time) []Tier {
+	if s.storage != nil || agent.metricsCount < len(agent.MetricsHistory) {
+		return s.tiers
+	}
+	oldest := agent.MetricsHistory[agent.metricsIndex%len(agent.MetricsHistory)].Timestamp
+	tiers := slices.Clone(s.tiers)
+	tiers[0].Retention = min(tiers[0].Retention, now.Sub(oldest))
+	return tiers
+}
+
+// Close stops the status sweeper and flushes and closes the storage backend, if
+// any. The rollup buckets still being filled are written too, so a restart
+// does not lose them.
+func (s *ServerStore) Close() error {
+	s.Lock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	type pending struct {
+		info AgentInfo
+		finishedBucket
+	}
+	var unfinished []pending
+	if s.storage != nil {
+		for _, agent := range s.agents {
+			info := AgentInfo{AgentID: agent.AgentID, Hostname: agent.Hostname, OS: agent.OS, LastSeen: agent.LastSeen}
+			for _, tb := range agent.rollups {
+				if tb.current != nil {
+					unfinished = append(unfinished, pending{info, finishedBucket{tier: tb.tier, bucket: tb.current.copy()}})
+				}
+			}
+		}
+	}
+	s.Unlock()
+
+	if s.storage == nil {
+		return nil
+	}
+	for _, p := range unfinished {
+		if err := s.storage.AppendRollup(p.info, p.tier, p.bucket); err != nil {
+			logger.Errorf("Error persisting %s rollup for agent %s: %v", p.tier.Name, p.info.AgentID, err)
+		}
+	}
+	return s.storage.Close()
+}
+
+// insert adds an entry to the ring buffer in timestamp order. An entry older
+// than everything a full buffer holds is left out.
+func (a *AgentData) insert(entry MetricEntry) {
+	if a.metricsCount == 0 {
+		a.push(entry)
+		return
+	}
+	size := len(a.MetricsHistory)
+	newest := a.MetricsHistory[(a.metricsIndex-1+size)%size]
+	if !entry.Timestamp.Before(newest.Timestamp) {
+		a.push(entry)
+		return
+	}
+
+	entries := a.History()
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Timestamp.After(entry.Timestamp) })
+	if i == 0 && a.metricsCount == size {
+		return
+	}
+	entries = slices.Insert(entries, i, entry)
+	if len(entries) > size {
+		entries = entries[1:]
+	}
+	a.metricsIndex, a.metricsCount = 0, 0
+	for _, e := range entries {
+		a.push(e)
+	}
+}
+
+// push writes an entry at the head of the ring buffer
+func (a *AgentData) push(entry MetricEntry) {
+	size := len(a.MetricsHistory)
+	a.MetricsHistory[a.metricsIndex] = entry
+
+	a.metricsIndex = (a.metricsIndex + 1) % size
+	if a.metricsCount < size {
+		a.metricsCount++
+	}
+}
+
 func (a *AgentData) Latest() *pb.AgentMetrics {
 	if a.metricsCount == 0 {
 		return nil
@@ -105,3 +400,23 @@ func (a *AgentData) Latest() *pb.AgentMetrics {
 	idx := (a.metricsIndex - 1 + len(a.MetricsHistory)) % len(a.MetricsHistory)
 	return a.MetricsHistory[idx].Metrics
 }
+
+// LatestSamples returns the samples of the last heartbeat
+func (a *AgentData) LatestSamples() []*pb.Sample {
+	if a.metricsCount == 0 {
+		return nil
+	}
+	idx := (a.metricsIndex - 1 + len(a.MetricsHistory)) % len(a.MetricsHistory)
+	return a.MetricsHistory[idx].Samples
+}
+
+// History returns the valid entries of the ring buffer, oldest first
+func (a *AgentData) History() []MetricEntry {
+	size := len(a.MetricsHistory)
+	entries := make([]MetricEntry, 0, a.metricsCount)
+	start := (a.metricsIndex - a.metricsCount + size) % size
+	for i := 0; i < a.metricsCount; i++ {
+		entries = append(entries, a.MetricsHistory[(start+i)%size])
+	}
+	return entries
+}
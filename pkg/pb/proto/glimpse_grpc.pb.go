@@ -19,14 +19,23 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	GlimpseService_Heartbeat_FullMethodName = "/glimpse.GlimpseService/Heartbeat"
+	GlimpseService_Heartbeat_FullMethodName     = "/glimpse.GlimpseService/Heartbeat"
+	GlimpseService_StreamMetrics_FullMethodName = "/glimpse.GlimpseService/StreamMetrics"
+	GlimpseService_Enroll_FullMethodName        = "/glimpse.GlimpseService/Enroll"
 )
 
 // GlimpseServiceClient is the client API for GlimpseService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type GlimpseServiceClient interface {
+	// Heartbeat is the original one-shot RPC. Kept for older agents.
 	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
+	// StreamMetrics keeps one long lived stream per agent. The agent sends a
+	// HeartbeatRequest every interval and the server can push commands back.
+	StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[HeartbeatRequest, ServerCommand], error)
+	// Enroll trades a pre-shared join token for a per-agent secret. Every
+	// other call must carry the agent id and secret as metadata.
+	Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*EnrollResponse, error)
 }
 
 type glimpseServiceClient struct {
@@ -47,11 +56,41 @@ func (c *glimpseServiceClient) Heartbeat(ctx context.Context, in *HeartbeatReque
 	return out, nil
 }
 
+func (c *glimpseServiceClient) StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[HeartbeatRequest, ServerCommand], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &GlimpseService_ServiceDesc.Streams[0], GlimpseService_StreamMetrics_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[HeartbeatRequest, ServerCommand]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GlimpseService_StreamMetricsClient = grpc.BidiStreamingClient[HeartbeatRequest, ServerCommand]
+
+func (c *glimpseServiceClient) Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*EnrollResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EnrollResponse)
+	err := c.cc.Invoke(ctx, GlimpseService_Enroll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GlimpseServiceServer is the server API for GlimpseService service.
 // All implementations must embed UnimplementedGlimpseServiceServer
 // for forward compatibility.
 type GlimpseServiceServer interface {
+	// Heartbeat is the original one-shot RPC. Kept for older agents.
 	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	// StreamMetrics keeps one long lived stream per agent. The agent sends a
+	// HeartbeatRequest every interval and the server can push commands back.
+	StreamMetrics(grpc.BidiStreamingServer[HeartbeatRequest, ServerCommand]) error
+	// Enroll trades a pre-shared join token for a per-agent secret. Every
+	// other call must carry the agent id and secret as metadata.
+	Enroll(context.Context, *EnrollRequest) (*EnrollResponse, error)
 	mustEmbedUnimplementedGlimpseServiceServer()
 }
 
@@ -65,6 +104,12 @@ type UnimplementedGlimpseServiceServer struct{}
 func (UnimplementedGlimpseServiceServer) Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
 }
+func (UnimplementedGlimpseServiceServer) StreamMetrics(grpc.BidiStreamingServer[HeartbeatRequest, ServerCommand]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMetrics not implemented")
+}
+func (UnimplementedGlimpseServiceServer) Enroll(context.Context, *EnrollRequest) (*EnrollResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Enroll not implemented")
+}
 func (UnimplementedGlimpseServiceServer) mustEmbedUnimplementedGlimpseServiceServer() {}
 func (UnimplementedGlimpseServiceServer) testEmbeddedByValue()                        {}
 
@@ -104,6 +149,31 @@ func _GlimpseService_Heartbeat_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GlimpseService_StreamMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GlimpseServiceServer).StreamMetrics(&grpc.GenericServerStream[HeartbeatRequest, ServerCommand]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GlimpseService_StreamMetricsServer = grpc.BidiStreamingServer[HeartbeatRequest, ServerCommand]
+
+func _GlimpseService_Enroll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GlimpseServiceServer).Enroll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GlimpseService_Enroll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GlimpseServiceServer).Enroll(ctx, req.(*EnrollRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // GlimpseService_ServiceDesc is the grpc.ServiceDesc for GlimpseService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,7 +185,18 @@ var GlimpseService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Heartbeat",
 			Handler:    _GlimpseService_Heartbeat_Handler,
 		},
+		{
+			MethodName: "Enroll",
+			Handler:    _GlimpseService_Enroll_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMetrics",
+			Handler:       _GlimpseService_StreamMetrics_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/glimpse.proto",
 }
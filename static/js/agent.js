@@ -0,0 +1,119 @@
+// Agent detail page: refresh the process tables while the page is open
+
+function initAgentPage() {
+    const detail = document.querySelector('.agent-detail');
+    if (!detail) return;
+
+    setInterval(() => updateAgentPage(detail), 2000);
+}
+
+async function updateAgentPage(detail) {
+    try {
+        const response = await fetch(`/agent/${detail.dataset.agentId}/data`);
+        if (!response.ok) return;
+        const agent = await response.json();
+
+        document.querySelector('.agent-status').textContent = agent.Status;
+        document.querySelector('.last-seen-ago').textContent = `Last seen ${agent.LastSeenAgo}`;
+        ['online', 'late', 'offline'].forEach(s => detail.classList.toggle(`status-${s}`, s === agent.Status));
+
+        const sensors = detail.querySelector('.sensor-table tbody');
+        if (sensors) {
+            sensors.innerHTML = sensorRows(agent.Metrics);
+        }
+
+        const containers = detail.querySelector('.container-table tbody');
+        if (containers) {
+            containers.innerHTML = containerRows(agent.Metrics.containers);
+        }
+
+        const checks = detail.querySelector('.check-table tbody');
+        if (checks) {
+            checks.innerHTML = checkRows(agent.Metrics.checks);
+        }
+
+        const samples = detail.querySelector('.sample-table tbody');
+        if (samples) {
+            samples.innerHTML = sampleRows(agent.Samples);
+        }
+
+        const topCPU = detail.querySelector('.top-cpu-table tbody');
+        if (topCPU) {
+            topCPU.innerHTML = processRows(agent.Metrics.top_cpu);
+        }
+
+        const topMemory = detail.querySelector('.top-memory-table tbody');
+        if (topMemory) {
+            topMemory.innerHTML = processRows(agent.Metrics.top_memory);
+        }
+    } catch (error) {
+        console.error('Failed to update agent:', error);
+    }
+}
+
+function sensorRows(metrics) {
+    const temps = (metrics.temperatures || []).map(t =>
+        `<tr><td>${escapeHTML(t.key)}</td><td>${escapeHTML(t.name)}</td><td class="num">${(t.celsius || 0).toFixed(1)}°C</td></tr>`);
+    const fans = (metrics.fans || []).map(f =>
+        `<tr><td>${escapeHTML(f.key)}</td><td>${escapeHTML(f.name)}</td><td class="num">${Math.round(f.rpm || 0)} RPM</td></tr>`);
+    return temps.concat(fans).join('');
+}
+
+function containerRows(containers) {
+    const rate = v => `${formatBytes(Math.floor(v || 0))}/s`;
+    return (containers || []).map(c => `
+        <tr class="state-${escapeHTML(c.state)}">
+            <td><span class="container-state"></span> ${escapeHTML(c.name)}</td>
+            <td>${escapeHTML(c.image)}</td>
+            <td>${escapeHTML(c.status)}</td>
+            <td class="num">${c.restart_count || 0}</td>
+            <td class="num">${(c.cpu_percent || 0).toFixed(1)}%</td>
+            <td class="num">${formatBytes(c.memory_bytes || 0)}</td>
+            <td class="num">${rate(c.net_rx_bytes)} / ${rate(c.net_tx_bytes)}</td>
+            <td class="num">${rate(c.block_read_bytes)} / ${rate(c.block_write_bytes)}</td>
+        </tr>
+    `).join('');
+}
+
+function checkRows(checks) {
+    return (checks || []).map(c => `
+        <tr class="${c.exit_code ? 'check-failed' : 'check-ok'}">
+            <td>${escapeHTML(c.name)}</td>
+            <td>${c.exit_code ? `exit ${c.exit_code}` : 'ok'}</td>
+            <td class="num">${(c.duration_seconds || 0).toFixed(2)}s</td>
+            <td class="cmdline" title="${escapeHTML(c.stderr)}">${escapeHTML(c.stderr)}</td>
+        </tr>
+    `).join('');
+}
+
+function sampleRows(samples) {
+    return (samples || []).map(s => {
+        const labels = Object.keys(s.labels || {}).sort().map(k => `${k}="${s.labels[k]}" `).join('');
+        return `<tr><td>${escapeHTML(s.name)}</td><td>${escapeHTML(labels)}</td><td class="num">${escapeHTML(sampleValue(s))}</td></tr>`;
+    }).join('');
+}
+
+// sampleValue matches formatSampleValue on the server
+function sampleValue(s) {
+    const value = s.value || 0;
+    if (s.unit === 'bytes' && value >= 0) {
+        return formatBytes(Math.floor(value));
+    }
+    const rounded = String(Math.round(value * 100) / 100);
+    return s.unit ? `${rounded} ${s.unit}` : rounded;
+}
+
+function processRows(processes) {
+    return (processes || []).map(p => `
+        <tr>
+            <td>${p.pid}</td>
+            <td>${escapeHTML(p.user)}</td>
+            <td>${escapeHTML(p.name)}</td>
+            <td class="num">${(p.cpu_percent || 0).toFixed(1)}%</td>
+            <td class="num">${formatBytes(p.rss || 0)}</td>
+            <td class="cmdline" title="${escapeHTML(p.cmdline)}">${escapeHTML(p.cmdline)}</td>
+        </tr>
+    `).join('');
+}
+
+document.addEventListener('DOMContentLoaded', initAgentPage);
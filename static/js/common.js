@@ -0,0 +1,21 @@
+// Helpers shared by the dashboard and the agent page
+
+// escapeHTML makes text reported by an agent safe to put in markup and in quoted attributes
+function escapeHTML(text) {
+    return String(text ?? '').replace(/[&<>"']/g, c => ({
+        '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'
+    })[c]);
+}
+
+function formatBytes(bytes) {
+    const units = ['KiB', 'MiB', 'GiB', 'TiB', 'PiB', 'EiB'];
+    if (bytes < 1024) {
+        return `${bytes} B`;
+    }
+    let i = -1;
+    do {
+        bytes /= 1024;
+        i++;
+    } while (bytes >= 1024 && i < units.length - 1);
+    return `${bytes.toFixed(1)} ${units[i]}`;
+}
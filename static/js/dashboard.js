@@ -2,17 +2,43 @@
 window.agentData = window.agentData || {};
 window.charts = window.charts || {};
 
+// Number of points kept on each chart
+const maxChartPoints = 15;
+
 // Initialize dashboard updates
 function initDashboard() {
     // Start polling for updates every second
     setInterval(updateDashboard, 1000);
+    setInterval(updateAlerts, 5000);
     
     // Initial load via HTMX for the agent cards
     setTimeout(() => {
         updateDashboard();
+        updateAlerts();
     }, 100);
 }
 
+// Show firing alerts in a banner above the agent cards
+async function updateAlerts() {
+    const banner = document.getElementById('alerts-banner');
+    if (!banner) return;
+
+    try {
+        const response = await fetch('/api/v1/alerts');
+        const alerts = await response.json();
+        const firing = alerts.filter(alert => alert.state === 'firing');
+
+        banner.hidden = firing.length === 0;
+        banner.innerHTML = firing.map(alert => `
+            <div class="alert alert-${escapeHTML(alert.severity)}">
+                <strong>${escapeHTML(alert.hostname)}</strong>: ${escapeHTML(alert.rule)} <span class="alert-expr">(${escapeHTML(alert.expr)})</span>
+            </div>
+        `).join('');
+    } catch (error) {
+        console.error('Failed to update alerts:', error);
+    }
+}
+
 // Update dashboard with new data
 async function updateDashboard() {
     try {
@@ -41,10 +67,13 @@ async function updateDashboard() {
         // Update each agent
         agents.forEach(agent => {
             updateAgentCard(agent);
+            if (agent.Status !== 'online') {
+                return; // don't keep plotting the last values of a silent agent
+            }
             createOrUpdateChart(agent.Hostname, {
-                cpu: agent.Metrics.cpu_usage,
-                memory: agent.Metrics.memory_usage,
-                temp: agent.Metrics.cpu_temp
+                cpu: unavailable(agent.Metrics, 'cpu_usage') ? null : agent.Metrics.cpu_usage,
+                memory: unavailable(agent.Metrics, 'memory_usage') ? null : agent.Metrics.memory_usage,
+                temp: unavailable(agent.Metrics, 'cpu_temp') ? null : agent.Metrics.cpu_temp
             });
         });
         
@@ -65,18 +94,20 @@ function updateAgentCard(agent) {
     if (!existingCard) {
         // Agent doesn't exist, create new card
         createAgentCard(agent);
+        seedChartHistory(agent);
         return;
     }
     
     // Update existing card metrics
     const metrics = [
-        { selector: '.cpu .metric-value', value: `${agent.Metrics.cpu_usage}%` },
-        { selector: '.memory .metric-value', value: `${agent.Metrics.memory_usage}%` },
-        { selector: '.disk .metric-value', value: `${agent.Metrics.disk_usage}%` },
-        { selector: '.network .metric-value', value: `↑${agent.Metrics.network_upload} ↓${agent.Metrics.network_download}` },
-        { selector: '.temp .metric-value', value: `${agent.Metrics.cpu_temp}°C` },
+        { selector: '.cpu .metric-value', value: metricText(agent.Metrics, 'cpu_usage', `${agent.Metrics.cpu_usage}%`) },
+        { selector: '.memory .metric-value', value: metricText(agent.Metrics, 'memory_usage', `${agent.Metrics.memory_usage}%`) },
+        { selector: '.disk .metric-value', value: metricText(agent.Metrics, 'disk_usage', `${agent.Metrics.disk_usage}%`) },
+        { selector: '.network .metric-value', value: metricText(agent.Metrics, 'network_upload', `↑${agent.Metrics.network_upload} ↓${agent.Metrics.network_download}`) },
+        { selector: '.temp .metric-value', value: metricText(agent.Metrics, 'cpu_temp', `${agent.Metrics.cpu_temp}°C`) },
         { selector: '.uptime .metric-value', value: agent.FormattedUptime },
-        { selector: '.last-seen', value: `Last seen ${agent.LastSeenAgo}` }
+        { selector: '.agent-status', value: agent.Status },
+        { selector: '.last-seen-ago', value: `Last seen ${agent.LastSeenAgo}` }
     ];
     
     metrics.forEach(metric => {
@@ -85,6 +116,18 @@ function updateAgentCard(agent) {
             element.textContent = metric.value;
         }
     });
+
+    updateCPUDetail(existingCard, agent.Metrics);
+    updateMemoryDetail(existingCard, agent.Metrics);
+    updateFilesystems(existingCard, agent.Metrics);
+    updateContainers(existingCard, agent.Metrics);
+    updateSystemdBadge(existingCard, agent.Metrics);
+
+    setStatusClass(existingCard, agent.Status);
+}
+
+function setStatusClass(card, status) {
+    ['online', 'late', 'offline'].forEach(s => card.classList.toggle(`status-${s}`, s === status));
 }
 
 // Create a new agent card
@@ -102,11 +145,12 @@ function createAgentCard(agent) {
     }
     
     const cardHTML = `
-        <div class="agent-card" data-agent-id="${agent.Hostname}">
+        <div class="agent-card status-${agent.Status}" data-agent-id="${agent.Hostname}">
             <div class="agent-header">
                 <div class="agent-title">
                     <span class="status-indicator"></span>
-                    ${agent.Hostname}
+                    <a href="/agent/${encodeURIComponent(agent.AgentID)}">${escapeHTML(agent.Hostname)}</a>
+                    <span class="systemd-badge" hidden></span>
                 </div>
                 <div class="agent-os">${agent.OS}</div>
             </div>
@@ -116,7 +160,7 @@ function createAgentCard(agent) {
                     <div class="metric-icon">⚡</div>
                     <div class="metric-content">
                         <div class="metric-label">CPU</div>
-                        <div class="metric-value">${agent.Metrics.cpu_usage}%</div>
+                        <div class="metric-value">${metricText(agent.Metrics, 'cpu_usage', `${agent.Metrics.cpu_usage}%`)}</div>
                     </div>
                 </div>
                 
@@ -124,7 +168,7 @@ function createAgentCard(agent) {
                     <div class="metric-icon">▣</div>
                     <div class="metric-content">
                         <div class="metric-label">Memory</div>
-                        <div class="metric-value">${agent.Metrics.memory_usage}%</div>
+                        <div class="metric-value">${metricText(agent.Metrics, 'memory_usage', `${agent.Metrics.memory_usage}%`)}</div>
                     </div>
                 </div>
                 
@@ -132,7 +176,7 @@ function createAgentCard(agent) {
                     <div class="metric-icon">◉</div>
                     <div class="metric-content">
                         <div class="metric-label">Disk</div>
-                        <div class="metric-value">${agent.Metrics.disk_usage}%</div>
+                        <div class="metric-value">${metricText(agent.Metrics, 'disk_usage', `${agent.Metrics.disk_usage}%`)}</div>
                     </div>
                 </div>
                 
@@ -140,7 +184,7 @@ function createAgentCard(agent) {
                     <div class="metric-icon">⟲</div>
                     <div class="metric-content">
                         <div class="metric-label">Network</div>
-                        <div class="metric-value">↑${agent.Metrics.network_upload} ↓${agent.Metrics.network_download}</div>
+                        <div class="metric-value">${metricText(agent.Metrics, 'network_upload', `↑${agent.Metrics.network_upload} ↓${agent.Metrics.network_download}`)}</div>
                     </div>
                 </div>
                 
@@ -148,7 +192,7 @@ function createAgentCard(agent) {
                     <div class="metric-icon">◐</div>
                     <div class="metric-content">
                         <div class="metric-label">Temp</div>
-                        <div class="metric-value">${agent.Metrics.cpu_temp}°C</div>
+                        <div class="metric-value">${metricText(agent.Metrics, 'cpu_temp', `${agent.Metrics.cpu_temp}°C`)}</div>
                     </div>
                 </div>
                 
@@ -161,17 +205,129 @@ function createAgentCard(agent) {
                 </div>
             </div>
             
+            <div class="cpu-detail">
+                <div class="core-strip"></div>
+                <div class="load-avg"></div>
+            </div>
+
+            <div class="mem-detail">
+                <div class="mem-bar">
+                    <span class="mem-used" title="Used"></span><span class="mem-buffers" title="Buffers"></span><span class="mem-cached" title="Page cache"></span>
+                </div>
+                <div class="mem-text"></div>
+                <div class="swap-text"></div>
+            </div>
+
+            <div class="fs-list"></div>
+
+            <div class="container-list"></div>
+
             <div class="chart-container">
                 <canvas id="chart-${agent.Hostname}"></canvas>
             </div>
             
             <div class="last-seen">
-                Last seen ${agent.LastSeenAgo}
+                <span class="agent-status">${agent.Status}</span> · <span class="last-seen-ago">Last seen ${agent.LastSeenAgo}</span>
             </div>
         </div>
     `;
     
     agentsContainer.insertAdjacentHTML('beforeend', cardHTML);
+    updateCPUDetail(agentsContainer.lastElementChild, agent.Metrics);
+    updateMemoryDetail(agentsContainer.lastElementChild, agent.Metrics);
+    updateFilesystems(agentsContainer.lastElementChild, agent.Metrics);
+    updateContainers(agentsContainer.lastElementChild, agent.Metrics);
+    updateSystemdBadge(agentsContainer.lastElementChild, agent.Metrics);
+}
+
+// Per-core heat strip and load averages
+function updateCPUDetail(card, metrics) {
+    const strip = card.querySelector('.core-strip');
+    const cores = metrics.cpu_per_core || [];
+
+    // rebuild the cells only when the number of cores changes
+    if (strip.children.length !== cores.length) {
+        strip.innerHTML = cores.map(() => '<span class="core"></span>').join('');
+    }
+    cores.forEach((usage, i) => {
+        const cell = strip.children[i];
+        cell.style.setProperty('--usage', usage);
+        cell.title = `Core ${i}: ${Math.round(usage)}%`;
+    });
+
+    const load = [metrics.load1, metrics.load5, metrics.load15].map(v => (v || 0).toFixed(2)).join(' ');
+    card.querySelector('.load-avg').textContent = `load ${load} · ${metrics.cpu_count || 0} cores`;
+}
+
+// Memory bar split into used, buffers and page cache, with the absolute numbers below
+function updateMemoryDetail(card, metrics) {
+    const total = metrics.mem_total || 0;
+    const share = bytes => total > 0 ? (bytes || 0) / total * 100 : 0;
+    card.querySelector('.mem-used').style.setProperty('--share', share(metrics.mem_used));
+    card.querySelector('.mem-buffers').style.setProperty('--share', share(metrics.mem_buffers));
+    card.querySelector('.mem-cached').style.setProperty('--share', share(metrics.mem_cached));
+
+    card.querySelector('.mem-text').textContent =
+        `${formatBytes(metrics.mem_used || 0)} used · ${formatBytes(metrics.mem_cached || 0)} cache · ` +
+        `${formatBytes(metrics.mem_available || 0)} available of ${formatBytes(total)}`;
+
+    const swapTotal = metrics.swap_total || 0;
+    const swapUsed = metrics.swap_used || 0;
+    const swapPercent = swapTotal > 0 ? Math.round(swapUsed / swapTotal * 100) : 0;
+    card.querySelector('.swap-text').textContent =
+        `swap ${formatBytes(swapUsed)} / ${formatBytes(swapTotal)} (${swapPercent}%) · ` +
+        `in ${formatBytes(Math.floor(metrics.swap_in || 0))}/s · out ${formatBytes(Math.floor(metrics.swap_out || 0))}/s`;
+}
+
+// One row per mounted filesystem with a usage bar
+function updateFilesystems(card, metrics) {
+    const list = card.querySelector('.fs-list');
+    list.innerHTML = (metrics.filesystems || []).map(fs => {
+        const usable = (fs.used_bytes || 0) + (fs.free_bytes || 0);
+        const used = usable > 0 ? Math.round((fs.used_bytes || 0) / usable * 100) : 0;
+        const title = `${fs.device} (${fs.fstype}), inodes ${fs.inodes_used || 0} / ${fs.inodes_total || 0}`;
+        return `<div class="fs-row" title="${escapeHTML(title)}">
+            <span class="fs-mount">${escapeHTML(fs.mountpoint)}</span>
+            <span class="fs-bar"><span class="fs-fill" style="--used: ${used}"></span></span>
+            <span class="fs-size">${formatBytes(fs.used_bytes || 0)} / ${formatBytes(fs.total_bytes || 0)}</span>
+        </div>`;
+    }).join('');
+}
+
+// systemd state badge, the failed and watched units are in its tooltip
+function updateSystemdBadge(card, metrics) {
+    const badge = card.querySelector('.systemd-badge');
+    const state = metrics.systemd_state || '';
+    const failed = metrics.failed_units || [];
+
+    badge.hidden = state === '';
+    badge.className = `systemd-badge systemd-${state}`;
+    badge.textContent = failed.length > 0 ? `${state} · ${failed.length} failed` : state;
+    badge.title = failed.map(u => `${u.name} failed`)
+        .concat((metrics.watched_units || []).map(u => `${u.name}: ${u.active_state}`))
+        .join('\n');
+}
+
+// Containers of the host, one row each with a state dot
+function updateContainers(card, metrics) {
+    const list = card.querySelector('.container-list');
+    list.innerHTML = (metrics.containers || []).map(c => {
+        const restarts = c.restart_count ? ` · ${c.restart_count} restarts` : '';
+        return `<div class="container-row state-${escapeHTML(c.state)}" title="${escapeHTML(`${c.image} · ${c.status}${restarts}`)}">
+            <span class="container-state"></span>
+            <span class="container-name">${escapeHTML(c.name)}</span>
+            <span class="container-usage">${(c.cpu_percent || 0).toFixed(1)}% · ${formatBytes(c.memory_bytes || 0)}</span>
+        </div>`;
+    }).join('');
+}
+
+// unavailable reports whether the agent could not collect field in its last heartbeat
+function unavailable(metrics, field) {
+    return (metrics.unavailable || []).some(u => (u.fields || []).includes(field));
+}
+
+function metricText(metrics, field, text) {
+    return unavailable(metrics, field) ? 'n/a' : text;
 }
 
 function createOrUpdateChart(hostname, metrics) {
@@ -199,9 +355,8 @@ function createOrUpdateChart(hostname, metrics) {
     data.memory.push(metrics.memory);
     data.temp.push(metrics.temp);
     
-    // Keep only last 15 data points for performance
-    const maxPoints = 15;
-    if (data.timestamps.length > maxPoints) {
+    // Keep only the last few data points for performance
+    if (data.timestamps.length > maxChartPoints) {
         data.timestamps.shift();
         data.cpu.shift();
         data.memory.shift();
@@ -216,6 +371,60 @@ function createOrUpdateChart(hostname, metrics) {
     }
 }
 
+// Seed a new chart with recent history so it doesn't start out empty
+async function seedChartHistory(agent) {
+    const fields = 'cpu_usage,memory_usage,cpu_temp';
+    const url = `/api/v1/agents/${encodeURIComponent(agent.AgentID)}/metrics?from=-${maxChartPoints}s&step=1s&fields=${fields}`;
+
+    try {
+        const response = await fetch(url);
+        if (!response.ok) {
+            return;
+        }
+        const history = await response.json();
+
+        // fields the agent could not collect are null, leave a gap for those
+        const round = v => v === null ? null : Math.round(v);
+        const seeded = { timestamps: [], cpu: [], memory: [], temp: [] };
+        history.timestamps.forEach((ts, i) => {
+            const cpu = history.series.cpu_usage.avg[i];
+            const memory = history.series.memory_usage.avg[i];
+            const temp = history.series.cpu_temp.avg[i];
+            if (cpu === null && memory === null && temp === null) {
+                return; // no sample in this slot
+            }
+            seeded.timestamps.push(new Date(ts));
+            seeded.cpu.push(round(cpu));
+            seeded.memory.push(round(memory));
+            seeded.temp.push(round(temp));
+        });
+
+        // keep live points that arrived while the request was in flight
+        const live = window.agentData[agent.Hostname];
+        const lastSeeded = seeded.timestamps.length ? seeded.timestamps[seeded.timestamps.length - 1] : 0;
+        if (live) {
+            live.timestamps.forEach((ts, i) => {
+                if (ts > lastSeeded) {
+                    seeded.timestamps.push(ts);
+                    seeded.cpu.push(live.cpu[i]);
+                    seeded.memory.push(live.memory[i]);
+                    seeded.temp.push(live.temp[i]);
+                }
+            });
+        }
+
+        const extra = seeded.timestamps.length - maxChartPoints;
+        if (extra > 0) {
+            Object.keys(seeded).forEach(key => seeded[key].splice(0, extra));
+        }
+
+        window.agentData[agent.Hostname] = seeded;
+        updateChart(agent.Hostname, seeded);
+    } catch (error) {
+        console.error('Failed to load chart history:', error);
+    }
+}
+
 function createChart(hostname, canvas, data) {
     const ctx = canvas.getContext('2d');
     